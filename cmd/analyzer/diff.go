@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/konveyor/analyzer-lsp/engine"
+)
+
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -[0-9]+(?:,[0-9]+)? \+([0-9]+)(?:,([0-9]+))? @@`)
+
+// getChangedLines returns the lines that were added or modified between the base and head
+// revisions for every file under the given locations. When head is empty, the base is
+// compared to the working tree, including the files that are not tracked yet.
+func getChangedLines(ctx context.Context, log logr.Logger, locations []string, base, head string) (map[string][]engine.LineRange, error) {
+	changed := map[string][]engine.LineRange{}
+	seen := map[string]bool{}
+	foundRepo := false
+	for _, location := range locations {
+		absLocation, err := filepath.Abs(location)
+		if err != nil {
+			absLocation = location
+		}
+		if seen[absLocation] {
+			continue
+		}
+		seen[absLocation] = true
+
+		if err := exec.CommandContext(ctx, "git", "-C", absLocation, "rev-parse", "--git-dir").Run(); err != nil {
+			log.V(5).Info("location is not in a git repository, skipping diff", "location", absLocation)
+			continue
+		}
+		foundRepo = true
+
+		args := []string{"-C", absLocation, "-c", "core.quotePath=false", "diff",
+			"--relative", "--no-prefix", "--no-color", "--no-ext-diff", "--unified=0", "--diff-filter=d", base}
+		if head != "" {
+			args = append(args, head)
+		}
+		args = append(args, "--")
+		cmd := exec.CommandContext(ctx, "git", args...)
+		stderr := &bytes.Buffer{}
+		cmd.Stderr = stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("unable to diff %s against %s in %s: %w: %s", head, base, absLocation, err, stderr.String())
+		}
+		fileLines, err := parseUnifiedDiff(out)
+		if err != nil {
+			return nil, err
+		}
+		for file, lines := range fileLines {
+			path := filepath.Join(absLocation, filepath.FromSlash(file))
+			changed[path] = append(changed[path], lines...)
+		}
+		if head != "" {
+			continue
+		}
+		// Files that are not tracked yet are not in the diff with the working tree,
+		// all of their lines are new.
+		untracked, err := getUntrackedFiles(ctx, absLocation)
+		if err != nil {
+			return nil, err
+		}
+		for path, lines := range untracked {
+			changed[path] = append(changed[path], lines...)
+		}
+	}
+	if !foundRepo {
+		return nil, fmt.Errorf("none of the analyzed locations are in a git repository")
+	}
+	return changed, nil
+}
+
+// getUntrackedFiles returns the files under the location that are not tracked
+// and not ignored by git, with a single range for all of their lines.
+func getUntrackedFiles(ctx context.Context, location string) (map[string][]engine.LineRange, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", location, "ls-files", "-z", "--others", "--exclude-standard")
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list untracked files in %s: %w: %s", location, err, stderr.String())
+	}
+	untracked := map[string][]engine.LineRange{}
+	for _, file := range strings.Split(string(out), "\x00") {
+		if file == "" {
+			continue
+		}
+		path := filepath.Join(location, filepath.FromSlash(file))
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read untracked file %s: %w", path, err)
+		}
+		lines := bytes.Count(content, []byte("\n"))
+		if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+			lines++
+		}
+		untracked[path] = []engine.LineRange{}
+		if lines > 0 {
+			untracked[path] = append(untracked[path], engine.LineRange{Start: 1, End: lines})
+		}
+	}
+	return untracked, nil
+}
+
+// parseUnifiedDiff reads a diff with no context lines and returns the changed line ranges
+// in the new version of each file, keyed by the path of the file in the diff.
+func parseUnifiedDiff(diff []byte) (map[string][]engine.LineRange, error) {
+	changed := map[string][]engine.LineRange{}
+	current := ""
+	// Added lines can also start with "+++", only trust it as a file name in the header.
+	inHeader := false
+	scanner := bufio.NewScanner(bytes.NewReader(diff))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			inHeader = true
+			current = ""
+		case inHeader && strings.HasPrefix(line, "+++ "):
+			current = diffFileName(strings.TrimPrefix(line, "+++ "))
+			if current == "/dev/null" {
+				current = ""
+				continue
+			}
+			if _, ok := changed[current]; !ok {
+				changed[current] = []engine.LineRange{}
+			}
+		case strings.HasPrefix(line, "@@ "):
+			inHeader = false
+			if current == "" {
+				continue
+			}
+			match := hunkHeaderRegex.FindStringSubmatch(line)
+			if match == nil {
+				return nil, fmt.Errorf("unable to parse hunk header %q", line)
+			}
+			start, err := strconv.Atoi(match[1])
+			if err != nil {
+				return nil, err
+			}
+			count := 1
+			if match[2] != "" {
+				count, err = strconv.Atoi(match[2])
+				if err != nil {
+					return nil, err
+				}
+			}
+			// Hunks that only remove lines have nothing to report on in the new file.
+			if count == 0 {
+				continue
+			}
+			changed[current] = append(changed[current], engine.LineRange{
+				Start: start,
+				End:   start + count - 1,
+			})
+		}
+	}
+	return changed, scanner.Err()
+}
+
+// diffFileName returns the path of a file name in the header of a diff. Git
+// ends names with spaces with a tab and quotes names with special characters,
+// in C style.
+func diffFileName(name string) string {
+	name = strings.TrimSuffix(name, "\t")
+	if strings.HasPrefix(name, `"`) {
+		if unquoted, err := strconv.Unquote(name); err == nil {
+			return unquoted
+		}
+	}
+	return name
+}
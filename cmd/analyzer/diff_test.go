@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/konveyor/analyzer-lsp/engine"
+)
+
+func TestParseUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want map[string][]engine.LineRange
+	}{
+		{
+			name: "additions only",
+			diff: `diff --git src/Main.java src/Main.java
+index 94ebaf9..29759b3 100644
+--- src/Main.java
++++ src/Main.java
+@@ -2,0 +3,2 @@ import javax.ejb.Stateless;
++import javax.ejb.Singleton;
++import javax.ejb.EJB;
+@@ -10,0 +13 @@ public class Main {
++    @EJB
+`,
+			want: map[string][]engine.LineRange{"src/Main.java": {{Start: 3, End: 4}, {Start: 13, End: 13}}},
+		},
+		{
+			name: "deletions only",
+			diff: `diff --git src/Main.java src/Main.java
+index 94ebaf9..29759b3 100644
+--- src/Main.java
++++ src/Main.java
+@@ -3,2 +2,0 @@ import javax.ejb.Stateless;
+-import javax.ejb.Singleton;
+-import javax.ejb.EJB;
+`,
+			want: map[string][]engine.LineRange{"src/Main.java": {}},
+		},
+		{
+			name: "modified lines",
+			diff: `diff --git pom.xml pom.xml
+index 94ebaf9..29759b3 100644
+--- pom.xml
++++ pom.xml
+@@ -3 +3 @@
+-  <version>1.0</version>
++  <version>2.0</version>
+`,
+			want: map[string][]engine.LineRange{"pom.xml": {{Start: 3, End: 3}}},
+		},
+		{
+			name: "deleted file",
+			diff: `diff --git src/Old.java src/Old.java
+deleted file mode 100644
+index 94ebaf9..0000000
+--- src/Old.java
++++ /dev/null
+@@ -1,2 +0,0 @@
+-class Old {
+-}
+`,
+			want: map[string][]engine.LineRange{},
+		},
+		{
+			name: "new file",
+			diff: `diff --git src/New.java src/New.java
+new file mode 100644
+index 0000000..94ebaf9
+--- /dev/null
++++ src/New.java
+@@ -0,0 +1,2 @@
++class New {
++}
+`,
+			want: map[string][]engine.LineRange{"src/New.java": {{Start: 1, End: 2}}},
+		},
+		{
+			name: "renamed file",
+			diff: `diff --git src/Old.java src/New.java
+similarity index 50%
+rename from src/Old.java
+rename to src/New.java
+index 94ebaf9..29759b3 100644
+--- src/Old.java
++++ src/New.java
+@@ -1 +1 @@
+-class Old {
++class New {
+diff --git src/Same.java src/Moved.java
+similarity index 100%
+rename from src/Same.java
+rename to src/Moved.java
+`,
+			want: map[string][]engine.LineRange{"src/New.java": {{Start: 1, End: 1}}},
+		},
+		{
+			name: "file names with spaces and special characters",
+			diff: "diff --git my file.txt my file.txt\n" +
+				"index de98044..0f7bc76 100644\n" +
+				"--- my file.txt\t\n" +
+				"+++ my file.txt\t\n" +
+				"@@ -2 +2 @@ a\n" +
+				"-b\n" +
+				"+B\n" +
+				"diff --git \"tab\\tname.txt\" \"tab\\tname.txt\"\n" +
+				"new file mode 100644\n" +
+				"index 0000000..bca70f3\n" +
+				"--- /dev/null\n" +
+				"+++ \"tab\\tname.txt\"\n" +
+				"@@ -0,0 +1 @@\n" +
+				"+q\n",
+			want: map[string][]engine.LineRange{
+				"my file.txt":   {{Start: 2, End: 2}},
+				"tab\tname.txt": {{Start: 1, End: 1}},
+			},
+		},
+		{
+			name: "added line that looks like a file header",
+			diff: `diff --git notes.md notes.md
+index 94ebaf9..29759b3 100644
+--- notes.md
++++ notes.md
+@@ -1,0 +2 @@
++++ other.md
+@@ -5,0 +7 @@
++line
+`,
+			want: map[string][]engine.LineRange{"notes.md": {{Start: 2, End: 2}, {Start: 7, End: 7}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUnifiedDiff([]byte(tt.diff))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseUnifiedDiff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseUnifiedDiff([]byte("diff --git a a\n--- a\n+++ a\n@@ invalid @@\n")); err == nil {
+		t.Errorf("expected an error for an invalid hunk header")
+	}
+}
+
+func TestGetChangedLines(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q")
+	write("src/Main.java", "a\nb\nc\n")
+	write("my file.txt", "a\nb\nc\n")
+	write("Old.java", "1\n2\n3\n4\n")
+	write("gone.txt", "x\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "init")
+
+	write("src/Main.java", "a\nB\nc\nd\n")
+	write("my file.txt", "a\nc\n")
+	git("mv", "Old.java", "New.java")
+	write("New.java", "1\n2\nthree\n4\n")
+	git("rm", "-q", "gone.txt")
+	git("add", "-A")
+	write("src/Added.java", "a\nb")
+	write("empty.txt", "")
+	write(".gitignore", "*.log\n")
+	write("build.log", "ignored\n")
+
+	got, err := getChangedLines(context.TODO(), logr.Discard(), []string{dir}, "HEAD", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]engine.LineRange{
+		filepath.Join(dir, "src", "Main.java"): {{Start: 2, End: 2}, {Start: 4, End: 4}},
+		// Only lines were removed from the file.
+		filepath.Join(dir, "my file.txt"): nil,
+		filepath.Join(dir, "New.java"):    {{Start: 3, End: 3}},
+		// Files that are not tracked yet are new as a whole.
+		filepath.Join(dir, "src", "Added.java"): {{Start: 1, End: 2}},
+		filepath.Join(dir, "empty.txt"):         nil,
+		filepath.Join(dir, ".gitignore"):        {{Start: 1, End: 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getChangedLines() = %v, want %v", got, want)
+	}
+
+	if _, err := getChangedLines(context.TODO(), logr.Discard(), []string{t.TempDir()}, "HEAD", ""); err == nil {
+		t.Errorf("expected an error when no location is in a git repository")
+	}
+}
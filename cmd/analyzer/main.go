@@ -2,13 +2,18 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	logrusr "github.com/bombsimon/logrusr/v3"
 	"github.com/go-logr/logr"
@@ -29,6 +34,9 @@ import (
 
 const (
 	EXIT_ON_ERROR_CODE = 3
+
+	yamlOutputFormat  = "yaml"
+	sarifOutputFormat = "sarif"
 )
 
 var (
@@ -48,8 +56,28 @@ var (
 	noDependencyRules bool
 	contextLines      int
 	getOpenAPISpec    string
+	getJSONSchema     string
 	treeOutput        bool
 	depOutputFile     string
+	cacheDir          string
+	diffBase          string
+	diffHead          string
+	baselineFile      string
+	outputFormat      string
+	stats             bool
+	statsOutputFile   string
+	streamOutputFile  string
+	streamOnly        bool
+	snippetContext    string
+	snippetTrim       bool
+	snippetMarkRange  bool
+	groupIncidents    bool
+	effortOutputFile  string
+	excludeGenerated  bool
+	maxFileSize       string
+	depLabelScope     string
+	fixDiffFile       string
+	strict            bool
 )
 
 func AnalysisCmd() *cobra.Command {
@@ -83,8 +111,14 @@ func AnalysisCmd() *cobra.Command {
 			// This will globally prevent the yaml library from auto-wrapping lines at 80 characters
 			yaml.FutureLineWrap()
 
-			ctx, cancelFunc := context.WithCancel(context.Background())
+			// The first SIGINT or SIGTERM cancels the analysis and the partial output is
+			// written, a second one stops the process right away.
+			ctx, cancelFunc := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 			defer cancelFunc()
+			go func() {
+				<-ctx.Done()
+				cancelFunc()
+			}()
 
 			selectors := []engine.RuleSelector{}
 			if labelSelector != "" {
@@ -128,42 +162,19 @@ func AnalysisCmd() *cobra.Command {
 				os.Exit(1)
 			}
 
-			// we add builtin configs by default for all locations
-			defaultBuiltinConfigs := []provider.InitConfig{}
-			seenBuiltinConfigs := map[string]bool{}
-			finalConfigs := []provider.Config{}
-			for _, config := range configs {
-				if config.Name != "builtin" {
-					finalConfigs = append(finalConfigs, config)
-				}
-				for _, initConf := range config.InitConfig {
-					if _, ok := seenBuiltinConfigs[initConf.Location]; !ok {
-						if initConf.Location != "" {
-							if stat, err := os.Stat(initConf.Location); err == nil && stat.IsDir() {
-								builtinLocation, err := filepath.Abs(initConf.Location)
-								if err != nil {
-									builtinLocation = initConf.Location
-								}
-								seenBuiltinConfigs[builtinLocation] = true
-								builtinConf := provider.InitConfig{Location: builtinLocation}
-								if config.Name == "builtin" {
-									builtinConf.ProviderSpecificConfig = initConf.ProviderSpecificConfig
-								}
-								defaultBuiltinConfigs = append(defaultBuiltinConfigs, builtinConf)
-							}
-						}
-					}
-				}
-			}
-			finalConfigs = append(finalConfigs, provider.Config{
-				Name:       "builtin",
-				InitConfig: defaultBuiltinConfigs,
-			})
+			finalConfigs := withBuiltinConfig(configs)
 
 			providers := map[string]provider.InternalProviderClient{}
 			providerLocations := []string{}
+			providerTimeouts := map[string]time.Duration{}
+			providerCostHints := map[string]int{}
 			for _, config := range finalConfigs {
 				config.ContextLines = contextLines
+				providerCostHints[config.Name] = config.GetCostHint()
+				// GetConfig already validated the timeout
+				if timeout, _ := config.GetRuleTimeout(); timeout > 0 {
+					providerTimeouts[config.Name] = timeout
+				}
 				for _, ind := range config.InitConfig {
 					providerLocations = append(providerLocations, ind.Location)
 				}
@@ -190,16 +201,61 @@ func AnalysisCmd() *cobra.Command {
 				}
 			}
 
-			engineCtx, engineSpan := tracing.StartNewSpan(ctx, "rule-engine")
-			//start up the rule eng
-			eng := engine.CreateRuleEngine(engineCtx,
-				10,
-				log,
+			engineOptions := []engine.Option{
 				engine.WithIncidentLimit(limitIncidents),
 				engine.WithCodeSnipLimit(limitCodeSnips),
 				engine.WithContextLines(contextLines),
 				engine.WithIncidentSelector(incidentSelector),
 				engine.WithLocationPrefixes(providerLocations),
+				engine.WithSnippetOptions(engine.SnippetOptions{
+					Context:     engine.SnippetContext(snippetContext),
+					TrimPadding: snippetTrim,
+					MarkRange:   snippetMarkRange,
+				}),
+			}
+			if cacheDir != "" {
+				configHash, err := hashCacheConfig(finalConfigs)
+				if err != nil {
+					errLog.Error(err, "unable to hash provider configuration for the rule cache")
+					os.Exit(1)
+				}
+				cache, err := engine.NewFileRuleCache(cacheDir, configHash, providerLocations, log)
+				if err != nil {
+					errLog.Error(err, "unable to create rule cache", "dir", cacheDir)
+					os.Exit(1)
+				}
+				engineOptions = append(engineOptions, engine.WithRuleCache(cache))
+			}
+			if baselineFile != "" {
+				baseline, err := readBaseline(baselineFile)
+				if err != nil {
+					errLog.Error(err, "unable to read baseline", "file", baselineFile)
+					os.Exit(1)
+				}
+				engineOptions = append(engineOptions, engine.WithBaseline(baseline))
+			}
+			if stats || statsOutputFile != "" {
+				engineOptions = append(engineOptions, engine.WithRuleStats())
+			}
+			var sink *jsonLinesSink
+			if streamOutputFile != "" {
+				sink, err = newJSONLinesSink(streamOutputFile)
+				if err != nil {
+					errLog.Error(err, "unable to create stream output file", "file", streamOutputFile)
+					os.Exit(1)
+				}
+				engineOptions = append(engineOptions, engine.WithViolationSink(sink))
+				if streamOnly {
+					engineOptions = append(engineOptions, engine.WithSinkOnly())
+				}
+			}
+
+			engineCtx, engineSpan := tracing.StartNewSpan(ctx, "rule-engine")
+			//start up the rule eng
+			eng := engine.CreateRuleEngine(engineCtx,
+				10,
+				log,
+				engineOptions...,
 			)
 
 			if getOpenAPISpec != "" {
@@ -218,11 +274,30 @@ func AnalysisCmd() *cobra.Command {
 				os.Exit(0)
 			}
 
+			if getJSONSchema != "" {
+				b, err := json.MarshalIndent(parser.CreateJSONSchema(providers), "", "  ")
+				if err != nil {
+					errLog.Error(err, "unable to create rules schema")
+					os.Exit(1)
+				}
+
+				err = os.WriteFile(getJSONSchema, b, 0644)
+				if err != nil {
+					errLog.Error(err, "error writing output file", "file", getJSONSchema)
+					os.Exit(1)
+				}
+				stopProviders(providers)
+				os.Exit(0)
+			}
+
 			parser := parser.RuleParser{
 				ProviderNameToClient: providers,
 				Log:                  log.WithName("parser"),
 				NoDependencyRules:    noDependencyRules,
 				DepLabelSelector:     dependencyLabelSelector,
+				ProviderTimeouts:     providerTimeouts,
+				ProviderCostHints:    providerCostHints,
+				Strict:               strict,
 			}
 			ruleSets := []engine.RuleSet{}
 			needProviders := map[string]provider.InternalProviderClient{}
@@ -230,12 +305,27 @@ func AnalysisCmd() *cobra.Command {
 				internRuleSet, internNeedProviders, err := parser.LoadRules(f)
 				if err != nil {
 					errLog.Error(err, "unable to parse all the rules for ruleset", "file", f)
+					if strict {
+						stopProviders(providers)
+						os.Exit(1)
+					}
 				}
 				ruleSets = append(ruleSets, internRuleSet...)
 				for k, v := range internNeedProviders {
 					needProviders[k] = v
 				}
 			}
+			if err := engine.ValidateRuleDependencies(ruleSets); err != nil {
+				errLog.Error(err, "invalid rule dependencies")
+				stopProviders(providers)
+				os.Exit(1)
+			}
+			supersedes, err := engine.ResolveSupersedes(ruleSets)
+			if err != nil {
+				errLog.Error(err, "invalid superseded rules")
+				stopProviders(providers)
+				os.Exit(1)
+			}
 			// Now that we have all the providers, we need to start them.
 			additionalBuiltinConfigs := []provider.InitConfig{}
 			for name, provider := range needProviders {
@@ -250,6 +340,7 @@ func AnalysisCmd() *cobra.Command {
 					additionalBuiltinConfs, err := provider.ProviderInit(initCtx, nil)
 					if err != nil {
 						errLog.Error(err, "unable to init the providers", "provider", name)
+						stopProviders(providers)
 						os.Exit(1)
 					}
 					if additionalBuiltinConfs != nil {
@@ -262,6 +353,7 @@ func AnalysisCmd() *cobra.Command {
 			if builtinClient, ok := needProviders["builtin"]; ok {
 				if _, err = builtinClient.ProviderInit(ctx, additionalBuiltinConfigs); err != nil {
 					errLog.Error(err, "unable to init builtin provider")
+					stopProviders(providers)
 					os.Exit(1)
 				}
 			}
@@ -275,41 +367,144 @@ func AnalysisCmd() *cobra.Command {
 				go DependencyOutput(depCtx, providers, log, errLog, depOutputFile, wg)
 			}
 
+			scopes := []engine.Scope{}
+			if diffBase != "" {
+				changedLines, err := getChangedLines(ctx, log, providerLocations, diffBase, diffHead)
+				if err != nil {
+					errLog.Error(err, "unable to get changed lines", "base", diffBase, "head", diffHead)
+					stopProviders(providers)
+					os.Exit(1)
+				}
+				log.Info("limiting analysis to changed lines", "base", diffBase, "head", diffHead, "files", len(changedLines))
+				scopes = append(scopes, engine.IncludedLinesScope(changedLines, log))
+			}
+			if excludeGenerated {
+				scopes = append(scopes, engine.GeneratedCodeScope(nil, log))
+			}
+			if maxFileSize != "" {
+				// validateFlags already parsed the size
+				size, _ := parseFileSize(maxFileSize)
+				scopes = append(scopes, engine.MaxFileSizeScope(size, log))
+			}
+			if depLabelScope != "" {
+				depScope, err := dependencyLabelScope(ctx, depLabelScope, needProviders, log)
+				if err != nil {
+					errLog.Error(err, "unable to create dependency label scope", "selector", depLabelScope)
+					stopProviders(providers)
+					os.Exit(1)
+				}
+				scopes = append(scopes, depScope)
+			}
+			var scope engine.Scope
+			if len(scopes) > 0 {
+				scope = engine.NewScope(scopes...)
+			}
+
 			// This will already wait
-			rulesets := eng.RunRules(ctx, ruleSets, selectors...)
+			rulesets := eng.RunRulesScoped(ctx, ruleSets, scope, selectors...)
 			engineSpan.End()
+			if sink != nil {
+				if err := sink.Close(); err != nil {
+					errLog.Error(err, "error writing stream output file", "file", streamOutputFile)
+				}
+			}
 			wg.Wait()
 			if depSpan != nil {
 				depSpan.End()
 			}
 			eng.Stop()
 
-			for _, provider := range needProviders {
-				provider.Stop()
-			}
+			stopProviders(providers)
 
 			sort.SliceStable(rulesets, func(i, j int) bool {
 				return rulesets[i].Name < rulesets[j].Name
 			})
+			if len(supersedes) > 0 || groupIncidents {
+				konveyor.GroupIncidents(rulesets, supersedes, groupIncidents)
+			}
+
+			// The stats of the rules are only in the output when asked for.
+			statsReport := konveyor.NewStatsReport(rulesets)
+			if !stats {
+				for i := range rulesets {
+					rulesets[i].Stats = nil
+				}
+			}
+
+			// The reports are written after the output, a report that can not
+			// be written does not keep the output from being written.
+			writeReports := func() bool {
+				written := true
+				if statsOutputFile != "" {
+					b, err := yaml.Marshal(statsReport)
+					if err == nil {
+						err = os.WriteFile(statsOutputFile, b, 0644)
+					}
+					if err != nil {
+						errLog.Error(err, "error writing stats file", "file", statsOutputFile)
+						written = false
+					}
+				}
+				if fixDiffFile != "" {
+					diff, skipped, err := konveyor.UnifiedDiff(konveyor.Edits(rulesets), os.ReadFile)
+					if len(skipped) > 0 {
+						log.Info("skipped fixes that overlap other fixes", "count", len(skipped))
+					}
+					if err == nil {
+						err = os.WriteFile(fixDiffFile, []byte(diff), 0644)
+					}
+					if err != nil {
+						errLog.Error(err, "error writing fix diff file", "file", fixDiffFile)
+						written = false
+					}
+				}
+				if effortOutputFile != "" {
+					b, err := yaml.Marshal(konveyor.NewEffortReport(rulesets, providerLocations))
+					if err == nil {
+						err = os.WriteFile(effortOutputFile, b, 0644)
+					}
+					if err != nil {
+						errLog.Error(err, "error writing effort report file", "file", effortOutputFile)
+						written = false
+					}
+				}
+				return written
+			}
 
 			// Write results out to CLI
 			b, _ := yaml.Marshal(rulesets)
-			if errorOnViolations && len(rulesets) != 0 {
+			if errorOnViolations && hasViolations(rulesets) {
 				fmt.Printf("%s", string(b))
+				writeReports()
 				os.Exit(EXIT_ON_ERROR_CODE)
 			}
 
+			if outputFormat == sarifOutputFormat {
+				b, err = konveyor.MarshalSARIF(rulesets, providerLocations)
+				if err != nil {
+					errLog.Error(err, "unable to marshal rulesets as SARIF")
+					os.Exit(1)
+				}
+			}
 			err = os.WriteFile(outputViolations, b, 0644)
 			if err != nil {
 				errLog.Error(err, "error writing output file", "file", outputViolations)
 				os.Exit(1) // Treat the error as a fatal error
 			}
+			if !writeReports() {
+				os.Exit(1)
+			}
+			if ctx.Err() != nil {
+				errLog.Error(ctx.Err(), "analysis was interrupted, the output is incomplete", "file", outputViolations)
+				os.Exit(1)
+			}
 		},
 	}
 
 	rootCmd.Flags().StringVar(&settingsFile, "provider-settings", "provider_settings.json", "path to the provider settings")
-	rootCmd.Flags().StringArrayVar(&rulesFile, "rules", []string{"rule-example.yaml"}, "filename or directory containing rule files")
+	rootCmd.Flags().StringArrayVar(&rulesFile, "rules", []string{"rule-example.yaml"}, "filename, directory or bundle (.zip, .tar.gz or OCI layout) containing rule files")
 	rootCmd.Flags().StringVar(&outputViolations, "output-file", "output.yaml", "filepath to to store rule violations")
+	rootCmd.Flags().StringVar(&outputFormat, "output-format", yamlOutputFormat, fmt.Sprintf("format of the output file, one of %s or %s", yamlOutputFormat, sarifOutputFormat))
 	rootCmd.Flags().BoolVar(&errorOnViolations, "error-on-violation", false, "exit with 3 if any violation are found will also print violations to console")
 	rootCmd.Flags().StringVar(&labelSelector, "label-selector", "", "an expression to select rules based on labels")
 	rootCmd.Flags().StringVar(&depLabelSelector, "dep-label-selector", "", "an expression to select dependencies based on labels. This will filter out the violations from these dependencies as well these dependencies when matching dependency conditions")
@@ -322,9 +517,31 @@ func AnalysisCmd() *cobra.Command {
 	rootCmd.Flags().StringVar(&analysisMode, "analysis-mode", "", "select one of full or source-only to tell the providers what to analyize. This can be given on a per provider setting, but this flag will override")
 	rootCmd.Flags().BoolVar(&noDependencyRules, "no-dependency-rules", false, "Disable dependency analysis rules")
 	rootCmd.Flags().IntVar(&contextLines, "context-lines", 10, "When violation occurs, A part of source code is added to the output, So this flag configures the number of source code lines to be printed to the output.")
+	rootCmd.Flags().StringVar(&snippetContext, "snippet-context", string(engine.LinesSnippetContext), fmt.Sprintf("lines of code shown around incidents, one of %s to show --context-lines lines or %s to show the enclosing function or XML element", engine.LinesSnippetContext, engine.EnclosingSnippetContext))
+	rootCmd.Flags().BoolVar(&snippetTrim, "snippet-trim", false, "drop blank lines at the start and end of code snippets")
+	rootCmd.Flags().BoolVar(&snippetMarkRange, "snippet-mark-range", false, "mark the characters of incidents in code snippets with a line of carets")
 	rootCmd.Flags().StringVar(&getOpenAPISpec, "get-openapi-spec", "", "Get the openAPI spec for the rulesets, rules and provider capabilities and put in file passed in.")
+	rootCmd.Flags().StringVar(&getJSONSchema, "get-json-schema", "", "path to write a JSON Schema of rule files and rulesets to, with the capabilities of the configured providers, for editors to validate and complete rules")
 	rootCmd.Flags().BoolVar(&treeOutput, "tree", false, "output dependencies as a tree")
 	rootCmd.Flags().StringVar(&depOutputFile, "dep-output-file", "", "path to dependency output file")
+	rootCmd.Flags().StringVar(&diffBase, "diff-base", "", "git revision to compare against, only incidents on lines changed since this revision are reported")
+	rootCmd.Flags().StringVar(&diffHead, "diff-head", "", "git revision to compare with --diff-base, defaults to the working tree")
+	rootCmd.Flags().StringVar(&baselineFile, "baseline", "", "output file of an earlier run, incidents found in it are not reported again")
+	rootCmd.Flags().StringVar(&streamOutputFile, "stream-output-file", "", "path to write violations to as JSON Lines while the rules are evaluated, one line per violation")
+	rootCmd.Flags().BoolVar(&streamOnly, "stream-only", false, "only write violations to the stream output file, the output file has the rulesets without their violations and insights so that they are not held in memory")
+	rootCmd.Flags().BoolVar(&stats, "stats", false, "add how long each rule took to evaluate, its provider calls and incident counts to the output")
+	rootCmd.Flags().StringVar(&statsOutputFile, "stats-output-file", "", "path to write a report of how long each rule took to evaluate, slowest rules first")
+	rootCmd.Flags().StringVar(&effortOutputFile, "effort-output-file", "", "path to write the effort of the violations to, in total and by category, ruleset, label and top-level directory")
+	rootCmd.Flags().BoolVar(&groupIncidents, "group-incidents", false, "list the other rules that found an incident at the same location in the relatedRules of each incident")
+	rootCmd.Flags().BoolVar(&excludeGenerated, "exclude-generated-code", false, "do not report incidents in files with a generated code marker, the \"// Code generated ... DO NOT EDIT.\" comment or the @Generated annotation, in their first lines")
+	rootCmd.Flags().StringVar(&maxFileSize, "max-file-size", "", "do not report incidents in files larger than this size, such as 512KB or 2MB")
+	rootCmd.Flags().StringVar(&depLabelScope, "dep-label-scope", "", "an expression to select dependencies based on labels, incidents of any condition in the files of dependencies that do not match it are left out of the output. Unlike --dep-label-selector, it applies to the incidents of every condition once a rule is evaluated and does not change the dependencies that dependency conditions match")
+	rootCmd.Flags().StringVar(&fixDiffFile, "fix-diff-file", "", "path to write the edits suggested by the fixes of rules to as a unified diff")
+	rootCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "directory to cache rule results in, rules that did not change since the last run reuse their results when the files they read did not change")
+	rootCmd.Flags().BoolVar(&strict, "strict", false, "stop without running the rules when any of them has a problem that is otherwise skipped, such as an unknown field, category or label")
+
+	rootCmd.AddCommand(ValidateCmd())
+	rootCmd.AddCommand(RuleTestCmd())
 
 	return rootCmd
 }
@@ -337,6 +554,56 @@ func main() {
 	}
 }
 
+// withBuiltinConfig returns the provider configs with the builtin provider
+// configured for the locations of every provider, so that builtin conditions
+// can be used on all of them.
+func withBuiltinConfig(configs []provider.Config) []provider.Config {
+	// we add builtin configs by default for all locations
+	defaultBuiltinConfigs := []provider.InitConfig{}
+	seenBuiltinConfigs := map[string]bool{}
+	finalConfigs := []provider.Config{}
+	builtinRuleTimeout := ""
+	for _, config := range configs {
+		if config.Name != "builtin" {
+			finalConfigs = append(finalConfigs, config)
+		} else {
+			builtinRuleTimeout = config.RuleTimeout
+		}
+		for _, initConf := range config.InitConfig {
+			if _, ok := seenBuiltinConfigs[initConf.Location]; !ok {
+				if initConf.Location != "" {
+					if stat, err := os.Stat(initConf.Location); err == nil && stat.IsDir() {
+						builtinLocation, err := filepath.Abs(initConf.Location)
+						if err != nil {
+							builtinLocation = initConf.Location
+						}
+						seenBuiltinConfigs[builtinLocation] = true
+						builtinConf := provider.InitConfig{Location: builtinLocation}
+						if config.Name == "builtin" {
+							builtinConf.ProviderSpecificConfig = initConf.ProviderSpecificConfig
+						}
+						defaultBuiltinConfigs = append(defaultBuiltinConfigs, builtinConf)
+					}
+				}
+			}
+		}
+	}
+	finalConfigs = append(finalConfigs, provider.Config{
+		Name:        "builtin",
+		InitConfig:  defaultBuiltinConfigs,
+		RuleTimeout: builtinRuleTimeout,
+	})
+	return finalConfigs
+}
+
+// stopProviders stops every provider that was created, whether it was used by
+// the rules or not, so that no provider or language server is left running.
+func stopProviders(providers map[string]provider.InternalProviderClient) {
+	for _, p := range providers {
+		p.Stop()
+	}
+}
+
 func validateFlags() error {
 	_, err := os.Stat(settingsFile)
 	if err != nil {
@@ -351,6 +618,38 @@ func validateFlags() error {
 			}
 		}
 	}
+	if baselineFile != "" {
+		if _, err := os.Stat(baselineFile); err != nil {
+			return fmt.Errorf("unable to find baseline file")
+		}
+	}
+	if outputFormat != yamlOutputFormat && outputFormat != sarifOutputFormat {
+		return fmt.Errorf("must select one of %s or %s for output format", yamlOutputFormat, sarifOutputFormat)
+	}
+	if c := engine.SnippetContext(snippetContext); c != engine.LinesSnippetContext && c != engine.EnclosingSnippetContext {
+		return fmt.Errorf("must select one of %s or %s for snippet context", engine.LinesSnippetContext, engine.EnclosingSnippetContext)
+	}
+	if maxFileSize != "" {
+		if _, err := parseFileSize(maxFileSize); err != nil {
+			return fmt.Errorf("invalid max file size: %w", err)
+		}
+	}
+	if depLabelScope != "" {
+		if _, err := labels.NewLabelSelector[*konveyor.Dep](depLabelScope, nil); err != nil {
+			return fmt.Errorf("invalid dependency label scope: %w", err)
+		}
+	}
+	if streamOnly {
+		if streamOutputFile == "" {
+			return fmt.Errorf("--stream-only can only be used with --stream-output-file")
+		}
+		if errorOnViolations || groupIncidents || fixDiffFile != "" || effortOutputFile != "" {
+			return fmt.Errorf("--stream-only can not be used with --error-on-violation, --group-incidents, --fix-diff-file or --effort-output-file, they need the violations in the output")
+		}
+	}
+	if diffHead != "" && diffBase == "" {
+		return fmt.Errorf("--diff-head can only be used with --diff-base")
+	}
 	m := provider.AnalysisMode(strings.ToLower(analysisMode))
 	if analysisMode != "" && !(m == provider.FullAnalysisMode || m == provider.SourceOnlyAnalysisMode) {
 		return fmt.Errorf("must select one of %s or %s for analysis mode", provider.FullAnalysisMode, provider.SourceOnlyAnalysisMode)
@@ -359,6 +658,51 @@ func validateFlags() error {
 	return nil
 }
 
+// readBaseline reads the rulesets written to the output file of an earlier run.
+func readBaseline(path string) ([]konveyor.RuleSet, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ruleSets := []konveyor.RuleSet{}
+	if err := yaml.Unmarshal(content, &ruleSets); err != nil {
+		return nil, err
+	}
+	return ruleSets, nil
+}
+
+func hasViolations(ruleSets []konveyor.RuleSet) bool {
+	for _, rs := range ruleSets {
+		for _, violation := range rs.Violations {
+			if len(violation.Incidents) != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hashCacheConfig identifies everything outside of the rules themselves
+// that changes the result of evaluating a rule.
+func hashCacheConfig(configs []provider.Config) (string, error) {
+	b, err := json.Marshal(struct {
+		Configs           []provider.Config `json:"configs"`
+		AnalysisMode      string            `json:"analysisMode"`
+		NoDependencyRules bool              `json:"noDependencyRules"`
+		DepLabelSelector  string            `json:"depLabelSelector"`
+	}{
+		Configs:           configs,
+		AnalysisMode:      analysisMode,
+		NoDependencyRules: noDependencyRules,
+		DepLabelSelector:  depLabelSelector,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
 func createOpenAPISchema(providers map[string]provider.InternalProviderClient, log logr.Logger) openapi3.Spec {
 
 	// in the future loop and build the openapi spec here:
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	logrusr "github.com/bombsimon/logrusr/v3"
+	"github.com/konveyor/analyzer-lsp/provider"
+	"github.com/konveyor/analyzer-lsp/ruletest"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// RuleTestCmd runs the tests of rules, the incidents that each rule must find
+// in a source fixture, and reports which rules pass and fail.
+func RuleTestCmd() *cobra.Command {
+	var testSettingsFile string
+	var testLogLevel int
+
+	testCmd := &cobra.Command{
+		Use:          "test [tests file or directory]...",
+		Short:        "Run rules against source fixtures and compare their incidents with the expected incidents",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			logrusLog := logrus.New()
+			logrusLog.SetOutput(os.Stderr)
+			logrusLog.SetLevel(logrus.Level(testLogLevel))
+			log := logrusr.New(logrusLog)
+
+			runner := ruletest.Runner{Log: log}
+			if testSettingsFile != "" {
+				configs, err := provider.GetConfig(testSettingsFile)
+				if err != nil {
+					return fmt.Errorf("unable to get configuration: %w", err)
+				}
+				runner.ProviderConfigs = configs
+			}
+
+			passed, failed := 0, 0
+			for _, arg := range args {
+				files, err := ruletest.FindTestsFiles(arg)
+				if err != nil {
+					return err
+				}
+				for _, file := range files {
+					results, err := runner.Run(c.Context(), file)
+					if err != nil {
+						return err
+					}
+					for _, result := range results {
+						fmt.Fprintln(c.OutOrStdout(), result.String())
+						if result.Passed() {
+							passed++
+						} else {
+							failed++
+						}
+					}
+				}
+			}
+			fmt.Fprintf(c.OutOrStdout(), "%d passed, %d failed\n", passed, failed)
+			if failed > 0 {
+				return fmt.Errorf("%d rule tests failed", failed)
+			}
+			return nil
+		},
+	}
+	testCmd.Flags().StringVar(&testSettingsFile, "provider-settings", "", "path to the provider settings, the builtin provider is used when it is not set")
+	testCmd.Flags().IntVar(&testLogLevel, "verbose", 0, "level for logging output")
+
+	return testCmd
+}
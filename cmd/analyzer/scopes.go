@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/konveyor/analyzer-lsp/engine"
+	"github.com/konveyor/analyzer-lsp/engine/labels"
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+	"github.com/konveyor/analyzer-lsp/provider"
+)
+
+var fileSizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{suffix: "GB", size: 1 << 30},
+	{suffix: "MB", size: 1 << 20},
+	{suffix: "KB", size: 1 << 10},
+	{suffix: "G", size: 1 << 30},
+	{suffix: "M", size: 1 << 20},
+	{suffix: "K", size: 1 << 10},
+	{suffix: "B", size: 1},
+}
+
+// parseFileSize parses a number of bytes with an optional unit such as 512KB or 2MB.
+func parseFileSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	unit := int64(1)
+	for _, u := range fileSizeUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, u.suffix))
+			unit = u.size
+			break
+		}
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size <= 0 || size > math.MaxInt64/unit {
+		return 0, fmt.Errorf("file size must be a number of bytes such as 512KB or 2MB")
+	}
+	return size * unit, nil
+}
+
+// dependencyLabelScope gets the dependencies of the providers so that incidents
+// in their files can be filtered by the labels of the dependencies.
+func dependencyLabelScope(ctx context.Context, expr string, providers map[string]provider.InternalProviderClient, log logr.Logger) (engine.Scope, error) {
+	selector, err := labels.NewLabelSelector[*konveyor.Dep](expr, nil)
+	if err != nil {
+		return nil, err
+	}
+	deps := []*konveyor.Dep{}
+	for name, prov := range providers {
+		if !provider.HasCapability(prov.Capabilities(), "dependency") {
+			continue
+		}
+		providerDeps, err := prov.GetDependencies(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get dependencies of provider %s: %w", name, err)
+		}
+		for _, ds := range providerDeps {
+			deps = append(deps, ds...)
+		}
+	}
+	return engine.DependencyLabelScope(selector, deps, log), nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseFileSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "100", want: 100},
+		{value: "512B", want: 512},
+		{value: "512KB", want: 512 << 10},
+		{value: "512K", want: 512 << 10},
+		{value: "2MB", want: 2 << 20},
+		{value: "2m", want: 2 << 20},
+		{value: " 2 mb ", want: 2 << 20},
+		{value: "1GB", want: 1 << 30},
+		{value: "1G", want: 1 << 30},
+		{value: "", wantErr: true},
+		{value: "MB", wantErr: true},
+		{value: "0", wantErr: true},
+		{value: "-1KB", wantErr: true},
+		{value: "1.5MB", wantErr: true},
+		{value: "2TB", wantErr: true},
+		{value: "9999999999GB", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseFileSize(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFileSize(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseFileSize(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+)
+
+// streamRecord is a line of the streamed output.
+type streamRecord struct {
+	RuleSet   string             `json:"ruleSet"`
+	RuleID    string             `json:"ruleID"`
+	Insight   bool               `json:"insight,omitempty"`
+	Violation konveyor.Violation `json:"violation"`
+}
+
+// jsonLinesSink writes every violation to a file as a single line of JSON as
+// soon as it is produced, so the file can be read while the analysis runs.
+type jsonLinesSink struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func newJSONLinesSink(path string) (*jsonLinesSink, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonLinesSink{
+		file:    file,
+		encoder: json.NewEncoder(file),
+	}, nil
+}
+
+func (s *jsonLinesSink) Write(ruleSet string, ruleID string, violation konveyor.Violation, insight bool) error {
+	// Encode writes the line with a single write, readers never see part of a line.
+	return s.encoder.Encode(streamRecord{
+		RuleSet:   ruleSet,
+		RuleID:    ruleID,
+		Insight:   insight,
+		Violation: violation,
+	})
+}
+
+func (s *jsonLinesSink) Close() error {
+	return s.file.Close()
+}
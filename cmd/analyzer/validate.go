@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	logrusr "github.com/bombsimon/logrusr/v3"
+	"github.com/konveyor/analyzer-lsp/parser"
+	"github.com/konveyor/analyzer-lsp/provider"
+	"github.com/konveyor/analyzer-lsp/provider/lib"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// ValidateCmd checks rules without running them, every problem is printed with
+// the file, line and column it was found at.
+func ValidateCmd() *cobra.Command {
+	var validateSettingsFile string
+	var validateRulesFiles []string
+	var validateLogLevel int
+	var validateSkipProviders []string
+
+	validateCmd := &cobra.Command{
+		Use:          "validate",
+		Short:        "Validate rules and report every problem with its file, line and column",
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			logrusLog := logrus.New()
+			logrusLog.SetOutput(os.Stderr)
+			logrusLog.SetLevel(logrus.Level(validateLogLevel))
+			log := logrusr.New(logrusLog)
+
+			configs, err := provider.GetConfig(validateSettingsFile)
+			if err != nil {
+				return fmt.Errorf("unable to get configuration: %w", err)
+			}
+
+			// The capabilities that rules can use are asked of the providers
+			// that are already running at an address, providers that would have
+			// to be started from their binary are skipped like the skipped ones
+			// and their conditions are not checked.
+			skipped := append([]string{}, validateSkipProviders...)
+			providers := map[string]provider.InternalProviderClient{}
+			defer stopProviders(providers)
+			for _, config := range withBuiltinConfig(configs) {
+				if slices.Contains(skipped, config.Name) {
+					continue
+				}
+				if config.Name != "builtin" && config.BinaryPath != "" {
+					log.V(1).Info("not checking the conditions of a provider that would have to be started", "provider", config.Name)
+					skipped = append(skipped, config.Name)
+					continue
+				}
+				prov, err := lib.GetProviderClient(config, log)
+				if err != nil {
+					return fmt.Errorf("unable to create provider client %s: %w", config.Name, err)
+				}
+				providers[config.Name] = prov
+			}
+
+			ruleParser := parser.RuleParser{
+				ProviderNameToClient: providers,
+				Log:                  log.WithName("parser"),
+				SkippedProviders:     skipped,
+			}
+			problems := 0
+			for _, f := range validateRulesFiles {
+				diagnostics, err := ruleParser.ValidateRules(f)
+				if err != nil {
+					return err
+				}
+				for _, d := range diagnostics {
+					fmt.Fprintln(c.OutOrStdout(), d.String())
+				}
+				problems += len(diagnostics)
+			}
+			if problems > 0 {
+				return fmt.Errorf("found %d problems in rules", problems)
+			}
+			return nil
+		},
+	}
+	validateCmd.Flags().StringVar(&validateSettingsFile, "provider-settings", "provider_settings.json", "path to the provider settings")
+	validateCmd.Flags().StringArrayVar(&validateRulesFiles, "rules", []string{"rule-example.yaml"}, "filename, directory or bundle (.zip, .tar.gz or OCI layout) containing rule files")
+	validateCmd.Flags().StringArrayVar(&validateSkipProviders, "skip-providers", []string{}, "providers that are not created, the capabilities of their conditions are not checked")
+	validateCmd.Flags().IntVar(&validateLogLevel, "verbose", 0, "level for logging output")
+
+	return validateCmd
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateCmd(t *testing.T) {
+	dir := t.TempDir()
+	settings := filepath.Join(dir, "provider_settings.json")
+	// The binary of the java provider does not exist, validating the rules
+	// must not start it.
+	if err := os.WriteFile(settings, []byte(`[
+  {"name": "java", "binaryPath": "`+filepath.Join(dir, "java-provider")+`", "initConfig": [{"location": "`+dir+`"}]},
+  {"name": "builtin", "initConfig": [{"location": "`+dir+`"}]}
+]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules := filepath.Join(dir, "rules.yaml")
+	if err := os.WriteFile(rules, []byte(`- ruleID: java-001
+  message: EJB remoting
+  when:
+    or:
+      - java.referenced:
+          pattern: javax.ejb.Remote
+      - builtin.filecontent:
+          pattern: Remote
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	invalidRules := filepath.Join(dir, "invalid-rules.yaml")
+	if err := os.WriteFile(invalidRules, []byte(`- ruleID: builtin-001
+  message: EJB remoting
+  when:
+    builtin.referenced:
+      pattern: javax.ejb.Remote
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{
+			name: "provider that would have to be started",
+			args: []string{"--provider-settings", settings, "--rules", rules},
+		},
+		{
+			name: "skipped provider",
+			args: []string{"--provider-settings", settings, "--rules", rules, "--skip-providers", "java"},
+		},
+		{
+			name:    "unknown capability of a provider that is checked",
+			args:    []string{"--provider-settings", settings, "--rules", invalidRules},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			cmd := ValidateCmd()
+			cmd.SetArgs(tt.args)
+			cmd.SetOut(out)
+			cmd.SetErr(out)
+			err := cmd.Execute()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate error = %v, want error %v: %s", err, tt.wantErr, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+	"go.lsp.dev/uri"
+)
+
+// baseline holds the fingerprints of incidents that were already reported by an
+// earlier run. Fingerprints are counted so that a new incident matching the same
+// line in the same file as a known one is still reported.
+type baseline struct {
+	mutex  sync.Mutex
+	counts map[string]int
+}
+
+func newBaseline(ruleSets []konveyor.RuleSet) *baseline {
+	b := &baseline{counts: map[string]int{}}
+	for _, rs := range ruleSets {
+		for _, violations := range []map[string]konveyor.Violation{rs.Violations, rs.Insights} {
+			for ruleID, violation := range violations {
+				for _, incident := range violation.Incidents {
+					b.counts[incident.Fingerprint(ruleID)]++
+				}
+			}
+		}
+	}
+	return b
+}
+
+// known reports whether the incident is part of the baseline, consuming one
+// occurrence of its fingerprint when it is.
+func (b *baseline) known(ruleID string, incident konveyor.Incident) bool {
+	if b == nil {
+		return false
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	fingerprint := incident.Fingerprint(ruleID)
+	if b.counts[fingerprint] == 0 {
+		return false
+	}
+	b.counts[fingerprint]--
+	return true
+}
+
+// lineHash returns the hash of the line of the incident read from its file.
+// Incidents that are not on a line of a local file have none.
+func (r *ruleEngine) lineHash(m IncidentContext) string {
+	if m.LineNumber == nil || *m.LineNumber < 1 || !strings.HasPrefix(string(m.FileURI), uri.FileScheme) {
+		return ""
+	}
+	lines, err := r.files.window(m.FileURI.Filename(), *m.LineNumber-1, *m.LineNumber-1)
+	if err != nil || len(lines) == 0 {
+		r.logger.V(6).Info("unable to read the line of the incident", "file", m.FileURI, "line", *m.LineNumber)
+		return ""
+	}
+	return konveyor.LineHash(lines[0])
+}
@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+	"go.lsp.dev/uri"
+)
+
+func TestBaseline(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "Main.java")
+	message := "EJB found"
+	rule := Rule{
+		RuleMeta: RuleMeta{RuleID: "ejb-00001"},
+		Perform:  Perform{Message: Message{Text: &message}},
+	}
+	incidentAt := func(line int) IncidentContext {
+		lineNumber := line
+		return IncidentContext{
+			FileURI:    uri.File(source),
+			LineNumber: &lineNumber,
+			CodeLocation: &Location{
+				StartPosition: Position{Line: line - 1},
+				EndPosition:   Position{Line: line - 1},
+			},
+			Variables: map[string]interface{}{},
+		}
+	}
+
+	err := os.WriteFile(source, []byte("import javax.ejb.Stateless;\nclass Main {}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &ruleEngine{logger: logr.Discard(), contextLines: 1}
+	known, err := r.createViolation(context.TODO(), ConditionResponse{
+		Matched:   true,
+		Incidents: []IncidentContext{incidentAt(1)},
+	}, rule, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Shift the known incident down and add a new one.
+	err = os.WriteFile(source, []byte("package app;\n\nimport javax.ejb.Stateless;\nimport javax.ejb.Singleton;\nclass Main {}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.baseline = newBaseline([]konveyor.RuleSet{
+		{
+			Name:       "ejb",
+			Violations: map[string]konveyor.Violation{rule.RuleID: known},
+		},
+	})
+	violation, err := r.createViolation(context.TODO(), ConditionResponse{
+		Matched:   true,
+		Incidents: []IncidentContext{incidentAt(3), incidentAt(4)},
+	}, rule, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violation.Incidents) != 1 || *violation.Incidents[0].LineNumber != 4 {
+		t.Fatalf("expected only the new incident on line 4 to be reported, got %#v", violation.Incidents)
+	}
+}
+
+func TestBaselineCodeSnipLimit(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "Main.java")
+	err := os.WriteFile(source, []byte("import javax.ejb.Stateless;\nimport javax.ejb.Singleton;\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := "EJB found"
+	rule := Rule{
+		RuleMeta: RuleMeta{RuleID: "ejb-00001"},
+		Perform:  Perform{Message: Message{Text: &message}},
+	}
+	response := func() ConditionResponse {
+		incidents := []IncidentContext{}
+		for _, line := range []int{1, 2} {
+			lineNumber := line
+			incidents = append(incidents, IncidentContext{
+				FileURI:      uri.File(source),
+				LineNumber:   &lineNumber,
+				CodeLocation: &Location{StartPosition: Position{Line: line - 1}, EndPosition: Position{Line: line - 1}},
+				Variables:    map[string]interface{}{},
+			})
+		}
+		return ConditionResponse{Matched: true, Incidents: incidents}
+	}
+	run := func(codeSnipLimit int, baseline []konveyor.RuleSet) konveyor.Violation {
+		r := &ruleEngine{logger: logr.Discard(), codeSnipLimit: codeSnipLimit}
+		if baseline != nil {
+			r.baseline = newBaseline(baseline)
+		}
+		violation, err := r.createViolation(context.TODO(), response(), rule, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return violation
+	}
+	known := []konveyor.RuleSet{{
+		Name:       "ejb",
+		Violations: map[string]konveyor.Violation{rule.RuleID: run(1, nil)},
+	}}
+
+	incidents := known[0].Violations[rule.RuleID].Incidents
+	if incidents[0].LineHash != "" || incidents[1].LineHash != konveyor.LineHash("import javax.ejb.Singleton;") {
+		t.Errorf("expected only the incident without a code snip to have the hash of its line, got %#v", incidents)
+	}
+	if violation := run(1, known); len(violation.Incidents) != 0 {
+		t.Errorf("expected no incidents with the code snip limit of the baseline, got %#v", violation.Incidents)
+	}
+	// The second incident had no code snip in the baseline, it is known by the
+	// hash of its line.
+	if violation := run(0, known); len(violation.Incidents) != 0 {
+		t.Errorf("expected no incidents without the code snip limit of the baseline, got %#v", violation.Incidents)
+	}
+}
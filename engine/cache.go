@@ -0,0 +1,290 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"go.lsp.dev/uri"
+	"gopkg.in/yaml.v2"
+)
+
+// RuleCache stores the result of evaluating a rule so that later runs can
+// skip going to the providers when nothing the rule depends on has changed.
+type RuleCache interface {
+	// Get returns a previously stored response for the rule evaluated with the given context.
+	Get(rule Rule, condCtx ConditionContext) (ConditionResponse, bool)
+	Put(rule Rule, condCtx ConditionContext, response ConditionResponse) error
+}
+
+type ruleCacheEntry struct {
+	RuleID string `json:"ruleID"`
+	// Scope is the hash of the files in the locations the providers analyze
+	// that the conditions of the rule read, all of them unless the conditions
+	// tell which files they read. A file of the scope that is added or changed
+	// can match a rule that matched nothing before.
+	Scope string `json:"scope"`
+	// Files maps every file the rule reported an incident in, to the hash of
+	// its content at the time of evaluation. These can be outside of the
+	// locations, such as the sources of dependencies.
+	Files    map[string]string `json:"files"`
+	Response ConditionResponse `json:"response"`
+}
+
+type fileRuleCache struct {
+	dir        string
+	configHash string
+	locations  []string
+	log        logr.Logger
+
+	mutex      sync.RWMutex
+	fileHashes map[string]string
+
+	filesOnce sync.Once
+	files     []string
+	filesErr  error
+}
+
+var _ RuleCache = &fileRuleCache{}
+
+// NewFileRuleCache creates a content addressed rule cache stored in dir.
+// configHash must identify the provider configuration used for the run,
+// results created with a different configuration are never returned.
+//
+// An entry is keyed by the hash of the rule definition, the provider
+// configuration and the tags and scopes the rule was evaluated with. It is
+// only reused when none of the files in locations, the files and directories
+// the providers analyze, that the rule reads were added, removed or changed and
+// the files the rule reported incidents in are unchanged. Conditions implement
+// FileScoper to tell which files they read, a rule with a condition that does
+// not reads every file of the locations.
+func NewFileRuleCache(dir string, configHash string, locations []string, log logr.Logger) (RuleCache, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create cache directory %s: %w", dir, err)
+	}
+	return &fileRuleCache{
+		dir:        dir,
+		configHash: configHash,
+		locations:  locations,
+		log:        log.WithName("rule-cache"),
+		fileHashes: map[string]string{},
+	}, nil
+}
+
+func (f *fileRuleCache) Get(rule Rule, condCtx ConditionContext) (ConditionResponse, bool) {
+	key, ok := f.key(rule, condCtx)
+	if !ok {
+		return ConditionResponse{}, false
+	}
+	content, err := os.ReadFile(f.entryPath(key))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			f.log.V(5).Error(err, "unable to read cache entry", "ruleID", rule.RuleID)
+		}
+		return ConditionResponse{}, false
+	}
+	entry := ruleCacheEntry{}
+	err = json.Unmarshal(content, &entry)
+	if err != nil {
+		f.log.V(5).Error(err, "unable to parse cache entry", "ruleID", rule.RuleID)
+		return ConditionResponse{}, false
+	}
+	scope, err := f.scopeSnapshot(rule)
+	if err != nil || entry.Scope != scope {
+		f.log.V(5).Info("cache entry is stale, the files the rule reads changed", "ruleID", rule.RuleID)
+		return ConditionResponse{}, false
+	}
+	for file, hash := range entry.Files {
+		current, err := f.hashFile(file)
+		if err != nil || current != hash {
+			f.log.V(5).Info("cache entry is stale", "ruleID", rule.RuleID, "file", file)
+			return ConditionResponse{}, false
+		}
+	}
+	f.log.V(5).Info("using cached result", "ruleID", rule.RuleID)
+	return entry.Response, true
+}
+
+func (f *fileRuleCache) Put(rule Rule, condCtx ConditionContext, response ConditionResponse) error {
+	key, ok := f.key(rule, condCtx)
+	if !ok {
+		return nil
+	}
+	scope, err := f.scopeSnapshot(rule)
+	if err != nil {
+		// We can not tell if the locations change, so the result can not be trusted later.
+		return fmt.Errorf("unable to hash the files of the locations: %w", err)
+	}
+	entry := ruleCacheEntry{
+		RuleID:   rule.RuleID,
+		Scope:    scope,
+		Files:    map[string]string{},
+		Response: response,
+	}
+	for _, incident := range response.Incidents {
+		if !strings.HasPrefix(string(incident.FileURI), uri.FileScheme) {
+			continue
+		}
+		file := incident.FileURI.Filename()
+		if _, ok := entry.Files[file]; ok {
+			continue
+		}
+		hash, err := f.hashFile(file)
+		if err != nil {
+			// We can not tell if this file changes, so the result can not be trusted later.
+			return fmt.Errorf("unable to hash file %s: %w", file, err)
+		}
+		entry.Files[file] = hash
+	}
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so that a concurrent or interrupted run
+	// never sees a partially written entry.
+	tmp, err := os.CreateTemp(f.dir, key)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.entryPath(key))
+}
+
+// key returns false when the rule can not be cached because we do not know
+// how it was defined.
+func (f *fileRuleCache) key(rule Rule, condCtx ConditionContext) (string, bool) {
+	if rule.DefinitionHash == "" {
+		return "", false
+	}
+	// yaml sorts map keys, this gives us a stable representation of the context.
+	context, err := yaml.Marshal(struct {
+		Tags     map[string]interface{}   `yaml:"tags"`
+		Template map[string]ChainTemplate `yaml:"template"`
+	}{
+		Tags:     condCtx.Tags,
+		Template: condCtx.Template,
+	})
+	if err != nil {
+		f.log.V(5).Error(err, "unable to serialize condition context", "ruleID", rule.RuleID)
+		return "", false
+	}
+	h := sha256.New()
+	h.Write([]byte(rule.DefinitionHash))
+	h.Write([]byte(f.configHash))
+	h.Write(context)
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+func (f *fileRuleCache) entryPath(key string) string {
+	return filepath.Join(f.dir, fmt.Sprintf("%s.json", key))
+}
+
+// scopeSnapshot hashes the paths and the content of the files in the
+// locations that the rule reads.
+func (f *fileRuleCache) scopeSnapshot(rule Rule) (string, error) {
+	files, err := f.locationFiles()
+	if err != nil {
+		return "", err
+	}
+	scope, scoped := conditionFileScope(rule.When)
+	h := sha256.New()
+	for _, path := range files {
+		if scoped && !scope(path) {
+			continue
+		}
+		hash, err := f.hashFile(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s  %s\n", hash, path)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// locationFiles lists every file in the locations once per run, sorted by
+// path, git metadata and the cache itself are left out.
+func (f *fileRuleCache) locationFiles() ([]string, error) {
+	f.filesOnce.Do(func() {
+		cacheDir, err := filepath.Abs(f.dir)
+		if err != nil {
+			f.filesErr = err
+			return
+		}
+		files := map[string]bool{}
+		for _, location := range f.locations {
+			if location == "" {
+				continue
+			}
+			location, err := filepath.Abs(location)
+			if err != nil {
+				f.filesErr = err
+				return
+			}
+			err = filepath.WalkDir(location, func(path string, d os.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() {
+					if path == cacheDir || d.Name() == ".git" {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if d.Type().IsRegular() {
+					files[path] = true
+				}
+				return nil
+			})
+			if err != nil {
+				f.filesErr = err
+				return
+			}
+		}
+		f.files = make([]string, 0, len(files))
+		for path := range files {
+			f.files = append(f.files, path)
+		}
+		sort.Strings(f.files)
+	})
+	return f.files, f.filesErr
+}
+
+// hashFile hashes the content of a file once per run.
+func (f *fileRuleCache) hashFile(path string) (string, error) {
+	f.mutex.RLock()
+	hash, ok := f.fileHashes[path]
+	f.mutex.RUnlock()
+	if ok {
+		return hash, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	hash = hex.EncodeToString(h.Sum(nil))
+	f.mutex.Lock()
+	f.fileHashes[path] = hash
+	f.mutex.Unlock()
+	return hash, nil
+}
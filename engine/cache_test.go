@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"go.lsp.dev/uri"
+)
+
+func TestFileRuleCache(t *testing.T) {
+	dir := t.TempDir()
+	location := filepath.Join(dir, "src")
+	if err := os.Mkdir(location, 0755); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(location, "source.java")
+	err := os.WriteFile(source, []byte("import javax.ejb.Stateless;\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lineNumber := 1
+	response := ConditionResponse{
+		Matched: true,
+		Incidents: []IncidentContext{
+			{
+				FileURI:    uri.File(source),
+				LineNumber: &lineNumber,
+				Variables:  map[string]interface{}{"name": "javax.ejb.Stateless"},
+			},
+		},
+	}
+	rule := Rule{
+		RuleMeta:       RuleMeta{RuleID: "ejb-00001"},
+		DefinitionHash: "rule-hash",
+	}
+	condCtx := ConditionContext{
+		Tags:     map[string]interface{}{},
+		Template: map[string]ChainTemplate{},
+	}
+
+	newCache := func(configHash string) RuleCache {
+		cache, err := NewFileRuleCache(filepath.Join(dir, "cache"), configHash, []string{location}, logr.Discard())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cache
+	}
+
+	cache := newCache("config")
+	if _, ok := cache.Get(rule, condCtx); ok {
+		t.Fatalf("expected empty cache to miss")
+	}
+	if err := cache.Put(rule, condCtx, response); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := newCache("config").Get(rule, condCtx)
+	if !ok {
+		t.Fatalf("expected cached result for unchanged rule and files")
+	}
+	if !got.Matched || len(got.Incidents) != 1 || got.Incidents[0].FileURI != uri.File(source) {
+		t.Errorf("unexpected cached response: %#v", got)
+	}
+
+	if _, ok := newCache("other-config").Get(rule, condCtx); ok {
+		t.Errorf("expected miss when provider config changes")
+	}
+	changedRule := rule
+	changedRule.DefinitionHash = "changed-rule-hash"
+	if _, ok := newCache("config").Get(changedRule, condCtx); ok {
+		t.Errorf("expected miss when rule definition changes")
+	}
+	taggedCtx := condCtx.Copy()
+	taggedCtx.Tags["EJB"] = true
+	if _, ok := newCache("config").Get(rule, taggedCtx); ok {
+		t.Errorf("expected miss when tags change")
+	}
+	if _, ok := newCache("config").Get(Rule{RuleMeta: RuleMeta{RuleID: "no-hash"}}, condCtx); ok {
+		t.Errorf("expected rules without a definition hash to never be cached")
+	}
+
+	err = os.WriteFile(source, []byte("import jakarta.ejb.Stateless;\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := newCache("config").Get(rule, condCtx); ok {
+		t.Errorf("expected miss when a file the rule matched changed")
+	}
+
+	noMatchRule := Rule{
+		RuleMeta:       RuleMeta{RuleID: "ejb-00002"},
+		DefinitionHash: "no-match-rule-hash",
+	}
+	if err := newCache("config").Put(noMatchRule, condCtx, ConditionResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := newCache("config").Get(noMatchRule, condCtx); !ok {
+		t.Fatalf("expected cached result for a rule that matched nothing")
+	}
+	err = os.WriteFile(filepath.Join(location, "added.java"), []byte("import javax.ejb.Singleton;\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := newCache("config").Get(noMatchRule, condCtx); ok {
+		t.Errorf("expected miss when a file that could match is added after a cached no-match")
+	}
+}
+
+type testScopedConditional struct {
+	suffix string
+}
+
+func (t testScopedConditional) Evaluate(ctx context.Context, log logr.Logger, condCtx ConditionContext) (ConditionResponse, error) {
+	return ConditionResponse{}, nil
+}
+
+func (t testScopedConditional) FileScope() (func(path string) bool, bool) {
+	return func(path string) bool { return strings.HasSuffix(path, t.suffix) }, true
+}
+
+func TestFileRuleCacheScope(t *testing.T) {
+	dir := t.TempDir()
+	location := filepath.Join(dir, "src")
+	if err := os.Mkdir(location, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(location, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("Main.java", "class Main {}\n")
+	write("app.properties", "port=8080\n")
+	newCache := func() RuleCache {
+		cache, err := NewFileRuleCache(filepath.Join(dir, "cache"), "config", []string{location}, logr.Discard())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cache
+	}
+	condCtx := ConditionContext{Tags: map[string]interface{}{}, Template: map[string]ChainTemplate{}}
+	rule := func(id string, when Conditional) Rule {
+		return Rule{RuleMeta: RuleMeta{RuleID: id}, DefinitionHash: id, When: when}
+	}
+	properties := rule("properties-00001", AndCondition{Conditions: []ConditionEntry{
+		{ProviderSpecificConfig: testScopedConditional{suffix: ".properties"}},
+		{ProviderSpecificConfig: CountCondition{Condition: ConditionEntry{ProviderSpecificConfig: testScopedConditional{suffix: ".yaml"}}}},
+	}})
+	unscoped := rule("unscoped-00001", OrCondition{Conditions: []ConditionEntry{
+		{ProviderSpecificConfig: testScopedConditional{suffix: ".properties"}},
+		{ProviderSpecificConfig: testConditional{}},
+	}})
+	for _, r := range []Rule{properties, unscoped} {
+		if err := newCache().Put(r, condCtx, ConditionResponse{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("Main.java", "class Main { int port; }\n")
+	if _, ok := newCache().Get(properties, condCtx); !ok {
+		t.Errorf("expected a hit when a file the rule does not read changed")
+	}
+	if _, ok := newCache().Get(unscoped, condCtx); ok {
+		t.Errorf("expected a miss when any file changed for a rule with a condition that does not tell the files it reads")
+	}
+
+	write("application.yaml", "port: 8080\n")
+	if _, ok := newCache().Get(properties, condCtx); ok {
+		t.Errorf("expected a miss when a file the rule reads was added")
+	}
+}
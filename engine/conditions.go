@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"maps"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
@@ -15,6 +18,7 @@ import (
 
 var _ Conditional = AndCondition{}
 var _ Conditional = OrCondition{}
+var _ Conditional = CountCondition{}
 
 type ConditionResponse struct {
 	Matched bool `yaml:"matched"`
@@ -96,6 +100,8 @@ type RuleSet struct {
 	Labels      []string `json:"labels,omitempty" yaml:"labels,omitempty"`
 	Tags        []string `json:"tags,omitempty" yaml:"tags,omitempty"`
 	Rules       []Rule   `json:"rules,omitempty" yaml:"rules,omitempty"`
+	// Provenance is set when the ruleset was loaded from a bundle.
+	Provenance *konveyor.Provenance `json:"-" yaml:"-"`
 }
 
 type Rule struct {
@@ -104,6 +110,22 @@ type Rule struct {
 	When            Conditional      `yaml:"when,omitempty" json:"when,omitempty"`
 	Snipper         CodeSnip         `yaml:"-" json:"-"`
 	CustomVariables []CustomVariable `yaml:"customVariables,omitempty" json:"customVariables,omitempty"`
+	// DependsOn lists rules that must match before this rule runs, given by their
+	// rule ID, prefixed by the name of their ruleset and a slash when they are in
+	// another ruleset. Their results can be used in conditions with "from".
+	DependsOn []string `yaml:"dependsOn,omitempty" json:"dependsOn,omitempty"`
+	// Supersedes lists less specific rules, given like in DependsOn. Their
+	// incidents are not reported where this rule reports an incident too.
+	Supersedes []string `yaml:"supersedes,omitempty" json:"supersedes,omitempty"`
+	// Timeout bounds how long the conditions of the rule can take to evaluate,
+	// zero means no timeout.
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// UsesTags lists the tags the conditions of the rule look up, the rule waits
+	// for the tagging rules that can create them before it runs.
+	UsesTags []string `yaml:"-" json:"-"`
+	// DefinitionHash identifies the rule as it was written, it is used to
+	// determine if results of earlier runs can be reused for this rule.
+	DefinitionHash string `yaml:"-" json:"-"`
 }
 
 type RuleMeta struct {
@@ -128,11 +150,21 @@ type CustomVariable struct {
 	Name               string         `yaml:"name"`
 	DefaultValue       string         `yaml:"defaultValue"`
 	NameOfCaptureGroup string         `yaml:"nameOfCaptureGroup"`
+	// Source is the text the pattern is matched against, the line of the
+	// incident by default.
+	Source CustomVariableSource `yaml:"source,omitempty"`
+	// LinesBefore and LinesAfter are the number of lines around the incident
+	// that are matched with the lines source.
+	LinesBefore int `yaml:"linesBefore,omitempty"`
+	LinesAfter  int `yaml:"linesAfter,omitempty"`
+	// Variable is the name of the provider variable matched with the variable source.
+	Variable string `yaml:"variable,omitempty"`
 }
 
 type Perform struct {
 	Message Message  `yaml:",inline"`
 	Tag     []string `yaml:"tag,omitempty"`
+	Fix     *Fix     `yaml:"fix,omitempty"`
 }
 
 type Message struct {
@@ -141,14 +173,163 @@ type Message struct {
 }
 
 func (p *Perform) Validate() error {
-	if p.Message.Text == nil && p.Tag == nil {
-		return fmt.Errorf("either message or tag must be set")
+	if p.Message.Text == nil && p.Tag == nil && p.Fix == nil {
+		return fmt.Errorf("either message, tag or fix must be set")
+	}
+	if p.Fix != nil {
+		return p.Fix.Validate()
 	}
 	return nil
 }
 
+// EvaluationMode is how the conditions of an and or an or condition are
+// evaluated.
+type EvaluationMode string
+
+const (
+	// EagerEvaluation evaluates every condition, in the order they are written.
+	EagerEvaluation EvaluationMode = "eager"
+	// LazyEvaluation evaluates the cheapest conditions first and stops as soon
+	// as the result is known.
+	LazyEvaluation EvaluationMode = "lazy"
+)
+
+func validateEvaluationMode(mode EvaluationMode) error {
+	switch mode {
+	case "", EagerEvaluation, LazyEvaluation:
+		return nil
+	}
+	return fmt.Errorf("evaluation must be %s or %s, not %s", EagerEvaluation, LazyEvaluation, mode)
+}
+
+// CostHinter is implemented by conditions that know how expensive they are to
+// evaluate compared to other conditions, conditions that do not implement it
+// cost nothing.
+type CostHinter interface {
+	Cost() int
+}
+
+func conditionCost(c Conditional) int {
+	if hinter, ok := c.(CostHinter); ok {
+		return hinter.Cost()
+	}
+	return 0
+}
+
+func conditionsCost(conditions []ConditionEntry) int {
+	cost := 0
+	for _, c := range conditions {
+		cost += conditionCost(c.ProviderSpecificConfig)
+	}
+	return cost
+}
+
+// FileScoper is implemented by conditions that know which of the files of the
+// locations they read, the cached result of a rule is only invalidated by
+// changes of those files. Conditions that do not implement it can read any
+// file.
+type FileScoper interface {
+	// FileScope returns whether the condition reads the file at the absolute
+	// path, false when the condition can not tell.
+	FileScope() (func(path string) bool, bool)
+}
+
+func conditionFileScope(c Conditional) (func(path string) bool, bool) {
+	if scoper, ok := c.(FileScoper); ok {
+		return scoper.FileScope()
+	}
+	return nil, false
+}
+
+// conditionsFileScope is the union of the files the conditions read.
+func conditionsFileScope(conditions []ConditionEntry) (func(path string) bool, bool) {
+	scopes := []func(path string) bool{}
+	for _, c := range conditions {
+		scope, ok := conditionFileScope(c.ProviderSpecificConfig)
+		if !ok {
+			return nil, false
+		}
+		scopes = append(scopes, scope)
+	}
+	return func(path string) bool {
+		for _, scope := range scopes {
+			if scope(path) {
+				return true
+			}
+		}
+		return false
+	}, true
+}
+
+// orderConditionEntries sorts the conditions to evaluate them, the cheapest
+// conditions come first when they are evaluated lazily.
+func orderConditionEntries(entries []ConditionEntry, mode EvaluationMode) []ConditionEntry {
+	if mode == LazyEvaluation {
+		entries = slices.Clone(entries)
+		sort.SliceStable(entries, func(i, j int) bool {
+			return conditionCost(entries[i].ProviderSpecificConfig) < conditionCost(entries[j].ProviderSpecificConfig)
+		})
+	}
+	return sortConditionEntries(entries)
+}
+
+// AndCorrelation is how the incidents of the conditions of an and condition
+// must be found together.
+type AndCorrelation string
+
+const (
+	// FileCorrelation keeps the incidents in the same file as an incident of
+	// every other condition.
+	FileCorrelation AndCorrelation = "file"
+	// LinesCorrelation keeps the incidents within a number of lines of an
+	// incident of every other condition.
+	LinesCorrelation AndCorrelation = "lines"
+)
+
 type AndCondition struct {
 	Conditions []ConditionEntry `yaml:"and"`
+	// Correlate only keeps the incidents that are found together with the
+	// incidents of the other conditions, the negated conditions are not
+	// correlated.
+	Correlate AndCorrelation `yaml:"correlate,omitempty"`
+	// Within is the number of lines between correlated incidents.
+	Within int `yaml:"within,omitempty"`
+	// Evaluation lazy stops at the first condition that does not match.
+	Evaluation EvaluationMode `yaml:"evaluation,omitempty"`
+}
+
+func (a AndCondition) Cost() int {
+	return conditionsCost(a.Conditions)
+}
+
+func (a AndCondition) FileScope() (func(path string) bool, bool) {
+	return conditionsFileScope(a.Conditions)
+}
+
+func (a AndCondition) Validate() error {
+	if err := validateEvaluationMode(a.Evaluation); err != nil {
+		return err
+	}
+	switch a.Correlate {
+	case "", FileCorrelation:
+		if a.Within != 0 {
+			return fmt.Errorf("within can only be used to correlate %s", LinesCorrelation)
+		}
+	case LinesCorrelation:
+		if a.Within < 0 {
+			return fmt.Errorf("within can not be negative")
+		}
+	default:
+		return fmt.Errorf("correlate must be %s or %s, not %s", FileCorrelation, LinesCorrelation, a.Correlate)
+	}
+	return nil
+}
+
+// correlatedIncidents are the incidents of a condition of an and condition that
+// are correlated, along with whether they are reported.
+type correlatedIncidents struct {
+	incidents []IncidentContext
+	ignorable bool
 }
 
 func (a AndCondition) Evaluate(ctx context.Context, log logr.Logger, condCtx ConditionContext) (ConditionResponse, error) {
@@ -164,7 +345,8 @@ func (a AndCondition) Evaluate(ctx context.Context, log logr.Logger, condCtx Con
 		Incidents:       []IncidentContext{},
 		TemplateContext: map[string]interface{}{},
 	}
-	conditions := sortConditionEntries(a.Conditions)
+	conditions := orderConditionEntries(a.Conditions, a.Evaluation)
+	correlated := []correlatedIncidents{}
 	for _, c := range conditions {
 		if _, ok := condCtx.Template[c.From]; !ok && c.From != "" {
 			// Short circut w/ error here
@@ -190,20 +372,106 @@ func (a AndCondition) Evaluate(ctx context.Context, log logr.Logger, condCtx Con
 			fullResponse.Matched = false
 		}
 
-		if !c.Ignorable {
+		if a.Correlate != "" && !c.Not {
+			correlated = append(correlated, correlatedIncidents{incidents: response.Incidents, ignorable: c.Ignorable})
+		} else if !c.Ignorable {
 			fullResponse.Incidents = append(fullResponse.Incidents, response.Incidents...)
 		}
 
 		for k, v := range response.TemplateContext {
 			fullResponse.TemplateContext[k] = v
 		}
+
+		if !fullResponse.Matched && a.Evaluation == LazyEvaluation {
+			log.V(5).Info("skipping the remaining conditions of and condition", "ruleID", condCtx.RuleID)
+			break
+		}
+	}
+
+	if a.Correlate != "" && fullResponse.Matched {
+		incidents, ok := a.correlate(correlated)
+		if !ok {
+			log.V(5).Info("incidents of and condition are not correlated", "ruleID", condCtx.RuleID, "correlate", a.Correlate)
+			fullResponse.Matched = false
+			fullResponse.Incidents = []IncidentContext{}
+		} else {
+			fullResponse.Incidents = append(fullResponse.Incidents, incidents...)
+		}
 	}
 
 	return fullResponse, nil
 }
 
+// correlate returns the incidents that are found together with an incident of
+// every other condition, or false when there are none.
+func (a AndCondition) correlate(conditions []correlatedIncidents) ([]IncidentContext, bool) {
+	byFile := make([]map[uri.URI][]IncidentContext, len(conditions))
+	for i, c := range conditions {
+		byFile[i] = map[uri.URI][]IncidentContext{}
+		for _, incident := range c.incidents {
+			byFile[i][incident.FileURI] = append(byFile[i][incident.FileURI], incident)
+		}
+	}
+	kept := []IncidentContext{}
+	for i, c := range conditions {
+		found := false
+		for _, incident := range c.incidents {
+			together := true
+			for j := range conditions {
+				if i != j && !a.foundNear(incident, byFile[j][incident.FileURI]) {
+					together = false
+					break
+				}
+			}
+			if !together {
+				continue
+			}
+			found = true
+			if !c.ignorable {
+				kept = append(kept, incident)
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return kept, true
+}
+
+// foundNear returns whether one of the incidents, all in the same file as the
+// incident, is near it. Incidents without a line are near every incident of
+// their file.
+func (a AndCondition) foundNear(incident IncidentContext, incidents []IncidentContext) bool {
+	for _, other := range incidents {
+		if a.Correlate == FileCorrelation || incident.LineNumber == nil || other.LineNumber == nil {
+			return true
+		}
+		distance := *incident.LineNumber - *other.LineNumber
+		if distance <= a.Within && -distance <= a.Within {
+			return true
+		}
+	}
+	return false
+}
+
 type OrCondition struct {
 	Conditions []ConditionEntry `yaml:"or"`
+	// Evaluation lazy stops at the first condition that matches and is not
+	// ignored, ignored conditions such as the start of a chain have no
+	// incidents to report.
+	Evaluation EvaluationMode `yaml:"evaluation,omitempty"`
+}
+
+func (o OrCondition) Cost() int {
+	return conditionsCost(o.Conditions)
+}
+
+func (o OrCondition) FileScope() (func(path string) bool, bool) {
+	return conditionsFileScope(o.Conditions)
+}
+
+func (o OrCondition) Validate() error {
+	return validateEvaluationMode(o.Evaluation)
 }
 
 func (o OrCondition) Evaluate(ctx context.Context, log logr.Logger, condCtx ConditionContext) (ConditionResponse, error) {
@@ -220,7 +488,7 @@ func (o OrCondition) Evaluate(ctx context.Context, log logr.Logger, condCtx Cond
 		Incidents:       []IncidentContext{},
 		TemplateContext: map[string]interface{}{},
 	}
-	conditions := sortConditionEntries(o.Conditions)
+	conditions := orderConditionEntries(o.Conditions, o.Evaluation)
 	for _, c := range conditions {
 		if _, ok := condCtx.Template[c.From]; !ok && c.From != "" {
 			// Short circut w/ error here
@@ -256,10 +524,113 @@ func (o OrCondition) Evaluate(ctx context.Context, log logr.Logger, condCtx Cond
 			fullResponse.TemplateContext[k] = v
 		}
 
+		if matched && !c.Ignorable && o.Evaluation == LazyEvaluation {
+			log.V(5).Info("skipping the remaining conditions of or condition", "ruleID", condCtx.RuleID)
+			break
+		}
 	}
 	return fullResponse, nil
 }
 
+// CountOf is what a CountCondition counts.
+type CountOf string
+
+const (
+	// IncidentsCount counts the incidents of the condition.
+	IncidentsCount CountOf = "incidents"
+	// FilesCount counts the files with incidents of the condition.
+	FilesCount CountOf = "files"
+)
+
+// CountCondition matches when the number of incidents of its condition, or the
+// number of files with incidents, is at least Min and at most Max. The
+// incidents are replaced by a single incident without a location, with the
+// count in its variables.
+type CountCondition struct {
+	Condition ConditionEntry `yaml:"condition"`
+	Of        CountOf        `yaml:"of,omitempty"`
+	Min       *int           `yaml:"min,omitempty"`
+	Max       *int           `yaml:"max,omitempty"`
+}
+
+func (c CountCondition) Cost() int {
+	return conditionCost(c.Condition.ProviderSpecificConfig)
+}
+
+func (c CountCondition) FileScope() (func(path string) bool, bool) {
+	return conditionFileScope(c.Condition.ProviderSpecificConfig)
+}
+
+func (c CountCondition) Validate() error {
+	if c.Of != "" && c.Of != IncidentsCount && c.Of != FilesCount {
+		return fmt.Errorf("count of must be %s or %s, not %s", IncidentsCount, FilesCount, c.Of)
+	}
+	if c.Min == nil && c.Max == nil {
+		return fmt.Errorf("count must have a min or a max")
+	}
+	if (c.Min != nil && *c.Min < 0) || (c.Max != nil && *c.Max < 0) {
+		return fmt.Errorf("count min and max can not be negative")
+	}
+	if c.Min != nil && c.Max != nil && *c.Min > *c.Max {
+		return fmt.Errorf("count min %d is greater than max %d", *c.Min, *c.Max)
+	}
+	return nil
+}
+
+func (c CountCondition) Evaluate(ctx context.Context, log logr.Logger, condCtx ConditionContext) (ConditionResponse, error) {
+	ctx, span := tracing.StartNewSpan(ctx, "count-condition")
+	defer span.End()
+
+	if c.Condition.ProviderSpecificConfig == nil {
+		return ConditionResponse{}, fmt.Errorf("condition must not be empty while evaluating")
+	}
+	if _, ok := condCtx.Template[c.Condition.From]; !ok && c.Condition.From != "" {
+		return ConditionResponse{}, fmt.Errorf("unable to find context value: %v", c.Condition.From)
+	}
+	response, err := c.Condition.Evaluate(ctx, log, condCtx)
+	if err != nil {
+		return ConditionResponse{}, err
+	}
+	if c.Condition.As != "" {
+		condCtx.Template[c.Condition.As] = ChainTemplate{
+			Filepaths: incidentsToFilepaths(response.Incidents),
+			Extras:    response.TemplateContext,
+		}
+	}
+
+	incidents := 0
+	files := map[uri.URI]bool{}
+	if response.Matched {
+		incidents = len(response.Incidents)
+		for _, incident := range response.Incidents {
+			files[incident.FileURI] = true
+		}
+	}
+	count := incidents
+	if c.Of == FilesCount {
+		count = len(files)
+	}
+	log.V(5).Info("counted incidents of condition", "ruleID", condCtx.RuleID, "of", c.Of, "count", count)
+	if (c.Min != nil && count < *c.Min) || (c.Max != nil && count > *c.Max) {
+		return ConditionResponse{TemplateContext: response.TemplateContext}, nil
+	}
+	return ConditionResponse{
+		Matched: true,
+		Incidents: []IncidentContext{{
+			Variables: map[string]interface{}{
+				"count":     count,
+				"incidents": incidents,
+				"files":     len(files),
+			},
+		}},
+		TemplateContext: response.TemplateContext,
+	}, nil
+}
+
+func (ce ConditionEntry) FileScope() (func(path string) bool, bool) {
+	return conditionFileScope(ce.ProviderSpecificConfig)
+}
+
 func (ce ConditionEntry) Evaluate(ctx context.Context, log logr.Logger, condCtx ConditionContext) (ConditionResponse, error) {
 	response, err := ce.ProviderSpecificConfig.Evaluate(ctx, log, condCtx)
 	if err != nil {
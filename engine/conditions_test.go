@@ -1,8 +1,12 @@
 package engine
 
 import (
+	"context"
 	"reflect"
 	"testing"
+
+	"github.com/go-logr/logr"
+	"go.lsp.dev/uri"
 )
 
 func Test_sortConditionEntries(t *testing.T) {
@@ -248,3 +252,306 @@ func Test_sortConditionEntries(t *testing.T) {
 		})
 	}
 }
+
+type testIncidentsConditional struct {
+	files []string
+}
+
+func (t testIncidentsConditional) Evaluate(ctx context.Context, log logr.Logger, condCtx ConditionContext) (ConditionResponse, error) {
+	response := ConditionResponse{Matched: len(t.files) > 0}
+	for _, file := range t.files {
+		response.Incidents = append(response.Incidents, IncidentContext{FileURI: uri.File(file)})
+	}
+	return response, nil
+}
+
+func TestCountCondition(t *testing.T) {
+	intPtr := func(i int) *int {
+		return &i
+	}
+	files := []string{"/a/A.java", "/a/A.java", "/a/B.java", "/a/C.java", "/a/C.java"}
+	tests := []struct {
+		name      string
+		condition CountCondition
+		matched   bool
+		count     int
+	}{
+		{
+			name:      "incidents at least min",
+			condition: CountCondition{Min: intPtr(5)},
+			matched:   true,
+			count:     5,
+		},
+		{
+			name:      "incidents more than max",
+			condition: CountCondition{Max: intPtr(4)},
+		},
+		{
+			name:      "files between min and max",
+			condition: CountCondition{Of: FilesCount, Min: intPtr(2), Max: intPtr(3)},
+			matched:   true,
+			count:     3,
+		},
+		{
+			name:      "files less than min",
+			condition: CountCondition{Of: FilesCount, Min: intPtr(4)},
+		},
+		{
+			name:      "negated condition counts nothing",
+			condition: CountCondition{Condition: ConditionEntry{Not: true}, Max: intPtr(0)},
+			matched:   true,
+			count:     0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.condition.Condition.ProviderSpecificConfig = testIncidentsConditional{files: files}
+			if err := tt.condition.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			response, err := tt.condition.Evaluate(context.TODO(), logr.Discard(), ConditionContext{Template: map[string]ChainTemplate{}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.Matched != tt.matched {
+				t.Fatalf("got matched %v, want %v", response.Matched, tt.matched)
+			}
+			if !tt.matched {
+				return
+			}
+			if len(response.Incidents) != 1 {
+				t.Fatalf("expected a single incident, got %v", response.Incidents)
+			}
+			if got := response.Incidents[0].Variables["count"]; got != tt.count {
+				t.Errorf("got count %v, want %v", got, tt.count)
+			}
+		})
+	}
+}
+
+type testLocatedConditional struct {
+	incidents []IncidentContext
+}
+
+func (t testLocatedConditional) Evaluate(ctx context.Context, log logr.Logger, condCtx ConditionContext) (ConditionResponse, error) {
+	return ConditionResponse{Matched: len(t.incidents) > 0, Incidents: t.incidents}, nil
+}
+
+func TestAndConditionCorrelate(t *testing.T) {
+	incident := func(file string, line int) IncidentContext {
+		return IncidentContext{FileURI: uri.File(file), LineNumber: &line}
+	}
+	annotations := testLocatedConditional{incidents: []IncidentContext{
+		incident("/a/A.java", 10),
+		incident("/a/B.java", 3),
+	}}
+	calls := testLocatedConditional{incidents: []IncidentContext{
+		incident("/a/A.java", 14),
+		incident("/a/A.java", 40),
+		incident("/a/C.java", 3),
+	}}
+	tests := []struct {
+		name      string
+		condition AndCondition
+		ignore    bool
+		matched   bool
+		want      []IncidentContext
+	}{
+		{
+			name:      "uncorrelated reports every incident",
+			condition: AndCondition{},
+			matched:   true,
+			want:      append(append([]IncidentContext{}, annotations.incidents...), calls.incidents...),
+		},
+		{
+			name:      "same file",
+			condition: AndCondition{Correlate: FileCorrelation},
+			matched:   true,
+			want:      []IncidentContext{annotations.incidents[0], calls.incidents[0], calls.incidents[1]},
+		},
+		{
+			name:      "within lines",
+			condition: AndCondition{Correlate: LinesCorrelation, Within: 5},
+			matched:   true,
+			want:      []IncidentContext{annotations.incidents[0], calls.incidents[0]},
+		},
+		{
+			name:      "within lines of an ignored condition",
+			condition: AndCondition{Correlate: LinesCorrelation, Within: 5},
+			ignore:    true,
+			matched:   true,
+			want:      []IncidentContext{calls.incidents[0]},
+		},
+		{
+			name:      "not within lines",
+			condition: AndCondition{Correlate: LinesCorrelation, Within: 3},
+			want:      []IncidentContext{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.condition.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			tt.condition.Conditions = []ConditionEntry{
+				{ProviderSpecificConfig: annotations, Ignorable: tt.ignore},
+				{ProviderSpecificConfig: calls},
+			}
+			response, err := tt.condition.Evaluate(context.TODO(), logr.Discard(), ConditionContext{Template: map[string]ChainTemplate{}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.Matched != tt.matched {
+				t.Errorf("got matched %v, want %v", response.Matched, tt.matched)
+			}
+			if !reflect.DeepEqual(response.Incidents, tt.want) {
+				t.Errorf("got incidents %v, want %v", response.Incidents, tt.want)
+			}
+		})
+	}
+}
+
+type testCostConditional struct {
+	name      string
+	cost      int
+	matched   bool
+	incidents []IncidentContext
+	evaluated *[]string
+}
+
+func (t testCostConditional) Evaluate(ctx context.Context, log logr.Logger, condCtx ConditionContext) (ConditionResponse, error) {
+	*t.evaluated = append(*t.evaluated, t.name)
+	return ConditionResponse{Matched: t.matched, Incidents: t.incidents}, nil
+}
+
+func (t testCostConditional) Cost() int {
+	return t.cost
+}
+
+func TestLazyEvaluation(t *testing.T) {
+	tests := []struct {
+		name      string
+		and       bool
+		mode      EvaluationMode
+		matched   []bool
+		evaluated []string
+	}{
+		{
+			name:      "eager and evaluates every condition in order",
+			and:       true,
+			matched:   []bool{true, false, false},
+			evaluated: []string{"lsp", "builtin", "other"},
+		},
+		{
+			name:      "lazy and stops at the first condition that does not match",
+			and:       true,
+			mode:      LazyEvaluation,
+			matched:   []bool{true, false, false},
+			evaluated: []string{"builtin"},
+		},
+		{
+			name:      "eager or evaluates every condition in order",
+			matched:   []bool{true, true, false},
+			evaluated: []string{"lsp", "builtin", "other"},
+		},
+		{
+			name:      "lazy or stops at the first condition that matches",
+			mode:      LazyEvaluation,
+			matched:   []bool{true, false, true},
+			evaluated: []string{"builtin", "other"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluated := []string{}
+			conditions := []ConditionEntry{}
+			for i, c := range []struct {
+				name string
+				cost int
+			}{{"lsp", 10}, {"builtin", 1}, {"other", 5}} {
+				conditions = append(conditions, ConditionEntry{
+					ProviderSpecificConfig: testCostConditional{name: c.name, cost: c.cost, matched: tt.matched[i], evaluated: &evaluated},
+				})
+			}
+			var condition Conditional = OrCondition{Conditions: conditions, Evaluation: tt.mode}
+			if tt.and {
+				condition = AndCondition{Conditions: conditions, Evaluation: tt.mode}
+			}
+			if _, err := condition.Evaluate(context.TODO(), logr.Discard(), ConditionContext{Template: map[string]ChainTemplate{}}); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(evaluated, tt.evaluated) {
+				t.Errorf("got evaluated %v, want %v", evaluated, tt.evaluated)
+			}
+		})
+	}
+}
+
+func TestLazyOrChain(t *testing.T) {
+	evaluated := []string{}
+	incidents := []IncidentContext{{FileURI: "file:///app/pom.xml"}}
+	condition := OrCondition{
+		Evaluation: LazyEvaluation,
+		Conditions: []ConditionEntry{
+			{
+				From:                   "poms",
+				ProviderSpecificConfig: testCostConditional{name: "consumer", cost: 1, matched: true, incidents: incidents, evaluated: &evaluated},
+			},
+			{
+				As:                     "poms",
+				Ignorable:              true,
+				ProviderSpecificConfig: testCostConditional{name: "producer", cost: 10, matched: true, incidents: []IncidentContext{{FileURI: "file:///app/other.xml"}}, evaluated: &evaluated},
+			},
+			{
+				ProviderSpecificConfig: testCostConditional{name: "other", cost: 5, evaluated: &evaluated},
+			},
+		},
+	}
+	response, err := condition.Evaluate(context.TODO(), logr.Discard(), ConditionContext{Template: map[string]ChainTemplate{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the ignored producer matching does not stop the or before its consumer
+	if want := []string{"other", "producer", "consumer"}; !reflect.DeepEqual(evaluated, want) {
+		t.Errorf("got evaluated %v, want %v", evaluated, want)
+	}
+	if !response.Matched || !reflect.DeepEqual(response.Incidents, incidents) {
+		t.Errorf("expected the incidents of the consumer, got matched %v with %v", response.Matched, response.Incidents)
+	}
+}
+
+func TestCountConditionIncludedLines(t *testing.T) {
+	minimum := 2
+	effort := 1
+	message := "{{ count }} incidents"
+	ruleSets := []RuleSet{
+		{
+			Name: "count",
+			Rules: []Rule{
+				{
+					RuleMeta: RuleMeta{RuleID: "count-001", Effort: &effort},
+					Perform:  Perform{Message: Message{Text: &message}},
+					When: CountCondition{
+						Condition: ConditionEntry{ProviderSpecificConfig: testIncidentsConditional{files: []string{"/a/A.java", "/a/B.java"}}},
+						Min:       &minimum,
+					},
+				},
+			},
+		},
+	}
+	scope := IncludedLinesScope(map[string][]LineRange{"/a/A.java": {{Start: 1, End: 1}}}, logr.Discard())
+
+	eng := CreateRuleEngine(context.Background(), 1, logr.Discard())
+	defer eng.Stop()
+	results := eng.RunRulesScoped(context.Background(), ruleSets, scope)
+	if len(results) != 1 {
+		t.Fatalf("expected a single ruleset, got %d", len(results))
+	}
+	violation, ok := results[0].Violations["count-001"]
+	if !ok {
+		t.Fatalf("expected the count to not be filtered out by the included lines, unmatched %v", results[0].Unmatched)
+	}
+	if len(violation.Incidents) != 1 || violation.Incidents[0].Message != "2 incidents" {
+		t.Errorf("unexpected incidents %v", violation.Incidents)
+	}
+}
@@ -0,0 +1,435 @@
+package engine
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+)
+
+// ruleKey identifies a rule across rulesets, rule IDs are only unique within a ruleset.
+type ruleKey struct {
+	ruleSet string
+	ruleID  string
+}
+
+func (k ruleKey) String() string {
+	return fmt.Sprintf("%s/%s", k.ruleSet, k.ruleID)
+}
+
+// ruleDependency is a dependency of a rule as it was written in the rule,
+// along with the rule it refers to. Rules that use tags wait for the tagging
+// rules that can create them without depending on their results.
+type ruleDependency struct {
+	name     string
+	key      ruleKey
+	tagsOnly bool
+}
+
+// dependencyResult is the outcome of a rule that other rules can depend on.
+type dependencyResult struct {
+	matched  bool
+	err      error
+	template ChainTemplate
+	// tags are the tags created by the rule and by the rules it depends on.
+	tags map[string]interface{}
+}
+
+// resolveDependency finds the rule a dependency refers to. A dependency is either
+// the ID of a rule in the same ruleset or a rule ID prefixed by the name of its
+// ruleset and a slash.
+func resolveDependency(ruleSetName string, dependency string, exists func(ruleKey) bool) (ruleKey, bool) {
+	key := ruleKey{ruleSet: ruleSetName, ruleID: dependency}
+	if exists(key) {
+		return key, true
+	}
+	for i := range dependency {
+		if dependency[i] != '/' {
+			continue
+		}
+		key = ruleKey{ruleSet: dependency[:i], ruleID: dependency[i+1:]}
+		if exists(key) {
+			return key, true
+		}
+	}
+	return ruleKey{}, false
+}
+
+// ValidateRuleDependencies makes sure that every dependency of a rule refers to a
+// rule in the given rulesets, that tagging rules only depend on other tagging rules
+// as the rules using their tags wait for them, and that no rule depends on itself.
+func ValidateRuleDependencies(ruleSets []RuleSet) error {
+	rules := map[ruleKey]Rule{}
+	for _, ruleSet := range ruleSets {
+		for _, rule := range ruleSet.Rules {
+			rules[ruleKey{ruleSet: ruleSet.Name, ruleID: rule.RuleID}] = rule
+		}
+	}
+	exists := func(key ruleKey) bool {
+		_, ok := rules[key]
+		return ok
+	}
+	graph := map[ruleKey][]ruleKey{}
+	for _, ruleSet := range ruleSets {
+		for _, rule := range ruleSet.Rules {
+			key := ruleKey{ruleSet: ruleSet.Name, ruleID: rule.RuleID}
+			for _, dependency := range rule.DependsOn {
+				depKey, ok := resolveDependency(ruleSet.Name, dependency, exists)
+				if !ok {
+					return fmt.Errorf("rule %s depends on unknown rule %s", key, dependency)
+				}
+				if rule.Perform.Tag != nil && rules[depKey].Perform.Tag == nil {
+					return fmt.Errorf("rule %s creates tags and can only depend on other rules that create tags, %s does not", key, dependency)
+				}
+				graph[key] = append(graph[key], depKey)
+			}
+		}
+	}
+	if cycle := findDependencyCycle(graph); cycle != nil {
+		names := []string{}
+		for _, key := range cycle {
+			names = append(names, key.String())
+		}
+		return fmt.Errorf("rules depend on each other in a cycle: %s", strings.Join(names, " -> "))
+	}
+	return nil
+}
+
+// findDependencyCycle returns the rules of the first cycle found in the graph,
+// starting and ending with the same rule.
+func findDependencyCycle(graph map[ruleKey][]ruleKey) []ruleKey {
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := map[ruleKey]int{}
+	path := []ruleKey{}
+	var visit func(key ruleKey) []ruleKey
+	visit = func(key ruleKey) []ruleKey {
+		switch state[key] {
+		case visiting:
+			for i := range path {
+				if path[i] == key {
+					return append(append([]ruleKey{}, path[i:]...), key)
+				}
+			}
+		case visited:
+			return nil
+		}
+		state[key] = visiting
+		path = append(path, key)
+		for _, dep := range graph[key] {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[key] = visited
+		return nil
+	}
+
+	keys := make([]ruleKey, 0, len(graph))
+	for key := range graph {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	for _, key := range keys {
+		if cycle := visit(key); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// resolveDependencies looks up the rules that the given rules depend on. Rules that
+// depend on unknown rules get an error, rules that depend on rules that were skipped
+// are skipped as well. The returned results hold the errors of the rules that can not
+// run so that the rules depending on them fail too.
+func (r *ruleEngine) resolveDependencies(taggingRules, otherRules []ruleMessage, mapRuleSets map[string]*konveyor.RuleSet) ([]ruleMessage, []ruleMessage, map[ruleKey]dependencyResult) {
+	results := map[ruleKey]dependencyResult{}
+	known := map[ruleKey]bool{}
+	skipped := map[ruleKey]bool{}
+	for _, rs := range mapRuleSets {
+		for _, ruleID := range rs.Skipped {
+			skipped[ruleKey{ruleSet: rs.Name, ruleID: ruleID}] = true
+		}
+	}
+	for _, messages := range [][]ruleMessage{taggingRules, otherRules} {
+		for _, m := range messages {
+			known[ruleKey{ruleSet: m.ruleSetName, ruleID: m.rule.RuleID}] = true
+		}
+	}
+	exists := func(key ruleKey) bool {
+		return known[key] || skipped[key]
+	}
+	tagging := map[ruleKey]bool{}
+	for _, m := range taggingRules {
+		tagging[ruleKey{ruleSet: m.ruleSetName, ruleID: m.rule.RuleID}] = true
+	}
+
+	resolve := func(messages []ruleMessage) []ruleMessage {
+		resolved := []ruleMessage{}
+		for _, m := range messages {
+			key := ruleKey{ruleSet: m.ruleSetName, ruleID: m.rule.RuleID}
+			m.dependencies = nil
+			var err error
+			for _, dependency := range m.rule.DependsOn {
+				depKey, ok := resolveDependency(m.ruleSetName, dependency, exists)
+				if !ok {
+					err = fmt.Errorf("unable to find rule %s that this rule depends on", dependency)
+					break
+				}
+				// Tagging rules run before the other rules, they can never
+				// see the results of a rule that does not create tags.
+				if tagging[key] && known[depKey] && !tagging[depKey] {
+					err = fmt.Errorf("rule creates tags and can only depend on other rules that create tags, %s does not", dependency)
+					break
+				}
+				m.dependencies = append(m.dependencies, ruleDependency{name: dependency, key: depKey})
+			}
+			if err != nil {
+				r.logger.Error(err, "unable to resolve rule dependencies", "ruleID", m.rule.RuleID)
+				mapRuleSets[m.ruleSetName].Errors[m.rule.RuleID] = err.Error()
+				results[key] = dependencyResult{err: err}
+				continue
+			}
+			resolved = append(resolved, m)
+		}
+		return resolved
+	}
+	taggingRules = resolve(taggingRules)
+	otherRules = resolve(otherRules)
+
+	// Skipping a rule can cause the rules that depend on it to be skipped.
+	skip := func(messages []ruleMessage) ([]ruleMessage, bool) {
+		kept := []ruleMessage{}
+		changed := false
+		for _, m := range messages {
+			key := ruleKey{ruleSet: m.ruleSetName, ruleID: m.rule.RuleID}
+			skippedDep := ""
+			for _, dependency := range m.dependencies {
+				if skipped[dependency.key] && !known[dependency.key] {
+					skippedDep = dependency.name
+					break
+				}
+			}
+			if skippedDep == "" {
+				kept = append(kept, m)
+				continue
+			}
+			r.logger.V(5).Info("rule depends on a skipped rule, skipping", "ruleID", m.rule.RuleID, "dependency", skippedDep)
+			if !skipped[key] {
+				mapRuleSets[m.ruleSetName].Skipped = append(mapRuleSets[m.ruleSetName].Skipped, m.rule.RuleID)
+			}
+			skipped[key] = true
+			delete(known, key)
+			changed = true
+		}
+		return kept, changed
+	}
+	for {
+		var taggingChanged, otherChanged bool
+		taggingRules, taggingChanged = skip(taggingRules)
+		otherRules, otherChanged = skip(otherRules)
+		if !taggingChanged && !otherChanged {
+			break
+		}
+	}
+	return taggingRules, otherRules, results
+}
+
+// orderByDependencies orders rules so that every rule comes after the rules it
+// depends on, keeping the order of the given rules otherwise. Rules that are
+// already completed are taken from the results. Rules that can never run
+// because they depend on each other are returned separately.
+func orderByDependencies(messages []ruleMessage, results map[ruleKey]dependencyResult) ([]ruleMessage, []ruleMessage) {
+	ordered := []ruleMessage{}
+	done := map[ruleKey]bool{}
+	for key := range results {
+		done[key] = true
+	}
+	remaining := messages
+	for len(remaining) > 0 {
+		next := []ruleMessage{}
+		for _, m := range remaining {
+			ready := true
+			for _, dependency := range m.dependencies {
+				if !done[dependency.key] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, m)
+				done[ruleKey{ruleSet: m.ruleSetName, ruleID: m.rule.RuleID}] = true
+			} else {
+				next = append(next, m)
+			}
+		}
+		if len(next) == len(remaining) {
+			return ordered, next
+		}
+		remaining = next
+	}
+	return ordered, nil
+}
+
+// dependencyTemplates collects the results of the rules a rule depends on so that
+// its conditions can refer to them by the name they were given in dependsOn. When
+// one of the dependencies did not match or failed, the rule should not run and
+// its outcome is returned instead.
+func dependencyTemplates(m ruleMessage, results map[ruleKey]dependencyResult) (map[string]ChainTemplate, *dependencyResult) {
+	templates := map[string]ChainTemplate{}
+	for _, dependency := range m.dependencies {
+		if dependency.tagsOnly {
+			continue
+		}
+		result, ok := results[dependency.key]
+		if !ok {
+			return nil, &dependencyResult{err: fmt.Errorf("rule %s that this rule depends on did not run", dependency.name)}
+		}
+		if isRuleCanceled(result.err) {
+			return nil, &dependencyResult{err: &RuleCanceledError{RuleID: m.rule.RuleID}}
+		}
+		if result.err != nil {
+			return nil, &dependencyResult{err: fmt.Errorf("rule %s that this rule depends on failed", dependency.name)}
+		}
+		if !result.matched {
+			return nil, &dependencyResult{}
+		}
+		templates[dependency.name] = result.template
+	}
+	return templates, nil
+}
+
+// pendingDependency returns a dependency of the rule that has not completed yet.
+func pendingDependency(m ruleMessage, results map[ruleKey]dependencyResult) (ruleKey, bool) {
+	for _, dependency := range m.dependencies {
+		if _, ok := results[dependency.key]; !ok {
+			return dependency.key, true
+		}
+	}
+	return ruleKey{}, false
+}
+
+func newDependencyResult(response ConditionResponse) dependencyResult {
+	return dependencyResult{
+		matched: true,
+		template: ChainTemplate{
+			Filepaths: incidentsToFilepaths(response.Incidents),
+			Extras:    response.TemplateContext,
+		},
+	}
+}
+
+// recordDependencyOutcome records a rule that did not run because of the rules it depends on.
+func (r *ruleEngine) recordDependencyOutcome(m ruleMessage, outcome dependencyResult, mapRuleSets map[string]*konveyor.RuleSet) {
+	rs, ok := mapRuleSets[m.ruleSetName]
+	if !ok {
+		return
+	}
+	if outcome.err != nil {
+		recordRuleError(rs, m.rule.RuleID, outcome.err)
+		return
+	}
+	r.logger.V(5).Info("rule depends on a rule that did not match, not running it", "ruleID", m.rule.RuleID)
+	rs.Unmatched = append(rs.Unmatched, m.rule.RuleID)
+}
+
+// failCyclicRules records an error for rules that can never run because they
+// depend on each other.
+func (r *ruleEngine) failCyclicRules(rules []ruleMessage, mapRuleSets map[string]*konveyor.RuleSet, results map[ruleKey]dependencyResult) {
+	for _, m := range rules {
+		err := fmt.Errorf("rule is part of or depends on a cycle of rule dependencies")
+		r.logger.Error(err, "unable to run rule", "ruleID", m.rule.RuleID)
+		r.recordDependencyOutcome(m, dependencyResult{err: err}, mapRuleSets)
+		results[ruleKey{ruleSet: m.ruleSetName, ruleID: m.rule.RuleID}] = dependencyResult{err: err}
+	}
+}
+
+// tagProducer holds the tags a tagging rule can create, templated tags are only
+// known once the rule matched so they could be any tag.
+type tagProducer struct {
+	key       ruleKey
+	tags      map[string]bool
+	templated bool
+}
+
+func newTagProducer(m ruleMessage) tagProducer {
+	p := tagProducer{
+		key:  ruleKey{ruleSet: m.ruleSetName, ruleID: m.rule.RuleID},
+		tags: map[string]bool{},
+	}
+	for _, tagString := range m.rule.Perform.Tag {
+		if isTemplatedTag(tagString) {
+			p.templated = true
+			continue
+		}
+		tags, err := parseTagsFromPerformString(tagString)
+		if err != nil {
+			continue
+		}
+		for _, tag := range tags {
+			p.tags[tag] = true
+		}
+	}
+	return p
+}
+
+func (p tagProducer) creates(tag string) bool {
+	return p.templated || isTemplatedTag(tag) || p.tags[tag]
+}
+
+func isTemplatedTag(tag string) bool {
+	return strings.Contains(tag, "{{") && strings.Contains(tag, "}}")
+}
+
+// addTagDependencies makes the rules that use tags wait for the tagging rules that
+// can create them. Tagging rules only wait for the tagging rules before them, so
+// they see the same tags as when tagging rules ran one after the other.
+func addTagDependencies(taggingRules, otherRules []ruleMessage) []ruleMessage {
+	producers := make([]tagProducer, len(taggingRules))
+	for i, m := range taggingRules {
+		producers[i] = newTagProducer(m)
+	}
+	rules := make([]ruleMessage, 0, len(taggingRules)+len(otherRules))
+	for i, m := range taggingRules {
+		m.dependencies = appendTagDependencies(m, producers[:i])
+		rules = append(rules, m)
+	}
+	for _, m := range otherRules {
+		m.dependencies = appendTagDependencies(m, producers)
+		rules = append(rules, m)
+	}
+	return rules
+}
+
+func appendTagDependencies(m ruleMessage, producers []tagProducer) []ruleDependency {
+	dependencies := append([]ruleDependency{}, m.dependencies...)
+	if len(m.rule.UsesTags) == 0 {
+		return dependencies
+	}
+	for _, p := range producers {
+		for _, tag := range m.rule.UsesTags {
+			if p.creates(tag) {
+				dependencies = append(dependencies, ruleDependency{key: p.key, tagsOnly: true})
+				break
+			}
+		}
+	}
+	return dependencies
+}
+
+// dependencyTags returns the tags created by the rules a rule depends on and by
+// the rules they depend on, they are the tags of the context of the rule.
+func dependencyTags(m ruleMessage, results map[ruleKey]dependencyResult) map[string]interface{} {
+	tags := map[string]interface{}{}
+	for _, dependency := range m.dependencies {
+		maps.Copy(tags, results[dependency.key].tags)
+	}
+	return tags
+}
@@ -0,0 +1,323 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"go.lsp.dev/uri"
+)
+
+type testDependentConditional struct {
+	file string
+	from string
+}
+
+func (t testDependentConditional) Evaluate(ctx context.Context, log logr.Logger, condCtx ConditionContext) (ConditionResponse, error) {
+	if t.from != "" {
+		template, ok := condCtx.Template[t.from]
+		if !ok || len(template.Filepaths) == 0 {
+			return ConditionResponse{}, fmt.Errorf("unable to find results of %s", t.from)
+		}
+	}
+	if t.file == "" {
+		return ConditionResponse{}, nil
+	}
+	return ConditionResponse{
+		Matched:   true,
+		Incidents: []IncidentContext{{FileURI: uri.File(t.file)}},
+	}, nil
+}
+
+func TestValidateRuleDependencies(t *testing.T) {
+	tag := []string{"Java"}
+	rule := func(id string, tags []string, dependsOn ...string) Rule {
+		return Rule{
+			RuleMeta:  RuleMeta{RuleID: id},
+			Perform:   Perform{Tag: tags},
+			DependsOn: dependsOn,
+		}
+	}
+	tests := []struct {
+		name     string
+		ruleSets []RuleSet
+		wantErr  bool
+	}{
+		{
+			name: "dependencies in the same and other rulesets",
+			ruleSets: []RuleSet{
+				{Name: "builtin", Rules: []Rule{rule("file-001", nil)}},
+				{Name: "java", Rules: []Rule{
+					rule("java-001", nil, "builtin/file-001"),
+					rule("java-002", nil, "java-001", "tag-001"),
+					rule("tag-001", tag),
+				}},
+			},
+		},
+		{
+			name: "unknown dependency",
+			ruleSets: []RuleSet{
+				{Name: "java", Rules: []Rule{rule("java-001", nil, "builtin/file-001")}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "tagging rule depending on other rule",
+			ruleSets: []RuleSet{
+				{Name: "java", Rules: []Rule{
+					rule("java-001", nil),
+					rule("tag-001", tag, "java-001"),
+				}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cycle",
+			ruleSets: []RuleSet{
+				{Name: "java", Rules: []Rule{
+					rule("java-001", nil, "java-003"),
+					rule("java-002", nil, "java-001"),
+					rule("java-003", nil, "java-002"),
+				}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateRuleDependencies(tt.ruleSets); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRuleDependencies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunRulesWithDependencies(t *testing.T) {
+	message := "found"
+	effort := 1
+	rule := func(id string, when Conditional, dependsOn ...string) Rule {
+		return Rule{
+			RuleMeta:  RuleMeta{RuleID: id, Effort: &effort},
+			Perform:   Perform{Message: Message{Text: &message}},
+			When:      when,
+			DependsOn: dependsOn,
+		}
+	}
+	ruleSets := []RuleSet{
+		{
+			Name: "builtin",
+			Rules: []Rule{
+				rule("file-001", testDependentConditional{file: "/app/pom.xml"}),
+				rule("file-002", testDependentConditional{}),
+			},
+		},
+		{
+			Name: "java",
+			Rules: []Rule{
+				rule("java-003", testDependentConditional{file: "/app/Main.java", from: "java-001"}, "java-001"),
+				rule("java-001", testDependentConditional{file: "/app/Main.java", from: "builtin/file-001"}, "builtin/file-001"),
+				rule("java-002", testDependentConditional{file: "/app/Main.java"}, "builtin/file-002"),
+				rule("java-004", testDependentConditional{file: "/app/Main.java"}, "java-002"),
+				rule("java-005", testDependentConditional{file: "/app/Main.java"}, "java-006"),
+				rule("java-006", testDependentConditional{file: "/app/Main.java"}, "java-005"),
+				{
+					RuleMeta:  RuleMeta{RuleID: "tag-001"},
+					Perform:   Perform{Tag: []string{"Java"}},
+					When:      testDependentConditional{file: "/app/Main.java"},
+					DependsOn: []string{"builtin/file-001"},
+				},
+			},
+		},
+	}
+
+	eng := CreateRuleEngine(context.Background(), 2, logr.Discard())
+	defer eng.Stop()
+	results := eng.RunRules(context.Background(), ruleSets)
+
+	for _, rs := range results {
+		matched := []string{}
+		for ruleID := range rs.Violations {
+			matched = append(matched, ruleID)
+		}
+		sort.Strings(matched)
+		sort.Strings(rs.Unmatched)
+		errored := []string{}
+		for ruleID := range rs.Errors {
+			errored = append(errored, ruleID)
+		}
+		sort.Strings(errored)
+
+		switch rs.Name {
+		case "builtin":
+			if !reflect.DeepEqual(matched, []string{"file-001"}) || !reflect.DeepEqual(rs.Unmatched, []string{"file-002"}) {
+				t.Errorf("unexpected builtin results, matched %v, unmatched %v", matched, rs.Unmatched)
+			}
+		case "java":
+			if !reflect.DeepEqual(matched, []string{"java-001", "java-003"}) {
+				t.Errorf("expected rules with matched dependencies to run with their results, got %v, errors %v", matched, rs.Errors)
+			}
+			if !reflect.DeepEqual(rs.Unmatched, []string{"java-002", "java-004"}) {
+				t.Errorf("expected rules with unmatched dependencies to be unmatched, got %v", rs.Unmatched)
+			}
+			if !reflect.DeepEqual(errored, []string{"java-005", "java-006", "tag-001"}) {
+				t.Errorf("expected rules in a dependency cycle to fail, got %v", rs.Errors)
+			}
+			if !strings.Contains(rs.Errors["java-005"], "cycle") {
+				t.Errorf("expected an error about the cycle, got %s", rs.Errors["java-005"])
+			}
+			if !strings.Contains(rs.Errors["tag-001"], "can only depend on other rules that create tags") {
+				t.Errorf("expected an error about the tagging rule depending on another rule, got %s", rs.Errors["tag-001"])
+			}
+		default:
+			t.Errorf("unexpected ruleset %s", rs.Name)
+		}
+	}
+}
+
+type testTaggingConditional struct {
+	started *sync.WaitGroup
+}
+
+func (t testTaggingConditional) Evaluate(ctx context.Context, log logr.Logger, condCtx ConditionContext) (ConditionResponse, error) {
+	// Only returns once every tagging rule started, which never happens when they run one after the other.
+	t.started.Done()
+	waited := make(chan struct{})
+	go func() {
+		t.started.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		return ConditionResponse{}, fmt.Errorf("tagging rules did not run concurrently")
+	}
+	return ConditionResponse{
+		Matched:   true,
+		Incidents: []IncidentContext{{FileURI: uri.File("/app/pom.xml")}},
+	}, nil
+}
+
+type testTagConsumerConditional struct {
+	tag string
+}
+
+func (t testTagConsumerConditional) Evaluate(ctx context.Context, log logr.Logger, condCtx ConditionContext) (ConditionResponse, error) {
+	if _, ok := condCtx.Tags[t.tag]; !ok {
+		return ConditionResponse{}, nil
+	}
+	return ConditionResponse{
+		Matched:   true,
+		Incidents: []IncidentContext{{FileURI: uri.File("/app/Main.java")}},
+	}, nil
+}
+
+func TestRunTaggingRulesConcurrently(t *testing.T) {
+	message := "found"
+	effort := 1
+	started := &sync.WaitGroup{}
+	started.Add(2)
+	ruleSets := []RuleSet{
+		{
+			Name: "tech",
+			Rules: []Rule{
+				{
+					RuleMeta: RuleMeta{RuleID: "tag-001"},
+					Perform:  Perform{Tag: []string{"Java"}},
+					When:     testTaggingConditional{started: started},
+				},
+				{
+					RuleMeta: RuleMeta{RuleID: "tag-002"},
+					Perform:  Perform{Tag: []string{"Language=Maven"}},
+					When:     testTaggingConditional{started: started},
+				},
+				{
+					RuleMeta: RuleMeta{RuleID: "uses-tag-001", Effort: &effort},
+					Perform:  Perform{Message: Message{Text: &message}},
+					When:     testTagConsumerConditional{tag: "Maven"},
+					UsesTags: []string{"Maven"},
+				},
+			},
+		},
+	}
+
+	eng := CreateRuleEngine(context.Background(), 2, logr.Discard())
+	defer eng.Stop()
+	results := eng.RunRules(context.Background(), ruleSets)
+	if len(results) != 1 {
+		t.Fatalf("expected a single ruleset, got %d", len(results))
+	}
+	rs := results[0]
+	if len(rs.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", rs.Errors)
+	}
+	sort.Strings(rs.Tags)
+	if !reflect.DeepEqual(rs.Tags, []string{"Java", "Language=Maven"}) {
+		t.Errorf("unexpected tags %v", rs.Tags)
+	}
+	if _, ok := rs.Violations["uses-tag-001"]; !ok {
+		t.Errorf("expected the rule using tags to see the tags, unmatched %v", rs.Unmatched)
+	}
+}
+
+type testContextTagsConditional struct {
+	tags chan map[string]interface{}
+}
+
+func (t testContextTagsConditional) Evaluate(ctx context.Context, log logr.Logger, condCtx ConditionContext) (ConditionResponse, error) {
+	t.tags <- condCtx.Tags
+	return ConditionResponse{
+		Matched:   true,
+		Incidents: []IncidentContext{{FileURI: uri.File("/app/Main.java")}},
+	}, nil
+}
+
+func TestRunRulesPassesDependencyTags(t *testing.T) {
+	message := "found"
+	effort := 1
+	used := make(chan map[string]interface{}, 1)
+	dependent := make(chan map[string]interface{}, 1)
+	ruleSets := []RuleSet{
+		{
+			Name: "tech",
+			Rules: []Rule{
+				{
+					RuleMeta: RuleMeta{RuleID: "tag-001"},
+					Perform:  Perform{Tag: []string{"Java", "Language=Maven"}},
+					When:     testContextTagsConditional{tags: make(chan map[string]interface{}, 1)},
+				},
+				{
+					RuleMeta: RuleMeta{RuleID: "uses-tag-001", Effort: &effort},
+					Perform:  Perform{Message: Message{Text: &message}},
+					When:     testContextTagsConditional{tags: used},
+					UsesTags: []string{"Java"},
+				},
+				{
+					RuleMeta:  RuleMeta{RuleID: "depends-001", Effort: &effort},
+					Perform:   Perform{Message: Message{Text: &message}},
+					When:      testContextTagsConditional{tags: dependent},
+					DependsOn: []string{"uses-tag-001"},
+				},
+			},
+		},
+	}
+
+	eng := CreateRuleEngine(context.Background(), 2, logr.Discard())
+	defer eng.Stop()
+	results := eng.RunRules(context.Background(), ruleSets)
+	if len(results) != 1 || len(results[0].Errors) != 0 {
+		t.Fatalf("unexpected results %v", results)
+	}
+	expected := map[string]interface{}{"Java": true, "Maven": true}
+	if tags := <-used; !reflect.DeepEqual(tags, expected) {
+		t.Errorf("expected the rule using a tag to get every tag of the tagging rule, got %v", tags)
+	}
+	if tags := <-dependent; !reflect.DeepEqual(tags, expected) {
+		t.Errorf("expected the tags to be passed on to the rules depending on the rule, got %v", tags)
+	}
+}
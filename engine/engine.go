@@ -1,17 +1,18 @@
 package engine
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"net/url"
-	"os"
 	"path/filepath"
 	"regexp"
-	"strconv"
+	"runtime/debug"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"go.lsp.dev/uri"
 	"go.opentelemetry.io/otel"
@@ -33,10 +34,16 @@ type RuleEngine interface {
 }
 
 type ruleMessage struct {
+	// ctx is the context of the run, a rule whose run is canceled before a
+	// worker takes it is not evaluated.
+	ctx              context.Context
 	rule             Rule
 	ruleSetName      string
 	conditionContext ConditionContext
 	scope            Scope
+	cache            RuleCache
+	dependencies     []ruleDependency
+	templates        map[string]ChainTemplate
 	returnChan       chan response
 	carrier          propagation.TextMapCarrier
 }
@@ -46,6 +53,7 @@ type response struct {
 	Err               error             `yaml:"err"`
 	Rule              Rule              `yaml:"rule"`
 	RuleSetName       string
+	stats             *ruleStats
 }
 
 type ruleEngine struct {
@@ -61,6 +69,16 @@ type ruleEngine struct {
 	contextLines     int
 	incidentSelector string
 	locationPrefixes []string
+	cache            RuleCache
+	baseline         *baseline
+	stats            bool
+	sink             ViolationSink
+	sinkOnly         bool
+	snippetOptions   SnippetOptions
+	files            *fileCache
+	// abandoned counts the evaluations of rules that timed out and are still
+	// running.
+	abandoned *atomic.Int64
 }
 
 type Option func(engine *ruleEngine)
@@ -95,18 +113,48 @@ func WithLocationPrefixes(location []string) Option {
 	}
 }
 
+func WithRuleCache(cache RuleCache) Option {
+	return func(engine *ruleEngine) {
+		engine.cache = cache
+	}
+}
+
+// WithBaseline only reports incidents that are not found in the output of an
+// earlier run.
+func WithBaseline(ruleSets []konveyor.RuleSet) Option {
+	return func(engine *ruleEngine) {
+		engine.baseline = newBaseline(ruleSets)
+	}
+}
+
+// WithRuleStats adds how long each rule took to evaluate, how many provider
+// calls it made and how many incidents it found to the stats of its ruleset.
+func WithRuleStats() Option {
+	return func(engine *ruleEngine) {
+		engine.stats = true
+	}
+}
+
 func CreateRuleEngine(ctx context.Context, workers int, log logr.Logger, options ...Option) RuleEngine {
 	// Only allow for 10 rules to be waiting in the buffer at once.
 	// Adding more workers will increase the number of rules running at once.
 	ruleProcessor := make(chan ruleMessage, 10)
 
-	ctx, cancelFunc := context.WithCancel(ctx)
+	abandoned := &atomic.Int64{}
+	ctx, cancelCtx := context.WithCancel(withAbandonedEvaluations(ctx, abandoned))
+	// Workers run until the engine is stopped rather than until ctx is done,
+	// the rules that are still queued once ctx is done must be answered.
+	stop, stopWorkers := context.WithCancel(context.Background())
+	cancelFunc := func() {
+		cancelCtx()
+		stopWorkers()
+	}
 	wg := &sync.WaitGroup{}
 
 	for i := 0; i < workers; i++ {
 		logger := log.WithValues("worker", i)
 		wg.Add(1)
-		go processRuleWorker(ctx, ruleProcessor, logger, wg)
+		go processRuleWorker(ctx, stop, ruleProcessor, logger, wg)
 	}
 
 	r := &ruleEngine{
@@ -114,6 +162,8 @@ func CreateRuleEngine(ctx context.Context, workers int, log logr.Logger, options
 		cancelFunc:     cancelFunc,
 		logger:         log,
 		wg:             wg,
+		files:          newFileCache(fileCacheSize, fileCacheBytes),
+		abandoned:      abandoned,
 	}
 	for _, o := range options {
 		o(r)
@@ -125,32 +175,49 @@ func (r *ruleEngine) Stop() {
 	r.cancelFunc()
 	r.logger.V(5).Info("rule engine stopping")
 	r.wg.Wait()
+	if count := r.abandoned.Load(); count > 0 {
+		r.logger.Info("conditions of rules that timed out are still running", "abandonedEvaluations", count)
+	}
 }
 
-func processRuleWorker(ctx context.Context, ruleMessages chan ruleMessage, logger logr.Logger, wg *sync.WaitGroup) {
+func processRuleWorker(ctx context.Context, stop context.Context, ruleMessages chan ruleMessage, logger logr.Logger, wg *sync.WaitGroup) {
 	prop := otel.GetTextMapPropagator()
 	for {
 		select {
 		case m := <-ruleMessages:
+			if ctx.Err() != nil || (m.ctx != nil && m.ctx.Err() != nil) {
+				logger.V(5).Info("rule canceled before it started", "ruleset", m.ruleSetName, "rule", m.rule.RuleID)
+				m.returnChan <- response{
+					Err:         &RuleCanceledError{RuleID: m.rule.RuleID},
+					Rule:        m.rule,
+					RuleSetName: m.ruleSetName,
+				}
+				continue
+			}
 			logger.V(5).Info("taking rule", "ruleset", m.ruleSetName, "rule", m.rule.RuleID)
 			newLogger := logger.WithValues("ruleID", m.rule.RuleID)
 			//We createa new rule context for a every rule run, here we need to apply the scope
 			m.conditionContext.Template = make(map[string]ChainTemplate)
+			for name, template := range m.templates {
+				m.conditionContext.Template[name] = template
+			}
 			if m.scope != nil {
 				m.scope.AddToContext(&m.conditionContext)
 			}
 			logger.Info("Adding Carrier span info to context")
-			ctx = prop.Extract(ctx, m.carrier)
-
-			bo, err := processRule(ctx, m.rule, m.conditionContext, newLogger)
+			ruleCtx, stats := withRuleStats(prop.Extract(ctx, m.carrier))
+			start := time.Now()
+			bo, err := processRuleWithCache(ruleCtx, m.rule, m.conditionContext, newLogger, m.cache)
+			stats.duration = time.Since(start)
 			logger.V(5).Info("finished rule", "found", len(bo.Incidents), "error", err, "rule", m.rule.RuleID)
 			m.returnChan <- response{
 				ConditionResponse: bo,
 				Err:               err,
 				Rule:              m.rule,
 				RuleSetName:       m.ruleSetName,
+				stats:             stats,
 			}
-		case <-ctx.Done():
+		case <-stop.Done():
 			logger.V(5).Info("stopping rule worker")
 			wg.Done()
 			return
@@ -168,12 +235,14 @@ func (r *ruleEngine) createRuleSet(ruleSet RuleSet) *konveyor.RuleSet {
 		Errors:      map[string]string{},
 		Unmatched:   []string{},
 		Skipped:     []string{},
+		Provenance:  ruleSet.Provenance,
 	}
 	return rs
 }
 
-// This will run tagging rules first, synchronously, generating tags to pass on further as context to other rules
-// then runs remaining rules async, fanning them out, fanning them in, finally generating the results. will block until completed.
+// This will run all the rules async on the workers, fanning them out, fanning them in, finally generating the results.
+// Rules that look up tags wait for the tagging rules that can create them and rules wait for the rules they depend on,
+// every other rule runs right away. will block until completed.
 func (r *ruleEngine) RunRules(ctx context.Context, ruleSets []RuleSet, selectors ...RuleSelector) []konveyor.RuleSet {
 	return r.RunRulesScoped(ctx, ruleSets, nil, selectors...)
 }
@@ -202,8 +271,21 @@ func (r *ruleEngine) RunRulesScoped(ctx context.Context, ruleSets []RuleSet, sco
 	ctx, cancelFunc := context.WithCancel(ctx)
 
 	taggingRules, otherRules, mapRuleSets := r.filterRules(ruleSets, selectors...)
+	taggingRules, otherRules, results := r.resolveDependencies(taggingRules, otherRules, mapRuleSets)
+	taggingRules, cyclicRules := orderByDependencies(taggingRules, results)
+	r.failCyclicRules(cyclicRules, mapRuleSets, results)
+	taggingKeys := map[ruleKey]bool{}
+	for _, rule := range taggingRules {
+		taggingKeys[ruleKey{ruleSet: rule.ruleSetName, ruleID: rule.rule.RuleID}] = true
+	}
+	rules, cyclicRules := orderByDependencies(addTagDependencies(taggingRules, otherRules), results)
+	r.failCyclicRules(cyclicRules, mapRuleSets, results)
 
-	ruleContext := r.runTaggingRules(ctx, taggingRules, mapRuleSets, conditionContext, scopes)
+	// Tags given to the rules that were scheduled, a rule passes them on
+	// to the rules that depend on it together with the tags it creates.
+	contextTags := map[ruleKey]map[string]interface{}{}
+	// track unique tags per ruleset
+	rulesetTagsCache := map[string]map[string]bool{}
 
 	// Need a better name for this thing
 	ret := make(chan response)
@@ -213,6 +295,82 @@ func (r *ruleEngine) RunRulesScoped(ctx context.Context, ruleSets []RuleSet, sco
 	var failedRules int32
 
 	wg := &sync.WaitGroup{}
+	wg.Add(len(rules))
+	done := make(chan struct{})
+
+	// Once the run is canceled, rules that did not start yet are reported as
+	// canceled, rules that already started are still waited for.
+	send := func(rule ruleMessage) {
+		if ctx.Err() == nil {
+			select {
+			case r.ruleProcessing <- rule:
+				return
+			case <-ctx.Done():
+			}
+		}
+		ret <- response{
+			Err:         &RuleCanceledError{RuleID: rule.rule.RuleID},
+			Rule:        rule.rule,
+			RuleSetName: rule.ruleSetName,
+		}
+	}
+
+	// Rules that depend on other rules wait for them to complete, the rules
+	// waiting on a rule are released by the response handler once it completes.
+	waiting := map[ruleKey][]ruleMessage{}
+	readyRules := []ruleMessage{}
+	var release func(key ruleKey, result dependencyResult)
+	schedule := func(rule ruleMessage, async bool) {
+		if pending, ok := pendingDependency(rule, results); ok {
+			waiting[pending] = append(waiting[pending], rule)
+			return
+		}
+		templates, outcome := dependencyTemplates(rule, results)
+		if outcome != nil {
+			r.recordDependencyOutcome(rule, *outcome, mapRuleSets)
+			wg.Done()
+			release(ruleKey{ruleSet: rule.ruleSetName, ruleID: rule.rule.RuleID}, *outcome)
+			return
+		}
+		newContext := conditionContext.Copy()
+		newContext.RuleID = rule.rule.RuleID
+		newContext.Tags = dependencyTags(rule, results)
+		contextTags[ruleKey{ruleSet: rule.ruleSetName, ruleID: rule.rule.RuleID}] = newContext.Tags
+		rule.conditionContext = newContext
+		rule.ctx = ctx
+		rule.templates = templates
+		rule.returnChan = ret
+		rule.scope = scopes
+		rule.cache = r.cache
+		rule.carrier = carrier
+		if async {
+			// The response handler can not wait for a worker, the workers may be waiting on it.
+			go send(rule)
+			return
+		}
+		readyRules = append(readyRules, rule)
+	}
+	release = func(key ruleKey, result dependencyResult) {
+		tags := maps.Clone(contextTags[key])
+		if tags == nil {
+			tags = map[string]interface{}{}
+		}
+		maps.Copy(tags, result.tags)
+		result.tags = tags
+		results[key] = result
+		dependents := waiting[key]
+		delete(waiting, key)
+		for _, rule := range dependents {
+			schedule(rule, true)
+		}
+	}
+	for _, rule := range rules {
+		schedule(rule, false)
+	}
+
+	// The results of rules that complete after the run is canceled are still reported.
+	handlerCtx := context.WithoutCancel(ctx)
+
 	// Handle returns
 	go func() {
 		for {
@@ -221,15 +379,30 @@ func (r *ruleEngine) RunRulesScoped(ctx context.Context, ruleSets []RuleSet, sco
 				func() {
 					r.logger.Info("rule returned", "ruleID", response.Rule.RuleID)
 					defer wg.Done()
+					key := ruleKey{ruleSet: response.RuleSetName, ruleID: response.Rule.RuleID}
+					result := dependencyResult{}
+					defer func() {
+						// The message part of a tagging rule does not release the rules waiting on its tags.
+						if response.Rule.Perform.Tag == nil && taggingKeys[key] {
+							return
+						}
+						release(key, result)
+					}()
+					if response.Rule.Perform.Tag != nil {
+						result = r.handleTaggingResponse(handlerCtx, response, mapRuleSets, rulesetTagsCache, scopes)
+						return
+					}
 					if response.Err != nil {
 						atomic.AddInt32(&failedRules, 1)
-						r.logger.Error(response.Err, "failed to evaluate rule", "ruleID", response.Rule.RuleID)
+						r.logRuleError(response)
+						result.err = response.Err
 
 						if rs, ok := mapRuleSets[response.RuleSetName]; ok {
-							rs.Errors[response.Rule.RuleID] = response.Err.Error()
+							recordRuleError(rs, response.Rule.RuleID, response.Err)
+							r.recordStats(rs, response, 0, 0)
 						}
 					} else if response.ConditionResponse.Matched && len(response.ConditionResponse.Incidents) > 0 {
-						violation, err := r.createViolation(ctx, response.ConditionResponse, response.Rule, scopes)
+						violation, err := r.createViolation(handlerCtx, response.ConditionResponse, response.Rule, scopes)
 						if err != nil {
 							r.logger.Error(err, "unable to create violation from response", "ruleID", response.Rule.RuleID)
 						}
@@ -238,9 +411,11 @@ func (r *ruleEngine) RunRulesScoped(ctx context.Context, ruleSets []RuleSet, sco
 							atomic.AddInt32(&unmatchedRules, 1)
 							if rs, ok := mapRuleSets[response.RuleSetName]; ok {
 								rs.Unmatched = append(rs.Unmatched, response.Rule.RuleID)
+								r.recordStats(rs, response, len(response.ConditionResponse.Incidents), 0)
 							}
 						} else {
 							atomic.AddInt32(&matchedRules, 1)
+							result = newDependencyResult(response.ConditionResponse)
 							rs, ok := mapRuleSets[response.RuleSetName]
 							if !ok {
 								r.logger.Info("this should never happen that we don't find the ruleset")
@@ -248,10 +423,11 @@ func (r *ruleEngine) RunRulesScoped(ctx context.Context, ruleSets []RuleSet, sco
 							}
 							// when a rule has 0 effort, we should create an insight instead
 							if response.Rule.Effort == nil || *response.Rule.Effort == 0 {
-								rs.Insights[response.Rule.RuleID] = violation
+								r.addViolation(rs, response.Rule.RuleID, violation, true)
 							} else {
-								rs.Violations[response.Rule.RuleID] = violation
+								r.addViolation(rs, response.Rule.RuleID, violation, false)
 							}
+							r.recordStats(rs, response, len(response.ConditionResponse.Incidents), len(violation.Incidents))
 						}
 					} else {
 						atomic.AddInt32(&unmatchedRules, 1)
@@ -260,42 +436,35 @@ func (r *ruleEngine) RunRulesScoped(ctx context.Context, ruleSets []RuleSet, sco
 
 						if rs, ok := mapRuleSets[response.RuleSetName]; ok {
 							rs.Unmatched = append(rs.Unmatched, response.Rule.RuleID)
+							r.recordStats(rs, response, len(response.ConditionResponse.Incidents), 0)
 						}
 					}
-					r.logger.V(5).Info("rule response received", "total", len(otherRules), "failed", failedRules, "matched", matchedRules, "unmatched", unmatchedRules)
+					r.logger.V(5).Info("rule response received", "total", len(rules), "failed", failedRules, "matched", matchedRules, "unmatched", unmatchedRules)
 
 				}()
-			case <-ctx.Done():
-				// At this point we should just return the function, we may want to close the wait group too.
+			case <-done:
 				return
 			}
 		}
 	}()
 
-	for _, rule := range otherRules {
-		newContext := ruleContext.Copy()
-		newContext.RuleID = rule.rule.RuleID
-		wg.Add(1)
-		rule.returnChan = ret
-		rule.conditionContext = newContext
-		rule.scope = scopes
-		rule.carrier = carrier
-		r.ruleProcessing <- rule
+	for _, rule := range readyRules {
+		send(rule)
 	}
-	r.logger.V(5).Info("All rules added buffer, waiting for engine to complete", "size", len(otherRules))
+	r.logger.V(5).Info("All rules added buffer, waiting for engine to complete", "size", len(rules))
 
-	done := make(chan struct{})
 	go func() {
 		defer close(done)
 		wg.Wait()
 	}()
 
-	// Wait for all the rules to process
-	select {
-	case <-done:
-		r.logger.V(2).Info("done processing all the rules")
-	case <-ctx.Done():
+	// Wait for all the rules to process, when the run is canceled this waits
+	// for the rules that already started to complete or time out.
+	<-done
+	if ctx.Err() != nil {
 		r.logger.V(1).Info("processing of rules was canceled")
+	} else {
+		r.logger.V(2).Info("done processing all the rules")
 	}
 	responses := []konveyor.RuleSet{}
 	for _, ruleSet := range mapRuleSets {
@@ -310,7 +479,7 @@ func (r *ruleEngine) RunRulesScoped(ctx context.Context, ruleSets []RuleSet, sco
 
 // filterRules splits rules into tagging and other rules
 func (r *ruleEngine) filterRules(ruleSets []RuleSet, selectors ...RuleSelector) ([]ruleMessage, []ruleMessage, map[string]*konveyor.RuleSet) {
-	// filter rules that generate tags, they run first
+	// filter rules that generate tags, the rules that use their tags wait for them
 	taggingRules := []ruleMessage{}
 	mapRuleSets := map[string]*konveyor.RuleSet{}
 	// all rules except meta
@@ -355,91 +524,102 @@ func (r *ruleEngine) filterRules(ruleSets []RuleSet, selectors ...RuleSelector)
 	return taggingRules, otherRules, mapRuleSets
 }
 
-// runTaggingRules filters and runs info rules synchronously
-// returns list of non-info rules, a context to pass to them
-func (r *ruleEngine) runTaggingRules(ctx context.Context, infoRules []ruleMessage, mapRuleSets map[string]*konveyor.RuleSet, context ConditionContext, scope Scope) ConditionContext {
-	// track unique tags per ruleset
-	rulesetTagsCache := map[string]map[string]bool{}
-	for _, ruleMessage := range infoRules {
-		rule := ruleMessage.rule
-		response, err := processRule(ctx, rule, context, r.logger)
-		if err != nil {
-			r.logger.Error(err, "failed to evaluate rule", "ruleID", rule.RuleID)
-			if rs, ok := mapRuleSets[ruleMessage.ruleSetName]; ok {
-				rs.Errors[rule.RuleID] = err.Error()
-			}
-		} else if response.Matched && len(response.Incidents) > 0 {
-			r.logger.V(5).Info("info rule was matched", "ruleID", rule.RuleID)
-			tags := map[string]bool{}
-			for _, tagString := range rule.Perform.Tag {
-				if strings.Contains(tagString, "{{") && strings.Contains(tagString, "}}") {
-					for _, incident := range response.Incidents {
-						// If this is the case then we neeed to use the reponse variables to get the tag
-						variables := make(map[string]interface{})
-						for key, value := range incident.Variables {
-							variables[key] = value
-						}
-						if incident.LineNumber != nil {
-							variables["lineNumber"] = *incident.LineNumber
-						}
-						templateString, err := r.createPerformString(tagString, variables)
-						if err != nil {
-							r.logger.Error(err, "unable to create tag string", "ruleID", rule.RuleID)
-							continue
-						}
-						tags[templateString] = true
-					}
-				} else {
-					tags[tagString] = true
-				}
-				for t := range tags {
-					tags, err := parseTagsFromPerformString(t)
-					if err != nil {
-						r.logger.Error(err, "unable to create tags", "ruleID", rule.RuleID)
-						continue
-					}
-					for _, tag := range tags {
-						context.Tags[tag] = true
-					}
+// handleTaggingResponse returns the tags created by a tagging rule, so they are
+// passed on to the rules that depend on it, and creates an insight for it.
+func (r *ruleEngine) handleTaggingResponse(ctx context.Context, response response, mapRuleSets map[string]*konveyor.RuleSet, rulesetTagsCache map[string]map[string]bool, scope Scope) dependencyResult {
+	rule := response.Rule
+	if response.Err != nil {
+		r.logRuleError(response)
+		if rs, ok := mapRuleSets[response.RuleSetName]; ok {
+			recordRuleError(rs, rule.RuleID, response.Err)
+			r.recordStats(rs, response, 0, 0)
+		}
+		return dependencyResult{err: response.Err}
+	}
+	if !response.ConditionResponse.Matched || len(response.ConditionResponse.Incidents) == 0 {
+		r.logger.Info("info rule not matched", "rule", rule.RuleID)
+		if rs, ok := mapRuleSets[response.RuleSetName]; ok {
+			rs.Unmatched = append(rs.Unmatched, rule.RuleID)
+			r.recordStats(rs, response, len(response.ConditionResponse.Incidents), 0)
+		}
+		return dependencyResult{}
+	}
+
+	r.logger.V(5).Info("info rule was matched", "ruleID", rule.RuleID)
+	contextTags := map[string]interface{}{}
+	tags := map[string]bool{}
+	for _, tagString := range rule.Perform.Tag {
+		if strings.Contains(tagString, "{{") && strings.Contains(tagString, "}}") {
+			for _, incident := range response.ConditionResponse.Incidents {
+				// If this is the case then we neeed to use the reponse variables to get the tag
+				variables := make(map[string]interface{})
+				for key, value := range incident.Variables {
+					variables[key] = value
 				}
-			}
-			rs, ok := mapRuleSets[ruleMessage.ruleSetName]
-			if !ok {
-				r.logger.Info("this should never happen that we don't find the ruleset")
-			} else {
-				if _, ok := rulesetTagsCache[rs.Name]; !ok {
-					rulesetTagsCache[rs.Name] = make(map[string]bool)
+				if incident.LineNumber != nil {
+					variables["lineNumber"] = *incident.LineNumber
 				}
-				for tag := range tags {
-					if _, ok := rulesetTagsCache[rs.Name][tag]; !ok {
-						rulesetTagsCache[rs.Name][tag] = true
-						rs.Tags = append(rs.Tags, tag)
-					}
+				templateString, err := r.createPerformString(tagString, variables)
+				if err != nil {
+					r.logger.Error(err, "unable to create tag string", "ruleID", rule.RuleID)
+					continue
 				}
-				mapRuleSets[ruleMessage.ruleSetName] = rs
+				tags[templateString] = true
 			}
-			// create an insight for this tag
-			violation, err := r.createViolation(ctx, response, rule, scope)
+		} else {
+			tags[tagString] = true
+		}
+		for t := range tags {
+			tags, err := parseTagsFromPerformString(t)
 			if err != nil {
-				r.logger.Error(err, "unable to create violation from response", "ruleID", rule.RuleID)
+				r.logger.Error(err, "unable to create tags", "ruleID", rule.RuleID)
+				continue
 			}
-			if rs, ok := mapRuleSets[ruleMessage.ruleSetName]; ok {
-				violation.Effort = nil
-				violation.Category = nil
-				// we need to tie these incidents back to tags that created them
-				for tag := range tags {
-					violation.Labels = append(violation.Labels, fmt.Sprintf("tag=%s", tag))
-				}
-				rs.Insights[rule.RuleID] = violation
+			for _, tag := range tags {
+				contextTags[tag] = true
 			}
-		} else {
-			r.logger.Info("info rule not matched", "rule", rule.RuleID)
-			if rs, ok := mapRuleSets[ruleMessage.ruleSetName]; ok {
-				rs.Unmatched = append(rs.Unmatched, rule.RuleID)
+		}
+	}
+	rs, ok := mapRuleSets[response.RuleSetName]
+	if !ok {
+		r.logger.Info("this should never happen that we don't find the ruleset")
+	} else {
+		if _, ok := rulesetTagsCache[rs.Name]; !ok {
+			rulesetTagsCache[rs.Name] = make(map[string]bool)
+		}
+		for tag := range tags {
+			if _, ok := rulesetTagsCache[rs.Name][tag]; !ok {
+				rulesetTagsCache[rs.Name][tag] = true
+				rs.Tags = append(rs.Tags, tag)
 			}
 		}
 	}
-	return context
+	// create an insight for this tag
+	violation, err := r.createViolation(ctx, response.ConditionResponse, rule, scope)
+	if err != nil {
+		r.logger.Error(err, "unable to create violation from response", "ruleID", rule.RuleID)
+	}
+	if rs, ok := mapRuleSets[response.RuleSetName]; ok {
+		violation.Effort = nil
+		violation.Category = nil
+		// we need to tie these incidents back to tags that created them
+		for tag := range tags {
+			violation.Labels = append(violation.Labels, fmt.Sprintf("tag=%s", tag))
+		}
+		r.addViolation(rs, rule.RuleID, violation, true)
+		r.recordStats(rs, response, len(response.ConditionResponse.Incidents), len(violation.Incidents))
+	}
+	result := newDependencyResult(response.ConditionResponse)
+	result.tags = contextTags
+	return result
+}
+
+func (r *ruleEngine) logRuleError(response response) {
+	if isRuleCanceled(response.Err) {
+		r.logger.V(5).Info("rule was not evaluated, the run was canceled", "ruleID", response.Rule.RuleID)
+		return
+	}
+	r.logger.Error(response.Err, "failed to evaluate rule", "ruleID", response.Rule.RuleID)
 }
 
 func parseTagsFromPerformString(tagString string) ([]string, error) {
@@ -458,14 +638,105 @@ func parseTagsFromPerformString(tagString string) ([]string, error) {
 	return tags, nil
 }
 
+type abandonedEvaluationsKey struct{}
+
+// withAbandonedEvaluations counts the evaluations of the rules that timed out
+// and are still running in the counter, see processRule.
+func withAbandonedEvaluations(ctx context.Context, counter *atomic.Int64) context.Context {
+	return context.WithValue(ctx, abandonedEvaluationsKey{}, counter)
+}
+
+// processRule evaluates the rule, within its timeout when it has one. A rule
+// that times out returns right away but its conditions keep running until they
+// return, as a goroutine can not be stopped: providers that do not stop when
+// the context is done keep using resources until they complete. These
+// abandoned evaluations are counted by the counter of withAbandonedEvaluations.
 func processRule(ctx context.Context, rule Rule, ruleCtx ConditionContext, log logr.Logger) (ConditionResponse, error) {
 	ctx, span := tracing.StartNewSpan(
 		ctx, "process-rule", attribute.Key("rule").String(rule.RuleID))
 	defer span.End()
 	// Here is what a worker should run when getting a rule.
 	// For now, lets not fan out the running of conditions.
+	if rule.Timeout <= 0 {
+		return evaluateRule(ctx, rule, ruleCtx, log)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, rule.Timeout)
+	defer cancel()
+	type evaluation struct {
+		response ConditionResponse
+		err      error
+	}
+	abandoned, ok := ctx.Value(abandonedEvaluationsKey{}).(*atomic.Int64)
+	if !ok {
+		abandoned = &atomic.Int64{}
+	}
+	const (
+		running = iota
+		finished
+		abandonedEvaluation
+	)
+	state := &atomic.Int32{}
+	// Providers that do not stop when the context is done would otherwise keep the worker.
+	done := make(chan evaluation, 1)
+	go func() {
+		response, err := evaluateRule(timeoutCtx, rule, ruleCtx, log)
+		done <- evaluation{response: response, err: err}
+		if !state.CompareAndSwap(running, finished) {
+			abandoned.Add(-1)
+		}
+	}()
+	select {
+	case e := <-done:
+		if e.err != nil && ctx.Err() == nil && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+			return ConditionResponse{}, &RuleTimeoutError{RuleID: rule.RuleID, Timeout: rule.Timeout}
+		}
+		return e.response, e.err
+	case <-timeoutCtx.Done():
+		// Counted before it is marked so that the evaluation can not complete
+		// and uncount itself first.
+		count := abandoned.Add(1)
+		if !state.CompareAndSwap(running, abandonedEvaluation) {
+			count = abandoned.Add(-1)
+		}
+		if ctx.Err() != nil {
+			return ConditionResponse{}, ctx.Err()
+		}
+		log.V(3).Info("rule timed out, its conditions keep running until they return", "ruleID", rule.RuleID, "timeout", rule.Timeout, "abandonedEvaluations", count)
+		return ConditionResponse{}, &RuleTimeoutError{RuleID: rule.RuleID, Timeout: rule.Timeout}
+	}
+}
+
+// evaluateRule evaluates the conditions of the rule, a condition that panics
+// fails the rule instead of the analysis.
+func evaluateRule(ctx context.Context, rule Rule, ruleCtx ConditionContext, log logr.Logger) (response ConditionResponse, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Error(fmt.Errorf("%v", recovered), "rule panicked", "ruleID", rule.RuleID, "stack", string(debug.Stack()))
+			response, err = ConditionResponse{}, fmt.Errorf("rule %s panicked: %v", rule.RuleID, recovered)
+		}
+	}()
 	return rule.When.Evaluate(ctx, log, ruleCtx)
+}
 
+// processRuleWithCache will only evaluate the rule when the cache does not
+// have a valid result for it.
+func processRuleWithCache(ctx context.Context, rule Rule, ruleCtx ConditionContext, log logr.Logger, cache RuleCache) (ConditionResponse, error) {
+	if cache == nil {
+		return processRule(ctx, rule, ruleCtx, log)
+	}
+	if response, ok := cache.Get(rule, ruleCtx); ok {
+		markRuleCached(ctx)
+		return response, nil
+	}
+	response, err := processRule(ctx, rule, ruleCtx, log)
+	if err != nil {
+		return response, err
+	}
+	if err := cache.Put(rule, ruleCtx, response); err != nil {
+		log.V(5).Error(err, "unable to cache rule result", "ruleID", rule.RuleID)
+	}
+	return response, nil
 }
 
 func (r *ruleEngine) getRelativePathForViolation(fileURI uri.URI) (uri.URI, error) {
@@ -552,56 +823,36 @@ func (r *ruleEngine) createViolation(ctx context.Context, conditionResponse Cond
 			}
 			fileCodeSnipCount[string(m.FileURI)] += 1
 		}
+		// Incidents without a code snip keep the hash of their line, so that a
+		// baseline identifies them the same way as the ones with a code snip.
+		if incident.CodeSnip == "" {
+			incident.LineHash = r.lineHash(m)
+		}
 
-		if len(rule.CustomVariables) > 0 {
-			var originalCodeSnip string
-			re := regexp.MustCompile(`^(\s*[0-9]+  )?(.*)`)
-			scanner := bufio.NewScanner(strings.NewReader(incident.CodeSnip))
-			for scanner.Scan() {
-				if incident.LineNumber != nil && strings.HasPrefix(strings.TrimSpace(scanner.Text()), fmt.Sprintf("%v", *incident.LineNumber)) {
-					originalCodeSnip = strings.TrimSpace(re.ReplaceAllString(scanner.Text(), "$2"))
-					r.logger.V(5).Info("found originalCodeSnip", "lineNuber", incident.LineNumber, "original", originalCodeSnip)
-					break
-				}
-			}
-
-			for _, cv := range rule.CustomVariables {
-				match := cv.Pattern.FindStringSubmatch(originalCodeSnip)
-				if cv.NameOfCaptureGroup != "" && cv.Pattern.SubexpIndex(cv.NameOfCaptureGroup) >= 0 &&
-					cv.Pattern.SubexpIndex(cv.NameOfCaptureGroup) < len(match) {
-
-					m.Variables[cv.Name] = strings.TrimSpace(match[cv.Pattern.SubexpIndex(cv.NameOfCaptureGroup)])
-					continue
+		r.setCustomVariables(rule, &m, incident.CodeSnip)
+		incident.Variables = m.Variables
 
-				} else {
-					switch len(match) {
-					case 0:
-						m.Variables[cv.Name] = cv.DefaultValue
-						continue
-					case 1:
-						m.Variables[cv.Name] = strings.TrimSpace(match[0])
-						continue
-					case 2:
-						m.Variables[cv.Name] = strings.TrimSpace(match[1])
-					}
-				}
-			}
+		variables := make(map[string]interface{})
+		for key, value := range m.Variables {
+			variables[key] = value
+		}
+		if m.LineNumber != nil {
+			variables["lineNumber"] = *m.LineNumber
 		}
-
 		if rule.Perform.Message.Text != nil {
-			variables := make(map[string]interface{})
-			for key, value := range m.Variables {
-				variables[key] = value
-			}
-			if m.LineNumber != nil {
-				variables["lineNumber"] = *m.LineNumber
-			}
 			templateString, err := r.createPerformString(*rule.Perform.Message.Text, variables)
 			if err != nil {
 				r.logger.Error(err, "unable to create template string")
 			}
 			incident.Message = templateString
 		}
+		if rule.Perform.Fix != nil {
+			edits, err := r.fixEdits(rule.Perform.Fix, m, variables)
+			if err != nil {
+				r.logger.V(5).Error(err, "unable to create edits of fix", "ruleID", rule.RuleID, "file", m.FileURI)
+			}
+			incident.Edits = edits
+		}
 
 		incidentLineNumber := -1
 		if incident.LineNumber != nil {
@@ -625,8 +876,12 @@ func (r *ruleEngine) createViolation(ctx context.Context, conditionResponse Cond
 
 		// Adding it to list  and set if no duplicates found
 		if _, isDuplicate := incidentsSet[incidentString]; !isDuplicate {
-			incidents = append(incidents, incident)
 			incidentsSet[incidentString] = struct{}{}
+			if r.baseline.known(rule.RuleID, incident) {
+				r.logger.V(8).Info("filtering out incident found in baseline", "ruleID", rule.RuleID, "uri", incident.URI)
+				continue
+			}
+			incidents = append(incidents, incident)
 		}
 
 	}
@@ -650,35 +905,20 @@ func (r *ruleEngine) getCodeLocation(_ context.Context, m IncidentContext, rule
 		return "", nil
 	}
 
+	isFile := strings.HasPrefix(string(m.FileURI), uri.FileScheme)
 	// We need to move this up, because the code only lives in the
-	// provider's
-	if rule.Snipper != nil {
+	// provider's, unless the snippet should be rendered differently from
+	// the lines around the incident that providers return.
+	if rule.Snipper != nil && (!isFile || r.snippetOptions.plain()) {
 		return rule.Snipper.GetCodeSnip(m.FileURI, *m.CodeLocation)
 	}
 
-	if strings.HasPrefix(string(m.FileURI), uri.FileScheme) {
-		//Find the file, open it in a buffer.
-		readFile, err := os.Open(m.FileURI.Filename())
+	if isFile {
+		codeSnip, err := r.renderSnippet(m.FileURI.Filename(), *m.CodeLocation)
 		if err != nil {
 			r.logger.V(5).Error(err, "Unable to read file")
 			return "", err
 		}
-		defer readFile.Close()
-
-		scanner := bufio.NewScanner(readFile)
-		lineNumber := 0
-		codeSnip := ""
-		paddingSize := len(strconv.Itoa(m.CodeLocation.EndPosition.Line + r.contextLines))
-		for scanner.Scan() {
-			if (lineNumber - r.contextLines) == m.CodeLocation.EndPosition.Line {
-				codeSnip = codeSnip + fmt.Sprintf("%*d  %v", paddingSize, lineNumber+1, scanner.Text())
-				break
-			}
-			if (lineNumber + r.contextLines) >= m.CodeLocation.StartPosition.Line {
-				codeSnip = codeSnip + fmt.Sprintf("%*d  %v\n", paddingSize, lineNumber+1, scanner.Text())
-			}
-			lineNumber += 1
-		}
 		return codeSnip, nil
 	}
 
@@ -2,13 +2,17 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/bombsimon/logrusr/v3"
 	"github.com/go-logr/logr"
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
 	"github.com/sirupsen/logrus"
 )
 
@@ -295,7 +299,7 @@ func TestEvaluateOrConditions(t *testing.T) {
 						Text: &testString,
 					},
 				},
-				When: OrCondition{tc.Conditions},
+				When: OrCondition{Conditions: tc.Conditions},
 			}
 			ret, err := processRule(context.TODO(), rule, ConditionContext{
 				Template: make(map[string]ChainTemplate),
@@ -448,7 +452,7 @@ func TestChainConditions(t *testing.T) {
 						Text: &testString,
 					},
 				},
-				When: OrCondition{tc.Conditions},
+				When: OrCondition{Conditions: tc.Conditions},
 			}
 			ret, err := processRule(context.TODO(), rule, ConditionContext{
 				Template: make(map[string]ChainTemplate),
@@ -744,3 +748,251 @@ func Test_parseTagsFromPerformString(t *testing.T) {
 		})
 	}
 }
+
+func TestRuleTimeout(t *testing.T) {
+	message := "found"
+	effort := 1
+	ruleSets := []RuleSet{
+		{
+			Name: "timeouts",
+			Rules: []Rule{
+				{
+					RuleMeta: RuleMeta{RuleID: "slow-001", Effort: &effort},
+					Perform:  Perform{Message: Message{Text: &message}},
+					When:     createTestConditional(true, nil, true),
+					Timeout:  50 * time.Millisecond,
+				},
+				{
+					RuleMeta: RuleMeta{RuleID: "fast-001", Effort: &effort},
+					Perform:  Perform{Message: Message{Text: &message}},
+					When:     testDependentConditional{file: "/app/Main.java"},
+					Timeout:  time.Minute,
+				},
+			},
+		},
+	}
+
+	eng := CreateRuleEngine(context.Background(), 2, logr.Discard())
+	defer eng.Stop()
+	start := time.Now()
+	results := eng.RunRules(context.Background(), ruleSets)
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("expected the run to stop waiting for the slow rule, took %s", elapsed)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected a single ruleset, got %d", len(results))
+	}
+	rs := results[0]
+	if !strings.HasPrefix(rs.Errors["slow-001"], RuleTimeoutErrorKind+":") {
+		t.Errorf("expected a timeout error for the slow rule, got %v", rs.Errors)
+	}
+	if _, ok := rs.Violations["fast-001"]; !ok {
+		t.Errorf("expected the fast rule to match, errors %v", rs.Errors)
+	}
+}
+
+func TestRuleTimeoutAbandonedEvaluations(t *testing.T) {
+	abandoned := &atomic.Int64{}
+	release := make(chan struct{})
+	rule := Rule{
+		RuleMeta: RuleMeta{RuleID: "stuck-001"},
+		When:     testBlockingConditional{started: make(chan struct{}), release: release},
+		Timeout:  50 * time.Millisecond,
+	}
+	_, err := processRule(withAbandonedEvaluations(context.Background(), abandoned), rule, ConditionContext{}, logr.Discard())
+	var timeoutErr *RuleTimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if count := abandoned.Load(); count != 1 {
+		t.Errorf("expected the evaluation that keeps running to be counted, got %d", count)
+	}
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for abandoned.Load() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the evaluation to not be counted once it returned, got %d", abandoned.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type testBlockingConditional struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (t testBlockingConditional) Evaluate(ctx context.Context, log logr.Logger, condCtx ConditionContext) (ConditionResponse, error) {
+	close(t.started)
+	<-t.release
+	return ConditionResponse{
+		Matched:   true,
+		Incidents: []IncidentContext{{FileURI: "file:///app/Main.java"}},
+	}, nil
+}
+
+func TestRunRulesCanceled(t *testing.T) {
+	message := "found"
+	effort := 1
+	blocking := testBlockingConditional{started: make(chan struct{}), release: make(chan struct{})}
+	ruleSets := []RuleSet{
+		{
+			Name: "canceled",
+			Rules: []Rule{
+				{
+					RuleMeta: RuleMeta{RuleID: "started-001", Effort: &effort},
+					Perform:  Perform{Message: Message{Text: &message}},
+					When:     blocking,
+				},
+				{
+					RuleMeta:  RuleMeta{RuleID: "waiting-001", Effort: &effort},
+					Perform:   Perform{Message: Message{Text: &message}},
+					When:      createTestConditional(true, nil, false),
+					DependsOn: []string{"started-001"},
+				},
+			},
+		},
+	}
+
+	eng := CreateRuleEngine(context.Background(), 1, logr.Discard())
+	defer eng.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan []konveyor.RuleSet)
+	go func() {
+		done <- eng.RunRules(ctx, ruleSets)
+	}()
+	<-blocking.started
+	cancel()
+	close(blocking.release)
+
+	var results []konveyor.RuleSet
+	select {
+	case results = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the run to return once the started rule completed")
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected a single ruleset, got %d", len(results))
+	}
+	rs := results[0]
+	if !rs.Incomplete {
+		t.Errorf("expected the ruleset to be incomplete")
+	}
+	if _, ok := rs.Violations["started-001"]; !ok {
+		t.Errorf("expected the rule that started before the run was canceled to be reported, errors %v", rs.Errors)
+	}
+	if !strings.HasPrefix(rs.Errors["waiting-001"], RuleCanceledErrorKind+":") {
+		t.Errorf("expected the rule that did not start to be canceled, got %v", rs.Errors)
+	}
+}
+
+func TestRunRulesCanceledEngineContext(t *testing.T) {
+	message := "found"
+	effort := 1
+	blocking := testBlockingConditional{started: make(chan struct{}), release: make(chan struct{})}
+	rules := []Rule{
+		{
+			RuleMeta: RuleMeta{RuleID: "started-001", Effort: &effort},
+			Perform:  Perform{Message: Message{Text: &message}},
+			When:     blocking,
+		},
+	}
+	// more rules than the queue of the engine holds
+	for i := 0; i < 30; i++ {
+		rules = append(rules, Rule{
+			RuleMeta: RuleMeta{RuleID: fmt.Sprintf("queued-%03d", i), Effort: &effort},
+			Perform:  Perform{Message: Message{Text: &message}},
+			When:     createTestConditional(true, nil, false),
+		})
+	}
+	ruleSets := []RuleSet{{Name: "canceled", Rules: rules}}
+
+	// the engine and the run share the context that is canceled, as they do
+	// when the CLI is interrupted
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eng := CreateRuleEngine(ctx, 1, logr.Discard())
+	defer eng.Stop()
+	done := make(chan []konveyor.RuleSet)
+	go func() {
+		done <- eng.RunRules(ctx, ruleSets)
+	}()
+	<-blocking.started
+	cancel()
+	close(blocking.release)
+
+	var results []konveyor.RuleSet
+	select {
+	case results = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunRules hung after cancel")
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected a single ruleset, got %d", len(results))
+	}
+	rs := results[0]
+	if !rs.Incomplete {
+		t.Errorf("expected the ruleset to be incomplete")
+	}
+	if _, ok := rs.Violations["started-001"]; !ok {
+		t.Errorf("expected the rule that started before the run was canceled to be reported, errors %v", rs.Errors)
+	}
+	for _, rule := range rules[1:] {
+		_, matched := rs.Violations[rule.RuleID]
+		if !matched && !strings.HasPrefix(rs.Errors[rule.RuleID], RuleCanceledErrorKind+":") {
+			t.Errorf("expected rule %s to be reported or canceled, errors %v", rule.RuleID, rs.Errors)
+		}
+	}
+}
+
+type testPanickingConditional struct{}
+
+func (t testPanickingConditional) Evaluate(ctx context.Context, log logr.Logger, condCtx ConditionContext) (ConditionResponse, error) {
+	panic("invalid condition")
+}
+
+func TestRulePanic(t *testing.T) {
+	message := "found"
+	effort := 1
+	ruleSets := []RuleSet{
+		{
+			Name: "panics",
+			Rules: []Rule{
+				{
+					RuleMeta: RuleMeta{RuleID: "panic-001", Effort: &effort},
+					Perform:  Perform{Message: Message{Text: &message}},
+					When:     testPanickingConditional{},
+				},
+				{
+					RuleMeta: RuleMeta{RuleID: "panic-002", Effort: &effort},
+					Perform:  Perform{Message: Message{Text: &message}},
+					When:     testPanickingConditional{},
+					Timeout:  time.Minute,
+				},
+				{
+					RuleMeta: RuleMeta{RuleID: "fine-001", Effort: &effort},
+					Perform:  Perform{Message: Message{Text: &message}},
+					When:     testDependentConditional{file: "/app/Main.java"},
+				},
+			},
+		},
+	}
+
+	eng := CreateRuleEngine(context.Background(), 2, logr.Discard())
+	defer eng.Stop()
+	results := eng.RunRules(context.Background(), ruleSets)
+	if len(results) != 1 {
+		t.Fatalf("expected a single ruleset, got %d", len(results))
+	}
+	rs := results[0]
+	for _, ruleID := range []string{"panic-001", "panic-002"} {
+		if !strings.Contains(rs.Errors[ruleID], "panicked: invalid condition") {
+			t.Errorf("expected an error for rule %s, got %v", ruleID, rs.Errors)
+		}
+	}
+	if _, ok := rs.Violations["fine-001"]; !ok {
+		t.Errorf("expected the other rule to match, errors %v", rs.Errors)
+	}
+}
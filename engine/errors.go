@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+)
+
+// RuleTimeoutErrorKind starts the error recorded for rules that timed out, so
+// they can be told apart from rules that failed in the output.
+const RuleTimeoutErrorKind = "timeout"
+
+// RuleTimeoutError is returned for a rule whose conditions did not complete
+// within the timeout of the rule.
+type RuleTimeoutError struct {
+	RuleID  string
+	Timeout time.Duration
+}
+
+func (e *RuleTimeoutError) Error() string {
+	return fmt.Sprintf("%s: rule %s did not complete within %s", RuleTimeoutErrorKind, e.RuleID, e.Timeout)
+}
+
+// RuleCanceledErrorKind starts the error recorded for rules that were not
+// evaluated because the run was canceled.
+const RuleCanceledErrorKind = "canceled"
+
+// RuleCanceledError is recorded for a rule that was not evaluated because the
+// context of the run was canceled before it could start.
+type RuleCanceledError struct {
+	RuleID string
+}
+
+func (e *RuleCanceledError) Error() string {
+	return fmt.Sprintf("%s: rule %s was not evaluated", RuleCanceledErrorKind, e.RuleID)
+}
+
+func isRuleCanceled(err error) bool {
+	var canceled *RuleCanceledError
+	return errors.As(err, &canceled)
+}
+
+// recordRuleError records the error of a rule in its ruleset, a ruleset with
+// rules that were canceled is incomplete.
+func recordRuleError(rs *konveyor.RuleSet, ruleID string, err error) {
+	rs.Errors[ruleID] = err.Error()
+	if isRuleCanceled(err) {
+		rs.Incomplete = true
+	}
+}
@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/cbroglie/mustache"
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+	"go.lsp.dev/uri"
+)
+
+// Fix describes how the code of every incident of a rule is edited, either by
+// replacing the matches of a pattern in the lines of the incident or by
+// replacing the code location of the incident with a text.
+type Fix struct {
+	// Replace is matched against every line of the incident, or every line of
+	// the file when the incident has no line.
+	Replace *regexp.Regexp `yaml:"replace,omitempty"`
+	// With replaces the matches of Replace, the groups of the match can be used
+	// as $1 or ${name} and the variables of the incident as {{ name }}.
+	With string `yaml:"with,omitempty"`
+	// Text replaces the code location of the incident, the variables of the
+	// incident can be used as {{ name }}.
+	Text *string `yaml:"text,omitempty"`
+}
+
+func (f *Fix) Validate() error {
+	if (f.Replace == nil) == (f.Text == nil) {
+		return fmt.Errorf("fix must have either replace or text")
+	}
+	return nil
+}
+
+// fixEdits returns the edits of the fix for the incident, the edits are given
+// for the file of the incident rather than its URI in the output, which is
+// relative when the location of the provider is.
+func (r *ruleEngine) fixEdits(fix *Fix, m IncidentContext, variables map[string]interface{}) ([]konveyor.TextEdit, error) {
+	if !strings.HasPrefix(string(m.FileURI), uri.FileScheme) {
+		return nil, fmt.Errorf("only incidents in local files can be fixed")
+	}
+	fileURI := uri.File(m.FileURI.Filename())
+	if fix.Text != nil {
+		if m.CodeLocation == nil {
+			return nil, fmt.Errorf("incident has no code location to replace")
+		}
+		text, err := mustache.RenderRaw(*fix.Text, true, variables)
+		if err != nil {
+			return nil, err
+		}
+		return []konveyor.TextEdit{{
+			URI: fileURI,
+			Range: konveyor.Range{
+				Start: konveyor.Position{Line: m.CodeLocation.StartPosition.Line, Character: m.CodeLocation.StartPosition.Character},
+				End:   konveyor.Position{Line: m.CodeLocation.EndPosition.Line, Character: m.CodeLocation.EndPosition.Character},
+			},
+			NewText: text,
+		}}, nil
+	}
+
+	lines, err := r.files.lines(m.FileURI.Filename())
+	if err != nil {
+		return nil, err
+	}
+	with, err := mustache.RenderRaw(fix.With, true, variables)
+	if err != nil {
+		return nil, err
+	}
+	first, last := 0, len(lines)-1
+	switch {
+	case m.CodeLocation != nil:
+		first, last = m.CodeLocation.StartPosition.Line, m.CodeLocation.EndPosition.Line
+	case m.LineNumber != nil:
+		first, last = *m.LineNumber-1, *m.LineNumber-1
+	}
+	edits := []konveyor.TextEdit{}
+	for i := first; i <= last && i < len(lines); i++ {
+		if i < 0 {
+			continue
+		}
+		line := lines[i]
+		for _, match := range fix.Replace.FindAllStringSubmatchIndex(line, -1) {
+			text := string(fix.Replace.ExpandString(nil, with, line, match))
+			if text == line[match[0]:match[1]] {
+				continue
+			}
+			edits = append(edits, konveyor.TextEdit{
+				URI: fileURI,
+				Range: konveyor.Range{
+					Start: konveyor.Position{Line: i, Character: utf16Length(line[:match[0]])},
+					End:   konveyor.Position{Line: i, Character: utf16Length(line[:match[1]])},
+				},
+				NewText: text,
+			})
+		}
+	}
+	return edits, nil
+}
+
+// utf16Length returns the length of the text in UTF-16 code units, the unit of
+// the characters of LSP positions.
+func utf16Length(text string) int {
+	return len(utf16.Encode([]rune(text)))
+}
@@ -0,0 +1,219 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+	"go.lsp.dev/uri"
+)
+
+func TestFixEdits(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "Main.java")
+	content := `package app;
+
+import javax.ejb.Stateless;
+import javax.ejb.EJB;
+
+@Stateless
+public class Main {
+    @EJB
+    private Service service;
+}`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fileURI := uri.File(file)
+	intPtr := func(i int) *int {
+		return &i
+	}
+	text := "@Inject"
+
+	tests := []struct {
+		name     string
+		fix      Fix
+		incident IncidentContext
+		want     []konveyor.TextEdit
+	}{
+		{
+			name: "replace on the incident line",
+			fix:  Fix{Replace: regexp.MustCompile(`javax\.(ejb)`), With: "jakarta.$1"},
+			incident: IncidentContext{
+				FileURI:    fileURI,
+				LineNumber: intPtr(3),
+			},
+			want: []konveyor.TextEdit{{
+				URI:     fileURI,
+				Range:   konveyor.Range{Start: konveyor.Position{Line: 2, Character: 7}, End: konveyor.Position{Line: 2, Character: 16}},
+				NewText: "jakarta.ejb",
+			}},
+		},
+		{
+			name: "replace with a variable of the incident",
+			fix:  Fix{Replace: regexp.MustCompile(`javax\.ejb`), With: "{{ package }}"},
+			incident: IncidentContext{
+				FileURI:    fileURI,
+				LineNumber: intPtr(4),
+				Variables:  map[string]interface{}{"package": "jakarta.ejb"},
+			},
+			want: []konveyor.TextEdit{{
+				URI:     fileURI,
+				Range:   konveyor.Range{Start: konveyor.Position{Line: 3, Character: 7}, End: konveyor.Position{Line: 3, Character: 16}},
+				NewText: "jakarta.ejb",
+			}},
+		},
+		{
+			name: "text replacing the code location",
+			fix:  Fix{Text: &text},
+			incident: IncidentContext{
+				FileURI:    fileURI,
+				LineNumber: intPtr(8),
+				CodeLocation: &Location{
+					StartPosition: Position{Line: 7, Character: 4},
+					EndPosition:   Position{Line: 7, Character: 8},
+				},
+			},
+			want: []konveyor.TextEdit{{
+				URI:     fileURI,
+				Range:   konveyor.Range{Start: konveyor.Position{Line: 7, Character: 4}, End: konveyor.Position{Line: 7, Character: 8}},
+				NewText: "@Inject",
+			}},
+		},
+	}
+	edits := []konveyor.TextEdit{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ruleEngine{logger: logr.Discard()}
+			variables := map[string]interface{}{}
+			for k, v := range tt.incident.Variables {
+				variables[k] = v
+			}
+			got, err := r.fixEdits(&tt.fix, tt.incident, variables)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fixEdits() = %v, want %v", got, tt.want)
+			}
+			edits = append(edits, got...)
+		})
+	}
+
+	// The same edit found twice is only applied once.
+	diff, skipped, err := konveyor.UnifiedDiff(append(edits, edits[0]), os.ReadFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 0 {
+		t.Errorf("expected no skipped edits, got %v", skipped)
+	}
+	want := "--- " + file + "\n+++ " + file + "\n" +
+		`@@ -1,10 +1,10 @@
+ package app;
+ 
+-import javax.ejb.Stateless;
+-import javax.ejb.EJB;
++import jakarta.ejb.Stateless;
++import jakarta.ejb.EJB;
+ 
+ @Stateless
+ public class Main {
+-    @EJB
++    @Inject
+     private Service service;
+ }
+\ No newline at end of file
+`
+	if diff != want {
+		t.Errorf("UnifiedDiff() = %q, want %q", diff, want)
+	}
+}
+
+func TestFixEditsRelativeLocation(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app", "Main.java")
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("import javax.ejb.Stateless;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the location of the provider is relative to the working directory
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	lineNumber := 1
+	r := &ruleEngine{logger: logr.Discard(), locationPrefixes: []string{"app"}, files: newFileCache(fileCacheSize, fileCacheBytes)}
+	rule := Rule{
+		RuleMeta: RuleMeta{RuleID: "ejb-00001"},
+		Perform: Perform{
+			Fix: &Fix{Replace: regexp.MustCompile(`javax\.ejb`), With: "jakarta.ejb"},
+		},
+	}
+	violation, err := r.createViolation(context.Background(), ConditionResponse{
+		Matched:   true,
+		Incidents: []IncidentContext{{FileURI: uri.File(file), LineNumber: &lineNumber}},
+	}, rule, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violation.Incidents) != 1 {
+		t.Fatalf("expected a single incident, got %v", violation.Incidents)
+	}
+	incident := violation.Incidents[0]
+	if incident.URI != "file:///app/Main.java" {
+		t.Errorf("expected the incident to have the relative URI, got %s", incident.URI)
+	}
+	diff, _, err := konveyor.UnifiedDiff(incident.Edits, os.ReadFile)
+	if err != nil {
+		t.Fatalf("expected the edits to be for the file of the incident, got %v", err)
+	}
+	want := "--- " + file + "\n+++ " + file + "\n@@ -1,1 +1,1 @@\n-import javax.ejb.Stateless;\n+import jakarta.ejb.Stateless;\n"
+	if diff != want {
+		t.Errorf("UnifiedDiff() = %q, want %q", diff, want)
+	}
+}
+
+func TestFixEditsUTF16(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "Main.java")
+	if err := os.WriteFile(file, []byte("String s = \"😀é\"; javax.ejb.EJB e;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lineNumber := 1
+	r := &ruleEngine{logger: logr.Discard(), files: newFileCache(fileCacheSize, fileCacheBytes)}
+	got, err := r.fixEdits(&Fix{Replace: regexp.MustCompile(`javax\.ejb`), With: "jakarta.ejb"}, IncidentContext{
+		FileURI:    uri.File(file),
+		LineNumber: &lineNumber,
+	}, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the emoji is two UTF-16 code units
+	want := []konveyor.TextEdit{{
+		URI:     uri.File(file),
+		Range:   konveyor.Range{Start: konveyor.Position{Line: 0, Character: 18}, End: konveyor.Position{Line: 0, Character: 27}},
+		NewText: "jakarta.ejb",
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("fixEdits() = %v, want %v", got, want)
+	}
+	diff, _, err := konveyor.UnifiedDiff(got, os.ReadFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDiff := "--- " + file + "\n+++ " + file + "\n@@ -1,1 +1,1 @@\n-String s = \"😀é\"; javax.ejb.EJB e;\n+String s = \"😀é\"; jakarta.ejb.EJB e;\n"
+	if diff != wantDiff {
+		t.Errorf("UnifiedDiff() = %q, want %q", diff, wantDiff)
+	}
+}
@@ -1,14 +1,20 @@
 package engine
 
 import (
+	"bufio"
 	"fmt"
 	"net/url"
+	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/go-logr/logr"
+	"github.com/konveyor/analyzer-lsp/engine/labels"
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
 	"go.lsp.dev/uri"
 )
 
@@ -101,8 +107,13 @@ func (i *includedPathScope) FilterResponse(response IncidentContext) bool {
 	if len(i.paths) == 0 {
 		return false
 	}
+	// Incidents without a file, such as the one of a count condition, are not
+	// in any of the paths to leave out.
+	if response.FileURI == "" {
+		return false
+	}
 	for _, path := range i.paths {
-		if string(response.FileURI) != "" && response.FileURI.Filename() == path {
+		if response.FileURI.Filename() == path {
 			return false
 		}
 	}
@@ -180,3 +191,229 @@ func ExcludedPathsScope(paths []string, log logr.Logger) Scope {
 		log:   log.WithName("excludedPathScope"),
 	}
 }
+
+// LineRange is an inclusive range of one based line numbers in a file.
+type LineRange struct {
+	Start int
+	End   int
+}
+
+func (l LineRange) overlaps(start, end int) bool {
+	return l.Start <= end && start <= l.End
+}
+
+type includedLinesScope struct {
+	*includedPathScope
+	lines map[string][]LineRange
+}
+
+var _ Scope = &includedLinesScope{}
+
+func (i *includedLinesScope) Name() string {
+	return "IncludedLinesScope"
+}
+
+func (i *includedLinesScope) FilterResponse(response IncidentContext) bool {
+	// Unlike included paths, no lines means that nothing is included.
+	if len(i.lines) == 0 || i.includedPathScope.FilterResponse(response) {
+		return true
+	}
+	// Incidents for a whole file can not be narrowed down any further.
+	if response.LineNumber == nil {
+		return false
+	}
+	start := *response.LineNumber
+	end := start
+	if response.CodeLocation != nil && response.CodeLocation.EndPosition.Line > response.CodeLocation.StartPosition.Line {
+		end = start + response.CodeLocation.EndPosition.Line - response.CodeLocation.StartPosition.Line
+	}
+	for _, r := range i.lines[response.FileURI.Filename()] {
+		if r.overlaps(start, end) {
+			return false
+		}
+	}
+	i.log.V(7).Info("filtering out incident outside of included lines", "file", response.FileURI.Filename(), "line", start)
+	return true
+}
+
+// IncludedLinesScope limits the analysis to the given files, like IncludedPathsScope,
+// and will only keep incidents that overlap one of the line ranges given for their file.
+func IncludedLinesScope(lines map[string][]LineRange, log logr.Logger) Scope {
+	paths := []string{}
+	for path := range lines {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return &includedLinesScope{
+		includedPathScope: &includedPathScope{
+			paths: paths,
+			log:   log,
+		},
+		lines: lines,
+	}
+}
+
+// DefaultGeneratedCodeMarkers match the lines found in the header of files
+// written by code generators, the "// Code generated ... DO NOT EDIT." comment
+// of Go files and the @Generated annotation of Java classes.
+var DefaultGeneratedCodeMarkers = []*regexp.Regexp{
+	regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`),
+	regexp.MustCompile(`^\s*@([\w.]+\.)?Generated\b`),
+}
+
+// generatedCodeHeaderLines is the number of lines at the start of a file that
+// are searched for generated code markers.
+const generatedCodeHeaderLines = 100
+
+type generatedCodeScope struct {
+	markers   []*regexp.Regexp
+	log       logr.Logger
+	mutex     sync.Mutex
+	generated map[string]bool
+}
+
+var _ Scope = &generatedCodeScope{}
+
+func (g *generatedCodeScope) Name() string {
+	return "GeneratedCodeScope"
+}
+
+// Providers can not tell generated files apart, they are filtered from the responses.
+func (g *generatedCodeScope) AddToContext(*ConditionContext) error {
+	return nil
+}
+
+func (g *generatedCodeScope) FilterResponse(response IncidentContext) bool {
+	if !strings.HasPrefix(string(response.FileURI), uri.FileScheme) {
+		return false
+	}
+	path := response.FileURI.Filename()
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	generated, ok := g.generated[path]
+	if !ok {
+		generated = g.isGenerated(path)
+		g.generated[path] = generated
+	}
+	if generated {
+		g.log.V(7).Info("filtering out incident in generated code", "file", path)
+	}
+	return generated
+}
+
+func (g *generatedCodeScope) isGenerated(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		g.log.V(5).Error(err, "unable to read file for generated code markers", "file", path)
+		return false
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for i := 0; i < generatedCodeHeaderLines && scanner.Scan(); i++ {
+		for _, marker := range g.markers {
+			if marker.MatchString(scanner.Text()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GeneratedCodeScope filters out incidents in files with a line matching one of
+// the markers in their first lines, DefaultGeneratedCodeMarkers are used when
+// none are given.
+func GeneratedCodeScope(markers []*regexp.Regexp, log logr.Logger) Scope {
+	if len(markers) == 0 {
+		markers = DefaultGeneratedCodeMarkers
+	}
+	return &generatedCodeScope{
+		markers:   markers,
+		log:       log,
+		generated: map[string]bool{},
+	}
+}
+
+type maxFileSizeScope struct {
+	size int64
+	log  logr.Logger
+}
+
+var _ Scope = &maxFileSizeScope{}
+
+func (m *maxFileSizeScope) Name() string {
+	return "MaxFileSizeScope"
+}
+
+func (m *maxFileSizeScope) AddToContext(*ConditionContext) error {
+	return nil
+}
+
+func (m *maxFileSizeScope) FilterResponse(response IncidentContext) bool {
+	if !strings.HasPrefix(string(response.FileURI), uri.FileScheme) {
+		return false
+	}
+	info, err := os.Stat(response.FileURI.Filename())
+	if err != nil || info.Size() <= m.size {
+		return false
+	}
+	m.log.V(7).Info("filtering out incident in large file", "file", response.FileURI.Filename(), "size", info.Size())
+	return true
+}
+
+// MaxFileSizeScope filters out incidents in files larger than the given number
+// of bytes, such as minified or bundled files.
+func MaxFileSizeScope(size int64, log logr.Logger) Scope {
+	return &maxFileSizeScope{
+		size: size,
+		log:  log,
+	}
+}
+
+type dependencyLabelScope struct {
+	selector *labels.LabelSelector[*konveyor.Dep]
+	deps     []*konveyor.Dep
+	log      logr.Logger
+}
+
+var _ Scope = &dependencyLabelScope{}
+
+func (d *dependencyLabelScope) Name() string {
+	return "DependencyLabelScope"
+}
+
+func (d *dependencyLabelScope) AddToContext(*ConditionContext) error {
+	return nil
+}
+
+func (d *dependencyLabelScope) FilterResponse(response IncidentContext) bool {
+	inDependency := false
+	for _, dep := range d.deps {
+		if dep.FileURIPrefix == "" || !strings.HasPrefix(string(response.FileURI), dep.FileURIPrefix) {
+			continue
+		}
+		inDependency = true
+		matched, err := d.selector.Matches(dep)
+		if err != nil {
+			d.log.V(5).Error(err, "unable to match dependency labels", "dependency", dep.Name)
+			continue
+		}
+		if matched {
+			return false
+		}
+	}
+	if inDependency {
+		d.log.V(7).Info("filtering out incident in a dependency not selected by labels", "file", response.FileURI)
+	}
+	return inDependency
+}
+
+// DependencyLabelScope filters out incidents in the files of the given
+// dependencies, found by their prefix, unless one of them matches the selector.
+// Incidents in other files are kept.
+func DependencyLabelScope(selector *labels.LabelSelector[*konveyor.Dep], deps []*konveyor.Dep, log logr.Logger) Scope {
+	return &dependencyLabelScope{
+		selector: selector,
+		deps:     deps,
+		log:      log,
+	}
+}
@@ -0,0 +1,185 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/konveyor/analyzer-lsp/engine/labels"
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+	"go.lsp.dev/uri"
+)
+
+func TestIncludedLinesScopeFilterResponse(t *testing.T) {
+	intPtr := func(i int) *int {
+		return &i
+	}
+	scope := IncludedLinesScope(map[string][]LineRange{
+		"/app/src/Main.java": {
+			{Start: 10, End: 12},
+			{Start: 40, End: 40},
+		},
+		"/app/pom.xml": {},
+	}, logr.Discard())
+
+	tests := []struct {
+		name     string
+		incident IncidentContext
+		filter   bool
+	}{
+		{
+			name:     "incident on a changed line",
+			incident: IncidentContext{FileURI: uri.File("/app/src/Main.java"), LineNumber: intPtr(11)},
+		},
+		{
+			name:     "incident on an unchanged line of a changed file",
+			incident: IncidentContext{FileURI: uri.File("/app/src/Main.java"), LineNumber: intPtr(20)},
+			filter:   true,
+		},
+		{
+			name: "incident spanning into a changed line",
+			incident: IncidentContext{
+				FileURI:    uri.File("/app/src/Main.java"),
+				LineNumber: intPtr(38),
+				CodeLocation: &Location{
+					StartPosition: Position{Line: 37},
+					EndPosition:   Position{Line: 40},
+				},
+			},
+		},
+		{
+			name:     "incident for a whole changed file",
+			incident: IncidentContext{FileURI: uri.File("/app/pom.xml")},
+		},
+		{
+			name:     "incident in a file with only removed lines",
+			incident: IncidentContext{FileURI: uri.File("/app/pom.xml"), LineNumber: intPtr(3)},
+			filter:   true,
+		},
+		{
+			name:     "incident in an unchanged file",
+			incident: IncidentContext{FileURI: uri.File("/app/src/Other.java"), LineNumber: intPtr(11)},
+			filter:   true,
+		}, {
+			name:     "incident without a file",
+			incident: IncidentContext{Variables: map[string]interface{}{"count": 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scope.FilterResponse(tt.incident); got != tt.filter {
+				t.Errorf("FilterResponse() = %v, want %v", got, tt.filter)
+			}
+		})
+	}
+
+	if !IncludedLinesScope(map[string][]LineRange{}, logr.Discard()).FilterResponse(IncidentContext{FileURI: uri.File("/app/pom.xml")}) {
+		t.Errorf("expected everything to be filtered when no lines are included")
+	}
+}
+
+func TestFileScopesFilterResponse(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"Main.java":      "package app;\n\npublic class Main {\n}\n",
+		"Generated.java": "package app;\n\n@javax.annotation.Generated(\"xjc\")\npublic class Generated {\n}\n",
+		"types.go":       "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage types\n",
+		"Customer.java":  "package app;\n\n@Entity\npublic class Customer {\n    @Id\n    @GeneratedValue\n    private Long id;\n}\n",
+		"Banner.java":    "package app;\n\n// DO NOT EDIT the banner, it is @generated by the build\npublic class Banner {\n}\n",
+		"bundle.js":      strings.Repeat("var a = 1;", 200),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	incident := func(name string) IncidentContext {
+		return IncidentContext{FileURI: uri.File(filepath.Join(dir, name))}
+	}
+	scope := NewScope(GeneratedCodeScope(nil, logr.Discard()), MaxFileSizeScope(1024, logr.Discard()))
+
+	tests := []struct {
+		name     string
+		incident IncidentContext
+		filter   bool
+	}{
+		{
+			name:     "source file",
+			incident: incident("Main.java"),
+		},
+		{
+			name:     "generated Java class",
+			incident: incident("Generated.java"),
+			filter:   true,
+		},
+		{
+			name:     "generated Go file",
+			incident: incident("types.go"),
+			filter:   true,
+		},
+		{
+			name:     "entity with a generated value",
+			incident: incident("Customer.java"),
+		},
+		{
+			name:     "comment that is not a generated code marker",
+			incident: incident("Banner.java"),
+		},
+		{
+			name:     "large file",
+			incident: incident("bundle.js"),
+			filter:   true,
+		},
+		{
+			name:     "missing file",
+			incident: incident("Missing.java"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scope.FilterResponse(tt.incident); got != tt.filter {
+				t.Errorf("FilterResponse() = %v, want %v", got, tt.filter)
+			}
+		})
+	}
+}
+
+func TestDependencyLabelScopeFilterResponse(t *testing.T) {
+	selector, err := labels.NewLabelSelector[*konveyor.Dep]("konveyor.io/dep-source=open-source", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope := DependencyLabelScope(selector, []*konveyor.Dep{
+		{Name: "commons", Labels: []string{"konveyor.io/dep-source=open-source"}, FileURIPrefix: "file:///m2/commons"},
+		{Name: "internal", Labels: []string{"konveyor.io/dep-source=internal"}, FileURIPrefix: "file:///m2/internal"},
+	}, logr.Discard())
+
+	tests := []struct {
+		name     string
+		incident IncidentContext
+		filter   bool
+	}{
+		{
+			name:     "incident in a selected dependency",
+			incident: IncidentContext{FileURI: "file:///m2/commons/Lang.java"},
+		},
+		{
+			name:     "incident in a dependency that is not selected",
+			incident: IncidentContext{FileURI: "file:///m2/internal/Util.java"},
+			filter:   true,
+		},
+		{
+			name:     "incident in the application",
+			incident: IncidentContext{FileURI: "file:///app/src/Main.java"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scope.FilterResponse(tt.incident); got != tt.filter {
+				t.Errorf("FilterResponse() = %v, want %v", got, tt.filter)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+)
+
+// ViolationSink receives violations and insights as soon as the rule that
+// produced them completes, instead of once all the rules are done. The engine
+// calls it from a single goroutine, one violation at a time.
+//
+// Violations are given as the engine creates them, with scopes, selectors,
+// limits and the baseline applied. Post-processing of the returned rulesets
+// across rules, such as konveyor.GroupIncidents, is not applied to them.
+type ViolationSink interface {
+	// Write is given the ruleset and rule of the violation, insight is true
+	// when the violation is an insight.
+	Write(ruleSet string, ruleID string, violation konveyor.Violation, insight bool) error
+}
+
+// WithViolationSink hands every violation and insight to the sink as it is
+// produced, they are still part of the returned rulesets.
+func WithViolationSink(sink ViolationSink) Option {
+	return func(engine *ruleEngine) {
+		engine.sink = sink
+	}
+}
+
+// WithSinkOnly keeps the violations and insights handed to the sink out of
+// the returned rulesets, so that they are not held in memory until all the
+// rules are done. It has no effect without a sink.
+func WithSinkOnly() Option {
+	return func(engine *ruleEngine) {
+		engine.sinkOnly = true
+	}
+}
+
+// addViolation adds the violation of a rule to its ruleset and hands it to the
+// sink.
+func (r *ruleEngine) addViolation(rs *konveyor.RuleSet, ruleID string, violation konveyor.Violation, insight bool) {
+	r.writeToSink(rs.Name, ruleID, violation, insight)
+	if r.sink != nil && r.sinkOnly {
+		return
+	}
+	if insight {
+		rs.Insights[ruleID] = violation
+	} else {
+		rs.Violations[ruleID] = violation
+	}
+}
+
+// writeToSink hands the violation to the sink, a failing sink does not fail the rule.
+func (r *ruleEngine) writeToSink(ruleSet string, ruleID string, violation konveyor.Violation, insight bool) {
+	if r.sink == nil {
+		return
+	}
+	if err := r.sink.Write(ruleSet, ruleID, violation, insight); err != nil {
+		r.logger.Error(err, "unable to write violation to sink", "ruleset", ruleSet, "ruleID", ruleID)
+	}
+}
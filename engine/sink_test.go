@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+)
+
+type testSink struct {
+	written []string
+}
+
+func (t *testSink) Write(ruleSet string, ruleID string, violation konveyor.Violation, insight bool) error {
+	kind := "violation"
+	if insight {
+		kind = "insight"
+	}
+	t.written = append(t.written, ruleSet+"/"+ruleID+":"+kind)
+	return nil
+}
+
+func TestViolationSink(t *testing.T) {
+	message := "found"
+	effort := 1
+	ruleSets := []RuleSet{
+		{
+			Name: "sink",
+			Rules: []Rule{
+				{
+					RuleMeta: RuleMeta{RuleID: "violation-001", Effort: &effort},
+					Perform:  Perform{Message: Message{Text: &message}},
+					When:     testDependentConditional{file: "/app/Main.java"},
+				},
+				{
+					RuleMeta: RuleMeta{RuleID: "insight-001"},
+					Perform:  Perform{Message: Message{Text: &message}},
+					When:     testDependentConditional{file: "/app/Main.java"},
+				},
+				{
+					RuleMeta: RuleMeta{RuleID: "tag-001"},
+					Perform:  Perform{Tag: []string{"Java"}},
+					When:     testDependentConditional{file: "/app/pom.xml"},
+				},
+				{
+					RuleMeta: RuleMeta{RuleID: "unmatched-001", Effort: &effort},
+					Perform:  Perform{Message: Message{Text: &message}},
+					When:     testDependentConditional{},
+				},
+			},
+		},
+	}
+
+	sink := &testSink{}
+	eng := CreateRuleEngine(context.Background(), 2, logr.Discard(), WithViolationSink(sink))
+	defer eng.Stop()
+	results := eng.RunRules(context.Background(), ruleSets)
+	if len(results) != 1 {
+		t.Fatalf("expected a single ruleset, got %d", len(results))
+	}
+
+	sort.Strings(sink.written)
+	expected := []string{"sink/insight-001:insight", "sink/tag-001:insight", "sink/violation-001:violation"}
+	if !reflect.DeepEqual(sink.written, expected) {
+		t.Errorf("expected %v to be written to the sink, got %v", expected, sink.written)
+	}
+	if _, ok := results[0].Violations["violation-001"]; !ok {
+		t.Errorf("expected violations to still be part of the ruleset")
+	}
+}
+
+func TestViolationSinkOnly(t *testing.T) {
+	message := "found"
+	effort := 1
+	ruleSets := []RuleSet{
+		{
+			Name: "sink",
+			Rules: []Rule{
+				{
+					RuleMeta: RuleMeta{RuleID: "violation-001", Effort: &effort},
+					Perform:  Perform{Message: Message{Text: &message}},
+					When:     testDependentConditional{file: "/app/Main.java"},
+				},
+				{
+					RuleMeta: RuleMeta{RuleID: "insight-001"},
+					Perform:  Perform{Message: Message{Text: &message}},
+					When:     testDependentConditional{file: "/app/Main.java"},
+				},
+				{
+					RuleMeta: RuleMeta{RuleID: "unmatched-001", Effort: &effort},
+					Perform:  Perform{Message: Message{Text: &message}},
+					When:     testDependentConditional{},
+				},
+			},
+		},
+	}
+
+	sink := &testSink{}
+	eng := CreateRuleEngine(context.Background(), 2, logr.Discard(), WithViolationSink(sink), WithSinkOnly())
+	defer eng.Stop()
+	results := eng.RunRules(context.Background(), ruleSets)
+	if len(results) != 1 {
+		t.Fatalf("expected a single ruleset, got %d", len(results))
+	}
+
+	sort.Strings(sink.written)
+	expected := []string{"sink/insight-001:insight", "sink/violation-001:violation"}
+	if !reflect.DeepEqual(sink.written, expected) {
+		t.Errorf("expected %v to be written to the sink, got %v", expected, sink.written)
+	}
+	if len(results[0].Violations) != 0 || len(results[0].Insights) != 0 {
+		t.Errorf("expected violations to only be written to the sink, got %v and %v", results[0].Violations, results[0].Insights)
+	}
+	if !reflect.DeepEqual(results[0].Unmatched, []string{"unmatched-001"}) {
+		t.Errorf("expected unmatched rules to still be part of the ruleset, got %v", results[0].Unmatched)
+	}
+}
@@ -0,0 +1,256 @@
+package engine
+
+import (
+	"regexp"
+	"strings"
+)
+
+// controlKeywords start the headers of blocks that are not functions.
+var controlKeywords = map[string]bool{
+	"if": true, "else": true, "for": true, "foreach": true, "while": true, "do": true,
+	"switch": true, "case": true, "default": true, "select": true, "try": true, "catch": true,
+	"finally": true, "synchronized": true, "return": true, "go": true, "defer": true,
+}
+
+// typeKeywords declare types, their blocks are not functions.
+var typeKeywords = map[string]bool{
+	"class": true, "interface": true, "enum": true, "struct": true, "namespace": true, "record": true,
+}
+
+var annotationRegex = regexp.MustCompile(`@[\w.]+(\([^)]*\))?`)
+
+// braceBlockExpander finds the function an incident is in for languages that
+// use curly braces for blocks, such as Java, Go or JavaScript.
+type braceBlockExpander struct{}
+
+type braceBlock struct {
+	header int
+	open   int
+	close  int
+}
+
+func (e braceBlockExpander) Expand(lines []string, start, end int) (int, int, bool) {
+	return e.expandParsed(e.parse(lines), start, end)
+}
+
+// parse returns the blocks of the functions in the lines.
+func (braceBlockExpander) parse(lines []string) interface{} {
+	functions := []braceBlock{}
+	for _, block := range braceBlocks(lines) {
+		if isFunctionHeader(strings.Join(lines[block.header:block.open+1], " ")) {
+			functions = append(functions, block)
+		}
+	}
+	return functions
+}
+
+func (braceBlockExpander) expandParsed(parsed interface{}, start, end int) (int, int, bool) {
+	found := false
+	best := braceBlock{}
+	for _, block := range parsed.([]braceBlock) {
+		if block.header > start || block.close < end {
+			continue
+		}
+		if !found || block.close-block.header < best.close-best.header {
+			best = block
+			found = true
+		}
+	}
+	return best.header, best.close, found
+}
+
+// braceBlocks returns the blocks of the lines, ignoring braces in strings and
+// comments. The header of a block starts at the first line of the statement
+// that opens it, such as the signature of a function.
+func braceBlocks(lines []string) []braceBlock {
+	blocks := []braceBlock{}
+	open := []int{}
+	inComment := false
+	for i, line := range lines {
+		var quote rune
+		escaped := false
+		runes := []rune(line)
+		for j := 0; j < len(runes); j++ {
+			c := runes[j]
+			next := rune(0)
+			if j+1 < len(runes) {
+				next = runes[j+1]
+			}
+			switch {
+			case inComment:
+				if c == '*' && next == '/' {
+					inComment = false
+					j++
+				}
+			case quote != 0:
+				if escaped {
+					escaped = false
+				} else if c == '\\' {
+					escaped = true
+				} else if c == quote {
+					quote = 0
+				}
+			case c == '/' && next == '/':
+				j = len(runes)
+			case c == '/' && next == '*':
+				inComment = true
+				j++
+			case c == '"' || c == '\'' || c == '`':
+				quote = c
+			case c == '{':
+				open = append(open, i)
+			case c == '}':
+				if len(open) == 0 {
+					continue
+				}
+				openLine := open[len(open)-1]
+				open = open[:len(open)-1]
+				blocks = append(blocks, braceBlock{
+					header: blockHeader(lines, openLine),
+					open:   openLine,
+					close:  i,
+				})
+			}
+		}
+	}
+	return blocks
+}
+
+// blockHeader returns the first line of the statement that ends on the line
+// opening a block, such as annotations and signatures over several lines.
+func blockHeader(lines []string, open int) int {
+	header := open
+	if strings.HasPrefix(strings.TrimSpace(lines[open]), "{") && open > 0 {
+		// the brace is on a line of its own
+		header--
+	}
+	for header > 0 {
+		previous := strings.TrimSpace(lines[header-1])
+		if previous == "" || strings.HasSuffix(previous, ";") || strings.HasSuffix(previous, "{") ||
+			strings.HasSuffix(previous, "}") || strings.HasSuffix(previous, "*/") || strings.HasPrefix(previous, "//") {
+			break
+		}
+		header--
+	}
+	return header
+}
+
+// isFunctionHeader tells if the statement opening a block declares a function,
+// these have parameters and do not start with a control keyword.
+func isFunctionHeader(header string) bool {
+	statement := annotationRegex.ReplaceAllString(header, "")
+	if i := strings.LastIndex(statement, "{"); i >= 0 {
+		statement = statement[:i]
+	}
+	parameters := strings.Index(statement, "(")
+	if parameters < 0 || !strings.Contains(statement[parameters:], ")") {
+		return false
+	}
+	words := strings.Fields(strings.NewReplacer("(", " ", "<", " ").Replace(statement[:parameters]))
+	if len(words) == 0 || controlKeywords[words[0]] || strings.HasPrefix(words[0], "}") {
+		return false
+	}
+	for _, word := range words {
+		if typeKeywords[word] {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	xmlTagRegex     = regexp.MustCompile(`<(/?)([A-Za-z_][\w:.-]*)[^<>]*?(/?)>`)
+	xmlSkippedRegex = regexp.MustCompile(`(?s)<!--.*?-->|<!\[CDATA\[.*?\]\]>`)
+)
+
+// xmlElementExpander finds the element an incident is in for XML files, an
+// element on a single line is shown with the element around it.
+type xmlElementExpander struct{}
+
+// xmlElement is the first and last line of an element.
+type xmlElement struct {
+	from int
+	to   int
+}
+
+func (e xmlElementExpander) Expand(lines []string, start, end int) (int, int, bool) {
+	return e.expandParsed(e.parse(lines), start, end)
+}
+
+// parse returns the elements of the lines that are closed.
+func (xmlElementExpander) parse(lines []string) interface{} {
+	content := strings.Join(lines, "\n")
+	// offsets of the start of each line
+	lineStarts := []int{0}
+	for i, c := range content {
+		if c == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	lineOf := func(offset int) int {
+		low, high := 0, len(lineStarts)-1
+		for low < high {
+			mid := (low + high + 1) / 2
+			if lineStarts[mid] <= offset {
+				low = mid
+			} else {
+				high = mid - 1
+			}
+		}
+		return low
+	}
+	skipped := xmlSkippedRegex.FindAllStringIndex(content, -1)
+	isSkipped := func(offset int) bool {
+		for _, span := range skipped {
+			if offset >= span[0] && offset < span[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	type openTag struct {
+		name string
+		line int
+	}
+	stack := []openTag{}
+	elements := []xmlElement{}
+	for _, match := range xmlTagRegex.FindAllStringSubmatchIndex(content, -1) {
+		if isSkipped(match[0]) {
+			continue
+		}
+		closing := match[3] > match[2]
+		selfClosing := match[7] > match[6]
+		name := content[match[4]:match[5]]
+		line := lineOf(match[0])
+		switch {
+		case selfClosing:
+			continue
+		case !closing:
+			stack = append(stack, openTag{name: name, line: line})
+			continue
+		}
+		// pop up to the element that is closed, ignoring elements that are never closed
+		for i := len(stack) - 1; i >= 0; i-- {
+			if stack[i].name != name {
+				continue
+			}
+			elements = append(elements, xmlElement{from: stack[i].line, to: line})
+			stack = stack[:i]
+			break
+		}
+	}
+	return elements
+}
+
+func (xmlElementExpander) expandParsed(parsed interface{}, start, end int) (int, int, bool) {
+	found := false
+	best := xmlElement{}
+	for _, element := range parsed.([]xmlElement) {
+		if element.from <= start && element.to >= end && element.to-element.from > end-start && (!found || element.to-element.from < best.to-best.from) {
+			best = element
+			found = true
+		}
+	}
+	return best.from, best.to, found
+}
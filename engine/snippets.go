@@ -0,0 +1,389 @@
+package engine
+
+import (
+	"bufio"
+	"container/list"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SnippetContext decides which lines of a file are shown around an incident.
+type SnippetContext string
+
+const (
+	// LinesSnippetContext shows the context lines above and below the incident.
+	LinesSnippetContext SnippetContext = "lines"
+	// EnclosingSnippetContext shows the function or the XML element the
+	// incident is in, when it can be found and is not too large.
+	EnclosingSnippetContext SnippetContext = "enclosing"
+
+	// maxEnclosingSnippetLines is the largest enclosing block shown in a snippet,
+	// the context lines are shown around incidents in larger blocks.
+	maxEnclosingSnippetLines = 60
+	// fileCacheSize is the number of files kept in memory for code snippets.
+	fileCacheSize = 64
+	// fileCacheBytes is the most bytes of lines kept in memory for code
+	// snippets, larger files are read again for each incident.
+	fileCacheBytes = 32 * 1024 * 1024
+	// maxSnippetLineSize is the longest line, in bytes, of a file read for code
+	// snippets, such as a line of minified code. Files with longer lines are
+	// not read.
+	maxSnippetLineSize = 16 * 1024 * 1024
+)
+
+// SnippetOptions decide how code snippets of incidents are rendered from the
+// files they are found in.
+type SnippetOptions struct {
+	// Context decides which lines around the incident are part of the snippet,
+	// defaults to LinesSnippetContext.
+	Context SnippetContext
+	// TrimPadding drops the blank lines at the start and the end of the snippet.
+	TrimPadding bool
+	// MarkRange adds a line under each line of the incident with carets under
+	// the characters of its location.
+	MarkRange bool
+	// Expanders find the enclosing block of an incident for the files with the
+	// given extension, such as ".java", in addition to the builtin ones.
+	Expanders map[string]SnippetExpander
+}
+
+// SnippetExpander finds the block that encloses an incident, such as the
+// function or the element it is in.
+type SnippetExpander interface {
+	// Expand is given the lines of the file and the zero-based first and last
+	// line of the incident. It returns the first and last line of the block
+	// enclosing them, or false when there is none.
+	Expand(lines []string, start, end int) (int, int, bool)
+}
+
+var defaultSnippetExpanders = map[string]SnippetExpander{}
+
+func init() {
+	for _, ext := range []string{".java", ".go", ".js", ".jsx", ".ts", ".tsx", ".cs", ".c", ".h", ".cpp", ".hpp", ".kt", ".scala", ".groovy", ".swift", ".rs", ".php"} {
+		defaultSnippetExpanders[ext] = braceBlockExpander{}
+	}
+	for _, ext := range []string{".xml", ".xsd", ".wsdl", ".xsl", ".xslt", ".xhtml", ".jspx", ".tld", ".fxml", ".csproj", ".config", ".props", ".targets"} {
+		defaultSnippetExpanders[ext] = xmlElementExpander{}
+	}
+}
+
+// WithSnippetOptions changes how the engine renders code snippets of incidents
+// in local files.
+func WithSnippetOptions(options SnippetOptions) Option {
+	return func(engine *ruleEngine) {
+		engine.snippetOptions = options
+	}
+}
+
+// plain is true when snippets only show the context lines around incidents.
+func (o SnippetOptions) plain() bool {
+	return (o.Context == "" || o.Context == LinesSnippetContext) && !o.TrimPadding && !o.MarkRange
+}
+
+func (o SnippetOptions) expander(path string) SnippetExpander {
+	ext := strings.ToLower(filepath.Ext(path))
+	if expander, ok := o.Expanders[ext]; ok {
+		return expander
+	}
+	return defaultSnippetExpanders[ext]
+}
+
+// renderSnippet renders the lines of the file around the location, each line
+// prefixed with its line number.
+func (r *ruleEngine) renderSnippet(path string, location Location) (string, error) {
+	start, end := location.StartPosition.Line, location.EndPosition.Line
+	if end < start {
+		end = start
+	}
+	first, last := start-r.contextLines, end+r.contextLines
+	if first < 0 {
+		first = 0
+	}
+	// Snippets with the lines around the incident keep the width they always had.
+	width := len(strconv.Itoa(end + r.contextLines))
+	var lines []string
+	if expander := r.snippetOptions.expander(path); r.snippetOptions.Context == EnclosingSnippetContext && expander != nil {
+		fileLines, err := r.files.lines(path)
+		if err != nil {
+			return "", err
+		}
+		if end < len(fileLines) {
+			if from, to, ok := r.files.expand(path, expander, fileLines, start, end); ok && from <= start && to >= end && to-from < maxEnclosingSnippetLines {
+				first, last = from, to
+				width = len(strconv.Itoa(to + 1))
+			}
+		}
+		if first < len(fileLines) {
+			lines = fileLines[first:min(last+1, len(fileLines))]
+		}
+	} else {
+		// Only the lines of the snippet are read from files that are not cached.
+		var err error
+		lines, err = r.files.window(path, first, last)
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	// A snippet cut short by the end of the file ends with a new line.
+	trailingNewLine := len(lines) <= last-first
+	last = first + len(lines) - 1
+	if r.snippetOptions.TrimPadding {
+		trailingNewLine = false
+		for first < start && first < last && strings.TrimSpace(lines[0]) == "" {
+			first++
+			lines = lines[1:]
+		}
+		for last > end && last > first && strings.TrimSpace(lines[last-first]) == "" {
+			last--
+		}
+	}
+
+	snippet := strings.Builder{}
+	for i := first; i <= last; i++ {
+		if i > first {
+			snippet.WriteString("\n")
+		}
+		line := lines[i-first]
+		snippet.WriteString(fmt.Sprintf("%*d  %v", width, i+1, line))
+		if r.snippetOptions.MarkRange && i >= start && i <= end {
+			if marker := rangeMarker(line, i, location); marker != "" {
+				snippet.WriteString(fmt.Sprintf("\n%*s  %s", width, "", marker))
+			}
+		}
+	}
+	if trailingNewLine {
+		snippet.WriteString("\n")
+	}
+	return snippet.String(), nil
+}
+
+// rangeMarker returns carets under the characters of the line that are part of
+// the location, tabs are kept so that the carets line up with the characters.
+// Characters of locations are UTF-16 code units as in LSP positions, a
+// character outside of the basic multilingual plane gets a single caret.
+func rangeMarker(line string, lineNumber int, location Location) string {
+	from, to := 0, utf16Length(line)
+	if lineNumber == location.StartPosition.Line {
+		from = location.StartPosition.Character
+	}
+	if lineNumber == location.EndPosition.Line && location.EndPosition.Character < to {
+		to = location.EndPosition.Character
+	}
+	if from < 0 || to <= from {
+		return ""
+	}
+	marker := strings.Builder{}
+	i := 0
+	for _, c := range line {
+		if i >= to {
+			break
+		}
+		switch {
+		case i >= from:
+			marker.WriteRune('^')
+		case c == '\t':
+			marker.WriteRune('\t')
+		default:
+			marker.WriteRune(' ')
+		}
+		// Characters outside of the basic multilingual plane are a surrogate pair.
+		if c > 0xFFFF {
+			i += 2
+		} else {
+			i++
+		}
+	}
+	return marker.String()
+}
+
+// fileCache keeps the lines of the files that were read last, incidents of a
+// rule and of different rules are often found in the same files. The files
+// are parsed once for each expander finding enclosing blocks in them.
+type fileCache struct {
+	mutex    sync.Mutex
+	size     int
+	maxBytes int
+	bytes    int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type fileCacheEntry struct {
+	path   string
+	lines  []string
+	bytes  int
+	parsed map[SnippetExpander]interface{}
+	// content is the lines joined by new lines, once they were joined.
+	content *string
+}
+
+// newFileCache returns a cache of at most size files and maxBytes bytes of
+// lines, larger files are not cached.
+func newFileCache(size int, maxBytes int) *fileCache {
+	return &fileCache{
+		size:     size,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+// lines returns the lines of the file, reading it when it is not cached. A nil
+// cache always reads the file.
+func (c *fileCache) lines(path string) ([]string, error) {
+	if c == nil {
+		return readLines(path)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[path]; ok {
+		c.order.MoveToFront(element)
+		return element.Value.(*fileCacheEntry).lines, nil
+	}
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	entry := &fileCacheEntry{path: path, lines: lines}
+	for _, line := range lines {
+		entry.bytes += len(line)
+	}
+	if entry.bytes > c.maxBytes {
+		return lines, nil
+	}
+	c.entries[path] = c.order.PushFront(entry)
+	c.bytes += entry.bytes
+	c.evict()
+	return lines, nil
+}
+
+// content returns the lines of the file joined by new lines, the lines of a
+// cached file are joined once.
+func (c *fileCache) content(path string) (string, error) {
+	lines, err := c.lines(path)
+	if err != nil {
+		return "", err
+	}
+	if c == nil {
+		return strings.Join(lines, "\n"), nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.entries[path]
+	if !ok {
+		return strings.Join(lines, "\n"), nil
+	}
+	entry := element.Value.(*fileCacheEntry)
+	if entry.content == nil {
+		content := strings.Join(entry.lines, "\n")
+		entry.content = &content
+		entry.bytes += len(content)
+		c.bytes += len(content)
+		c.evict()
+	}
+	return *entry.content, nil
+}
+
+// evict drops the least recently used files until the cache is within its
+// number of files and bytes.
+func (c *fileCache) evict() {
+	for c.order.Len() > c.size || c.bytes > c.maxBytes {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*fileCacheEntry).path)
+		c.bytes -= oldest.Value.(*fileCacheEntry).bytes
+	}
+}
+
+// window returns the zero-based lines first to last of the file, fewer when
+// the file ends before last. Only these lines are read when the file is not
+// cached, the file is not added to the cache.
+func (c *fileCache) window(path string, first, last int) ([]string, error) {
+	if c != nil {
+		c.mutex.Lock()
+		element, ok := c.entries[path]
+		if ok {
+			c.order.MoveToFront(element)
+		}
+		c.mutex.Unlock()
+		if ok {
+			lines := element.Value.(*fileCacheEntry).lines
+			if first >= len(lines) {
+				return nil, nil
+			}
+			return lines[first:min(last+1, len(lines))], nil
+		}
+	}
+	return scanLines(path, first, last)
+}
+
+// snippetParser is implemented by the expanders that parse the whole file to
+// find a block, the parsed file is kept with its lines in the cache so that
+// it is parsed once for all the incidents in it.
+type snippetParser interface {
+	parse(lines []string) interface{}
+	expandParsed(parsed interface{}, start, end int) (int, int, bool)
+}
+
+// expand finds the block enclosing the lines start to end of the file with the
+// expander, parsing the file only when it was not parsed by the expander yet.
+func (c *fileCache) expand(path string, expander SnippetExpander, lines []string, start, end int) (int, int, bool) {
+	parser, ok := expander.(snippetParser)
+	if !ok || c == nil {
+		return expander.Expand(lines, start, end)
+	}
+	c.mutex.Lock()
+	element, cached := c.entries[path]
+	var parsed interface{}
+	if cached {
+		parsed, ok = element.Value.(*fileCacheEntry).parsed[expander]
+	}
+	c.mutex.Unlock()
+	if cached && ok {
+		return parser.expandParsed(parsed, start, end)
+	}
+	parsed = parser.parse(lines)
+	if cached {
+		c.mutex.Lock()
+		entry := element.Value.(*fileCacheEntry)
+		if entry.parsed == nil {
+			entry.parsed = map[SnippetExpander]interface{}{}
+		}
+		entry.parsed[expander] = parsed
+		c.mutex.Unlock()
+	}
+	return parser.expandParsed(parsed, start, end)
+}
+
+func readLines(path string) ([]string, error) {
+	return scanLines(path, 0, -1)
+}
+
+// scanLines reads the zero-based lines first to last of the file, up to its
+// end when last is negative.
+func scanLines(path string, first, last int) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	lines := []string{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxSnippetLineSize)
+	for i := 0; (last < 0 || i <= last) && scanner.Scan(); i++ {
+		if i >= first {
+			lines = append(lines, scanner.Text())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	return lines, nil
+}
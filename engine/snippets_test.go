@@ -0,0 +1,340 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestRenderSnippet(t *testing.T) {
+	dir := t.TempDir()
+	javaFile := filepath.Join(dir, "Main.java")
+	err := os.WriteFile(javaFile, []byte(`package app;
+
+import javax.ejb.Stateless;
+
+@Stateless
+public class Main {
+
+    @Override
+    public String hello(String name) {
+        if (name == null) {
+            return "{";
+        }
+        return "hello " + name;
+    }
+}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unicodeFile := filepath.Join(dir, "Greeting.java")
+	err = os.WriteFile(unicodeFile, []byte("String hi = \"\U0001F44B \u00e9t\u00e9\"; hello(hi);\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xmlFile := filepath.Join(dir, "pom.xml")
+	err = os.WriteFile(xmlFile, []byte(`<project>
+  <dependencies>
+    <!-- <dependency> -->
+    <dependency>
+      <groupId>javax</groupId>
+      <artifactId>javaee-api</artifactId>
+    </dependency>
+  </dependencies>
+</project>
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(line, start, end int) Location {
+		return Location{
+			StartPosition: Position{Line: line, Character: start},
+			EndPosition:   Position{Line: line, Character: end},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		location Location
+		context  int
+		options  SnippetOptions
+		want     string
+	}{
+		{
+			name:     "context lines",
+			file:     javaFile,
+			location: at(2, 0, 0),
+			context:  1,
+			want:     "2  \n3  import javax.ejb.Stateless;\n4  ",
+		},
+		{
+			name:     "context lines cut short by the end of the file",
+			file:     javaFile,
+			location: at(14, 0, 0),
+			context:  2,
+			want:     "13          return \"hello \" + name;\n14      }\n15  }\n",
+		},
+		{
+			name:     "trimmed padding",
+			file:     javaFile,
+			location: at(2, 0, 0),
+			context:  1,
+			options:  SnippetOptions{TrimPadding: true},
+			want:     "3  import javax.ejb.Stateless;",
+		},
+		{
+			name:     "marked range",
+			file:     javaFile,
+			location: at(2, 7, 26),
+			options:  SnippetOptions{MarkRange: true},
+			want:     "3  import javax.ejb.Stateless;\n          ^^^^^^^^^^^^^^^^^^^",
+		},
+		{
+			name:     "marked range after a surrogate pair",
+			file:     unicodeFile,
+			location: at(0, 22, 27),
+			options:  SnippetOptions{MarkRange: true},
+			want:     "1  String hi = \"\U0001F44B \u00e9t\u00e9\"; hello(hi);\n                        ^^^^^",
+		},
+		{
+			name:     "marked range with a surrogate pair",
+			file:     unicodeFile,
+			location: at(0, 12, 20),
+			options:  SnippetOptions{MarkRange: true},
+			want:     "1  String hi = \"\U0001F44B \u00e9t\u00e9\"; hello(hi);\n               ^^^^^^^",
+		},
+		{
+			name:     "enclosing function",
+			file:     javaFile,
+			location: at(10, 0, 0),
+			context:  1,
+			options:  SnippetOptions{Context: EnclosingSnippetContext},
+			want: " 8      @Override\n 9      public String hello(String name) {\n10          if (name == null) {\n" +
+				"11              return \"{\";\n12          }\n13          return \"hello \" + name;\n14      }",
+		},
+		{
+			name:     "no enclosing function",
+			file:     javaFile,
+			location: at(2, 0, 0),
+			context:  1,
+			options:  SnippetOptions{Context: EnclosingSnippetContext},
+			want:     "2  \n3  import javax.ejb.Stateless;\n4  ",
+		},
+		{
+			name:     "enclosing element",
+			file:     xmlFile,
+			location: at(4, 0, 0),
+			options:  SnippetOptions{Context: EnclosingSnippetContext},
+			want:     "4      <dependency>\n5        <groupId>javax</groupId>\n6        <artifactId>javaee-api</artifactId>\n7      </dependency>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ruleEngine{logger: logr.Discard(), contextLines: tt.context, snippetOptions: tt.options}
+			got, err := r.renderSnippet(tt.file, tt.location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("renderSnippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileCache(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	cache := newFileCache(2, fileCacheBytes)
+	for _, file := range files[:2] {
+		if _, err := cache.lines(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Changes to cached files are not seen, the least recently used file is
+	// read again once it is evicted by the third one.
+	for _, file := range files[:2] {
+		if err := os.WriteFile(file, []byte("changed\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lines, _ := cache.lines(files[0]); lines[0] != "a.txt" {
+		t.Errorf("expected the cached lines, got %v", lines)
+	}
+	if _, err := cache.lines(files[2]); err != nil {
+		t.Fatal(err)
+	}
+	if lines, _ := cache.lines(files[0]); lines[0] != "a.txt" {
+		t.Errorf("expected the lines of the recently used file to still be cached, got %v", lines)
+	}
+	if lines, _ := cache.lines(files[1]); lines[0] != "changed" {
+		t.Errorf("expected the evicted file to be read again, got %v", lines)
+	}
+}
+
+func TestReadLinesLongLines(t *testing.T) {
+	dir := t.TempDir()
+	long := strings.Repeat("a", 100*1024)
+	file := filepath.Join(dir, "app.min.js")
+	if err := os.WriteFile(file, []byte(long+"\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lines, err := readLines(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || lines[0] != long || lines[1] != "b" {
+		t.Errorf("expected the long line and the line after it, got %d lines", len(lines))
+	}
+
+	tooLong := filepath.Join(dir, "huge.min.js")
+	if err := os.WriteFile(tooLong, []byte(strings.Repeat("a", maxSnippetLineSize+1)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cache := newFileCache(2, fileCacheBytes)
+	if _, err := cache.lines(tooLong); err == nil {
+		t.Errorf("expected an error for a line longer than the limit")
+	}
+	if len(cache.entries) != 0 {
+		t.Errorf("expected the file with the line longer than the limit to not be cached")
+	}
+}
+
+func TestFileCacheBytes(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{}
+	for _, content := range []string{"aaaa\n", "bbbb\n", "cccccccccccc\n"} {
+		file := filepath.Join(dir, content[:1]+".txt")
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	cache := newFileCache(10, 10)
+	for _, file := range files {
+		if _, err := cache.lines(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := cache.entries[files[2]]; ok {
+		t.Errorf("expected the file larger than the cache to not be cached")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "d.txt"), []byte("dddd\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.lines(filepath.Join(dir, "d.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.entries[files[0]]; ok || cache.bytes != 8 || len(cache.entries) != 2 {
+		t.Errorf("expected the least recently used file to be evicted to stay within the bytes of the cache, got %d bytes of %d files", cache.bytes, len(cache.entries))
+	}
+}
+
+func TestFileCacheWindow(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.min.js")
+	content := "a\nb\nc\n" + strings.Repeat("d", maxSnippetLineSize+1) + "\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cache := newFileCache(2, fileCacheBytes)
+	lines, err := cache.window(file, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lines, []string{"b", "c"}) {
+		t.Errorf("expected the lines of the window, got %v", lines)
+	}
+	if len(cache.entries) != 0 {
+		t.Errorf("expected the file to not be cached for a window of its lines")
+	}
+
+	short := filepath.Join(dir, "short.txt")
+	if err := os.WriteFile(short, []byte("a\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if lines, err := cache.window(short, 1, 5); err != nil || !reflect.DeepEqual(lines, []string{"b"}) {
+		t.Errorf("expected the window to end with the file, got %v, %v", lines, err)
+	}
+	if _, err := cache.lines(short); err != nil {
+		t.Fatal(err)
+	}
+	if lines, err := cache.window(short, 0, 0); err != nil || !reflect.DeepEqual(lines, []string{"a"}) {
+		t.Errorf("expected the window of the cached file, got %v, %v", lines, err)
+	}
+}
+
+type testParsingExpander struct {
+	parsed *int
+}
+
+func (e testParsingExpander) Expand(lines []string, start, end int) (int, int, bool) {
+	return e.expandParsed(e.parse(lines), start, end)
+}
+
+func (e testParsingExpander) parse(lines []string) interface{} {
+	*e.parsed++
+	return len(lines)
+}
+
+func (e testParsingExpander) expandParsed(parsed interface{}, start, end int) (int, int, bool) {
+	return 0, parsed.(int) - 1, true
+}
+
+func TestFileCacheParsesOnce(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "Main.java")
+	if err := os.WriteFile(file, []byte("a\nb\nc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parsed := 0
+	expander := testParsingExpander{parsed: &parsed}
+	cache := newFileCache(2, fileCacheBytes)
+	lines, err := cache.lines(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for line := range lines {
+		if from, to, ok := cache.expand(file, expander, lines, line, line); !ok || from != 0 || to != 2 {
+			t.Errorf("unexpected block %d-%d for line %d", from, to, line)
+		}
+	}
+	if parsed != 1 {
+		t.Errorf("expected the file to be parsed once, parsed %d times", parsed)
+	}
+}
+
+func TestFileCacheContent(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "Main.java")
+	if err := os.WriteFile(file, []byte("a\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cache := newFileCache(2, fileCacheBytes)
+	content, err := cache.content(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "a\nb" {
+		t.Errorf("unexpected content %q", content)
+	}
+	entry := cache.entries[file].Value.(*fileCacheEntry)
+	if entry.content == nil || cache.bytes != 2+len(content) {
+		t.Errorf("expected the content to be kept with the lines of the file, got %d bytes", cache.bytes)
+	}
+	if again, _ := cache.content(file); again != content {
+		t.Errorf("expected the cached content, got %q", again)
+	}
+}
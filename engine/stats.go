@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+)
+
+type ruleStatsKey struct{}
+
+// ruleStats collects the stats of a single rule evaluation, conditions of the
+// rule may call providers concurrently.
+type ruleStats struct {
+	mutex     sync.Mutex
+	calls     int
+	providers map[string]bool
+	duration  time.Duration
+	cached    bool
+}
+
+func withRuleStats(ctx context.Context) (context.Context, *ruleStats) {
+	stats := &ruleStats{providers: map[string]bool{}}
+	return context.WithValue(ctx, ruleStatsKey{}, stats), stats
+}
+
+// RecordProviderCall counts a call to the named provider in the stats of the
+// rule that is evaluated with the context, if any.
+func RecordProviderCall(ctx context.Context, provider string) {
+	stats, ok := ctx.Value(ruleStatsKey{}).(*ruleStats)
+	if !ok || stats == nil {
+		return
+	}
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	stats.calls++
+	if provider != "" {
+		stats.providers[provider] = true
+	}
+}
+
+// markRuleCached notes in the stats of the rule that is evaluated with the
+// context that its result came from the rule cache.
+func markRuleCached(ctx context.Context) {
+	stats, ok := ctx.Value(ruleStatsKey{}).(*ruleStats)
+	if !ok || stats == nil {
+		return
+	}
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	stats.cached = true
+}
+
+// output returns the stats of the rule, given the number of incidents its
+// conditions found and how many of them were reported.
+func (s *ruleStats) output(found, reported int) konveyor.RuleStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	providers := []string{}
+	for name := range s.providers {
+		providers = append(providers, name)
+	}
+	sort.Strings(providers)
+	return konveyor.RuleStats{
+		Duration:          konveyor.Duration(s.duration),
+		ProviderCalls:     s.calls,
+		Providers:         providers,
+		IncidentsFound:    found,
+		IncidentsReported: reported,
+		Cached:            s.cached,
+	}
+}
+
+// recordStats adds the stats of an evaluation of the rule to its ruleset.
+func (r *ruleEngine) recordStats(rs *konveyor.RuleSet, response response, found, reported int) {
+	if !r.stats || rs == nil || response.stats == nil {
+		return
+	}
+	if rs.Stats == nil {
+		rs.Stats = map[string]konveyor.RuleStats{}
+	}
+	stats := response.stats.output(found, reported)
+	if existing, ok := rs.Stats[response.Rule.RuleID]; ok {
+		stats = existing.Add(stats)
+	}
+	rs.Stats[response.Rule.RuleID] = stats
+}
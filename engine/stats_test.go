@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"go.lsp.dev/uri"
+)
+
+type testProviderCallConditional struct {
+	providers []string
+	files     []string
+}
+
+func (t testProviderCallConditional) Evaluate(ctx context.Context, log logr.Logger, condCtx ConditionContext) (ConditionResponse, error) {
+	for _, provider := range t.providers {
+		RecordProviderCall(ctx, provider)
+	}
+	response := ConditionResponse{}
+	for _, file := range t.files {
+		response.Matched = true
+		response.Incidents = append(response.Incidents, IncidentContext{FileURI: uri.File(file)})
+	}
+	return response, nil
+}
+
+func TestRuleStats(t *testing.T) {
+	message := "found"
+	effort := 1
+	ruleSets := []RuleSet{
+		{
+			Name: "stats",
+			Rules: []Rule{
+				{
+					RuleMeta: RuleMeta{RuleID: "matched-001", Effort: &effort},
+					Perform:  Perform{Message: Message{Text: &message}},
+					When: testProviderCallConditional{
+						providers: []string{"java", "builtin", "java"},
+						files:     []string{"/app/Main.java", "/app/Util.java", "/app/Test.java"},
+					},
+				},
+				{
+					RuleMeta: RuleMeta{RuleID: "unmatched-001", Effort: &effort},
+					Perform:  Perform{Message: Message{Text: &message}},
+					When:     testProviderCallConditional{providers: []string{"builtin"}},
+				},
+			},
+		},
+	}
+
+	eng := CreateRuleEngine(context.Background(), 2, logr.Discard(), WithIncidentLimit(2), WithRuleStats())
+	defer eng.Stop()
+	results := eng.RunRules(context.Background(), ruleSets)
+	if len(results) != 1 {
+		t.Fatalf("expected a single ruleset, got %d", len(results))
+	}
+	stats := results[0].Stats
+
+	matched, ok := stats["matched-001"]
+	if !ok {
+		t.Fatalf("expected stats for the matched rule, got %v", stats)
+	}
+	if matched.ProviderCalls != 3 || !reflect.DeepEqual(matched.Providers, []string{"builtin", "java"}) {
+		t.Errorf("unexpected provider calls %d to %v", matched.ProviderCalls, matched.Providers)
+	}
+	if matched.IncidentsFound != 3 || matched.IncidentsReported != 2 {
+		t.Errorf("expected 3 incidents found and 2 reported, got %d and %d", matched.IncidentsFound, matched.IncidentsReported)
+	}
+
+	unmatched, ok := stats["unmatched-001"]
+	if !ok {
+		t.Fatalf("expected stats for the unmatched rule, got %v", stats)
+	}
+	if unmatched.ProviderCalls != 1 || unmatched.IncidentsFound != 0 || unmatched.IncidentsReported != 0 {
+		t.Errorf("unexpected stats for the unmatched rule %#v", unmatched)
+	}
+
+	eng = CreateRuleEngine(context.Background(), 2, logr.Discard())
+	defer eng.Stop()
+	results = eng.RunRules(context.Background(), ruleSets)
+	if len(results) != 1 || results[0].Stats != nil {
+		t.Errorf("expected no stats unless they are requested, got %v", results)
+	}
+}
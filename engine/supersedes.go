@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+)
+
+// ResolveSupersedes returns the rules that every rule supersedes, directly or
+// through the rules it supersedes, for konveyor.GroupIncidents. Superseded rules
+// must be in the given rulesets and can not supersede the rules superseding them.
+func ResolveSupersedes(ruleSets []RuleSet) (map[konveyor.RuleRef][]konveyor.RuleRef, error) {
+	known := map[ruleKey]bool{}
+	for _, ruleSet := range ruleSets {
+		for _, rule := range ruleSet.Rules {
+			known[ruleKey{ruleSet: ruleSet.Name, ruleID: rule.RuleID}] = true
+		}
+	}
+	exists := func(key ruleKey) bool {
+		return known[key]
+	}
+	graph := map[ruleKey][]ruleKey{}
+	for _, ruleSet := range ruleSets {
+		for _, rule := range ruleSet.Rules {
+			key := ruleKey{ruleSet: ruleSet.Name, ruleID: rule.RuleID}
+			for _, superseded := range rule.Supersedes {
+				supersededKey, ok := resolveDependency(ruleSet.Name, superseded, exists)
+				if !ok {
+					return nil, fmt.Errorf("rule %s supersedes unknown rule %s", key, superseded)
+				}
+				graph[key] = append(graph[key], supersededKey)
+			}
+		}
+	}
+	if cycle := findDependencyCycle(graph); cycle != nil {
+		names := []string{}
+		for _, key := range cycle {
+			names = append(names, key.String())
+		}
+		return nil, fmt.Errorf("rules supersede each other in a cycle: %s", strings.Join(names, " -> "))
+	}
+
+	supersedes := map[konveyor.RuleRef][]konveyor.RuleRef{}
+	for key := range graph {
+		seen := map[ruleKey]bool{}
+		pending := append([]ruleKey{}, graph[key]...)
+		for len(pending) > 0 {
+			next := pending[0]
+			pending = pending[1:]
+			if seen[next] {
+				continue
+			}
+			seen[next] = true
+			ref := konveyor.RuleRef{RuleSet: key.ruleSet, RuleID: key.ruleID}
+			supersedes[ref] = append(supersedes[ref], konveyor.RuleRef{RuleSet: next.ruleSet, RuleID: next.ruleID})
+			pending = append(pending, graph[next]...)
+		}
+	}
+	return supersedes, nil
+}
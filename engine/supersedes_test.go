@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+	"go.lsp.dev/uri"
+)
+
+func TestResolveSupersedes(t *testing.T) {
+	rule := func(id string, supersedes ...string) Rule {
+		return Rule{
+			RuleMeta:   RuleMeta{RuleID: id},
+			Supersedes: supersedes,
+		}
+	}
+	ref := func(ruleSet, ruleID string) konveyor.RuleRef {
+		return konveyor.RuleRef{RuleSet: ruleSet, RuleID: ruleID}
+	}
+	tests := []struct {
+		name     string
+		ruleSets []RuleSet
+		want     map[konveyor.RuleRef][]konveyor.RuleRef
+		wantErr  bool
+	}{
+		{
+			name: "superseded rules in the same and other rulesets",
+			ruleSets: []RuleSet{
+				{Name: "builtin", Rules: []Rule{rule("file-001")}},
+				{Name: "java", Rules: []Rule{
+					rule("java-001", "builtin/file-001"),
+					rule("java-002", "java-001"),
+				}},
+			},
+			want: map[konveyor.RuleRef][]konveyor.RuleRef{
+				ref("java", "java-001"): {ref("builtin", "file-001")},
+				ref("java", "java-002"): {ref("java", "java-001"), ref("builtin", "file-001")},
+			},
+		},
+		{
+			name: "unknown superseded rule",
+			ruleSets: []RuleSet{
+				{Name: "java", Rules: []Rule{rule("java-001", "builtin/file-001")}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cycle",
+			ruleSets: []RuleSet{
+				{Name: "java", Rules: []Rule{
+					rule("java-001", "java-002"),
+					rule("java-002", "java-001"),
+				}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveSupersedes(tt.ruleSets)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveSupersedes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResolveSupersedes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupIncidents(t *testing.T) {
+	line := func(n int) *int {
+		return &n
+	}
+	file := uri.File("/app/src/Main.java")
+	ruleSets := func() []konveyor.RuleSet {
+		return []konveyor.RuleSet{
+			{
+				Name: "builtin",
+				Violations: map[string]konveyor.Violation{
+					"file-001": {Incidents: []konveyor.Incident{{URI: file, LineNumber: line(3)}}},
+				},
+			},
+			{
+				Name: "java",
+				Violations: map[string]konveyor.Violation{
+					"java-001": {Incidents: []konveyor.Incident{
+						{URI: file, LineNumber: line(3)},
+						{URI: file, LineNumber: line(7)},
+					}},
+				},
+				Insights: map[string]konveyor.Violation{
+					"java-002": {Incidents: []konveyor.Incident{{URI: file, LineNumber: line(7)}}},
+				},
+			},
+		}
+	}
+
+	t.Run("superseded incidents", func(t *testing.T) {
+		got := ruleSets()
+		konveyor.GroupIncidents(got, map[konveyor.RuleRef][]konveyor.RuleRef{
+			{RuleSet: "java", RuleID: "java-001"}: {{RuleSet: "builtin", RuleID: "file-001"}},
+		}, false)
+		if _, ok := got[0].Violations["file-001"]; ok {
+			t.Errorf("expected the superseded violation to be removed")
+		}
+		if !reflect.DeepEqual(got[0].Unmatched, []string{"file-001"}) {
+			t.Errorf("expected the superseded rule to be unmatched, got %v", got[0].Unmatched)
+		}
+		if n := len(got[1].Violations["java-001"].Incidents); n != 2 {
+			t.Errorf("expected the incidents of the superseding rule to be kept, got %d", n)
+		}
+		if related := got[1].Insights["java-002"].Incidents[0].RelatedRules; related != nil {
+			t.Errorf("expected no related rules without cross references, got %v", related)
+		}
+	})
+
+	t.Run("superseded rule that is already unmatched", func(t *testing.T) {
+		got := ruleSets()
+		got[0].Unmatched = []string{"file-001"}
+		got[0].Insights = map[string]konveyor.Violation{
+			"file-001": {Incidents: []konveyor.Incident{{URI: file, LineNumber: line(3)}}},
+		}
+		konveyor.GroupIncidents(got, map[konveyor.RuleRef][]konveyor.RuleRef{
+			{RuleSet: "java", RuleID: "java-001"}: {{RuleSet: "builtin", RuleID: "file-001"}},
+		}, false)
+		if len(got[0].Violations) != 0 || len(got[0].Insights) != 0 {
+			t.Errorf("expected the superseded violation and insight to be removed")
+		}
+		if !reflect.DeepEqual(got[0].Unmatched, []string{"file-001"}) {
+			t.Errorf("expected the superseded rule to be unmatched once, got %v", got[0].Unmatched)
+		}
+	})
+
+	t.Run("cross references", func(t *testing.T) {
+		got := ruleSets()
+		konveyor.GroupIncidents(got, nil, true)
+		javaIncidents := got[1].Violations["java-001"].Incidents
+		for _, tt := range []struct {
+			incident konveyor.Incident
+			want     []string
+		}{
+			{incident: got[0].Violations["file-001"].Incidents[0], want: []string{"java/java-001"}},
+			{incident: javaIncidents[0], want: []string{"builtin/file-001"}},
+			{incident: javaIncidents[1], want: []string{"java/java-002"}},
+			{incident: got[1].Insights["java-002"].Incidents[0], want: []string{"java/java-001"}},
+		} {
+			if !reflect.DeepEqual(tt.incident.RelatedRules, tt.want) {
+				t.Errorf("got related rules %v at line %d, want %v", tt.incident.RelatedRules, *tt.incident.LineNumber, tt.want)
+			}
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.lsp.dev/uri"
+)
+
+// CustomVariableSource decides which text the pattern of a custom variable is
+// matched against.
+type CustomVariableSource string
+
+const (
+	// LineVariableSource matches the line of the incident, the default.
+	LineVariableSource CustomVariableSource = "line"
+	// LinesVariableSource matches the lines around the incident, the match on
+	// the line closest to the incident is used, lines above it first.
+	LinesVariableSource CustomVariableSource = "lines"
+	// FileVariableSource matches the content of the whole file, the first match is used.
+	FileVariableSource CustomVariableSource = "file"
+	// ProviderVariableSource matches a variable returned by the provider for the
+	// incident, its value is used as is when there is no pattern.
+	ProviderVariableSource CustomVariableSource = "variable"
+)
+
+var codeSnipLineRegex = regexp.MustCompile(`^(\s*[0-9]+  )?(.*)`)
+
+// setCustomVariables sets the custom variables of the rule in the variables of
+// the incident. Lines are read from the file of the incident when it is a
+// local file, so that they do not depend on its code snippet being fetched.
+func (r *ruleEngine) setCustomVariables(rule Rule, m *IncidentContext, codeSnip string) {
+	if len(rule.CustomVariables) == 0 {
+		return
+	}
+	if m.Variables == nil {
+		m.Variables = map[string]interface{}{}
+	}
+	var lines []string
+	var linesErr error
+	fileLines := func() ([]string, error) {
+		if lines == nil && linesErr == nil {
+			if !strings.HasPrefix(string(m.FileURI), uri.FileScheme) {
+				linesErr = fmt.Errorf("incident is not in a local file")
+			} else {
+				lines, linesErr = r.files.lines(m.FileURI.Filename())
+			}
+		}
+		return lines, linesErr
+	}
+
+	for _, cv := range rule.CustomVariables {
+		var value string
+		var found bool
+		switch cv.Source {
+		case LinesVariableSource:
+			value, found = r.matchLinesVariable(cv, m, fileLines)
+		case FileVariableSource:
+			value, found = r.matchFileVariable(cv, m)
+		case ProviderVariableSource:
+			value, found = matchProviderVariable(cv, m)
+		default:
+			line, ok := r.incidentLine(m, codeSnip, fileLines)
+			if !ok {
+				r.logger.V(5).Info("unable to find the line of the incident for custom variables", "ruleID", rule.RuleID, "file", m.FileURI)
+			}
+			value, found = matchCustomVariable(cv, line)
+		}
+		if found {
+			m.Variables[cv.Name] = value
+		}
+	}
+}
+
+// matchCustomVariable returns the value of the custom variable in the text, its
+// capture group or the single group of the pattern when there is one, or the
+// default value when the pattern does not match. Patterns with several groups
+// and no capture group name have no value.
+func matchCustomVariable(cv CustomVariable, text string) (string, bool) {
+	if cv.Pattern == nil {
+		return cv.DefaultValue, true
+	}
+	match := cv.Pattern.FindStringSubmatch(text)
+	if cv.NameOfCaptureGroup != "" && cv.Pattern.SubexpIndex(cv.NameOfCaptureGroup) >= 0 &&
+		cv.Pattern.SubexpIndex(cv.NameOfCaptureGroup) < len(match) {
+		return strings.TrimSpace(match[cv.Pattern.SubexpIndex(cv.NameOfCaptureGroup)]), true
+	}
+	switch len(match) {
+	case 0:
+		return cv.DefaultValue, true
+	case 1:
+		return strings.TrimSpace(match[0]), true
+	case 2:
+		return strings.TrimSpace(match[1]), true
+	}
+	return "", false
+}
+
+// incidentLine returns the line of the incident from its file, or from its code
+// snippet when the file can not be read.
+func (r *ruleEngine) incidentLine(m *IncidentContext, codeSnip string, fileLines func() ([]string, error)) (string, bool) {
+	if m.LineNumber == nil {
+		return "", false
+	}
+	if lines, err := fileLines(); err == nil && *m.LineNumber >= 1 && *m.LineNumber <= len(lines) {
+		return strings.TrimSpace(lines[*m.LineNumber-1]), true
+	}
+	scanner := bufio.NewScanner(strings.NewReader(codeSnip))
+	for scanner.Scan() {
+		if strings.HasPrefix(strings.TrimSpace(scanner.Text()), fmt.Sprintf("%v", *m.LineNumber)) {
+			line := strings.TrimSpace(codeSnipLineRegex.ReplaceAllString(scanner.Text(), "$2"))
+			r.logger.V(5).Info("found originalCodeSnip", "lineNuber", *m.LineNumber, "original", line)
+			return line, true
+		}
+	}
+	return "", false
+}
+
+func (r *ruleEngine) matchLinesVariable(cv CustomVariable, m *IncidentContext, fileLines func() ([]string, error)) (string, bool) {
+	lines, err := fileLines()
+	if err != nil || m.LineNumber == nil || cv.Pattern == nil {
+		r.logger.V(5).Info("unable to read the lines around the incident for custom variable", "name", cv.Name, "file", m.FileURI)
+		return cv.DefaultValue, true
+	}
+	// lines by their distance to the incident, the line above first
+	line := *m.LineNumber - 1
+	order := []int{line}
+	for i := 1; i <= max(cv.LinesBefore, cv.LinesAfter); i++ {
+		if i <= cv.LinesBefore {
+			order = append(order, line-i)
+		}
+		if i <= cv.LinesAfter {
+			order = append(order, line+i)
+		}
+	}
+	for _, i := range order {
+		if i < 0 || i >= len(lines) || !cv.Pattern.MatchString(lines[i]) {
+			continue
+		}
+		return matchCustomVariable(cv, lines[i])
+	}
+	return cv.DefaultValue, true
+}
+
+func (r *ruleEngine) matchFileVariable(cv CustomVariable, m *IncidentContext) (string, bool) {
+	if !strings.HasPrefix(string(m.FileURI), uri.FileScheme) {
+		r.logger.V(5).Info("unable to read the file for custom variable, incident is not in a local file", "name", cv.Name, "file", m.FileURI)
+		return cv.DefaultValue, true
+	}
+	content, err := r.files.content(m.FileURI.Filename())
+	if err != nil {
+		r.logger.V(5).Info("unable to read the file for custom variable", "name", cv.Name, "file", m.FileURI)
+		return cv.DefaultValue, true
+	}
+	return matchCustomVariable(cv, content)
+}
+
+func matchProviderVariable(cv CustomVariable, m *IncidentContext) (string, bool) {
+	value, ok := m.Variables[cv.Variable]
+	if !ok || value == nil {
+		return cv.DefaultValue, true
+	}
+	if cv.Pattern == nil {
+		return strings.TrimSpace(fmt.Sprintf("%v", value)), true
+	}
+	return matchCustomVariable(cv, fmt.Sprintf("%v", value))
+}
@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"go.lsp.dev/uri"
+)
+
+func TestSetCustomVariables(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "Main.java")
+	err := os.WriteFile(file, []byte(`package com.example.apps;
+
+public class Main {
+    public static void main(String[] args) {
+        GenericClass<String> element = new GenericClass<String>("Hello world!");
+        element.get();
+    }
+}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := 6
+
+	tests := []struct {
+		name     string
+		variable CustomVariable
+		want     interface{}
+	}{
+		{
+			name:     "incident line without a code snippet",
+			variable: CustomVariable{Pattern: regexp.MustCompile(`([A-z]+)\.get\(\)`)},
+			want:     "element",
+		},
+		{
+			name: "lines above the incident",
+			variable: CustomVariable{
+				Pattern:     regexp.MustCompile(`class (\w+)`),
+				Source:      LinesVariableSource,
+				LinesBefore: 5,
+			},
+			want: "Main",
+		},
+		{
+			name: "closest line below the incident",
+			variable: CustomVariable{
+				Pattern:     regexp.MustCompile(`^\s*(\}|public class \w+)`),
+				Source:      LinesVariableSource,
+				LinesBefore: 3,
+				LinesAfter:  1,
+			},
+			want: "}",
+		},
+		{
+			name: "lines out of the window",
+			variable: CustomVariable{
+				Pattern:      regexp.MustCompile(`package ([\w.]+);`),
+				DefaultValue: "none",
+				Source:       LinesVariableSource,
+				LinesBefore:  2,
+				LinesAfter:   2,
+			},
+			want: "none",
+		},
+		{
+			name: "whole file",
+			variable: CustomVariable{
+				Pattern: regexp.MustCompile(`package ([\w.]+);`),
+				Source:  FileVariableSource,
+			},
+			want: "com.example.apps",
+		},
+		{
+			name: "provider variable",
+			variable: CustomVariable{
+				Pattern:  regexp.MustCompile(`\.(\w+)$`),
+				Source:   ProviderVariableSource,
+				Variable: "package",
+			},
+			want: "apps",
+		},
+		{
+			name: "missing provider variable",
+			variable: CustomVariable{
+				DefaultValue: "unknown",
+				Source:       ProviderVariableSource,
+				Variable:     "kind",
+			},
+			want: "unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.variable.Name = "value"
+			r := &ruleEngine{logger: logr.Discard()}
+			m := IncidentContext{
+				FileURI:    uri.File(file),
+				LineNumber: &line,
+				Variables:  map[string]interface{}{"package": "com.example.apps"},
+			}
+			r.setCustomVariables(Rule{CustomVariables: []CustomVariable{tt.variable}}, &m, "")
+			if got := m.Variables["value"]; got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -56,8 +56,8 @@ func (p *javaServiceClient) Evaluate(ctx context.Context, cap string, conditionI
 	if err != nil {
 		return provider.ProviderEvaluateResponse{}, fmt.Errorf("unable to get query info: %v", err)
 	}
-	// filepaths get rendered as a string and must be converted
-	if cond.Referenced.Filepaths != nil {
+	// filepaths rendered in a string are separated by spaces and must be converted
+	if len(cond.Referenced.Filepaths) == 1 {
 		cond.Referenced.Filepaths = strings.Split(cond.Referenced.Filepaths[0], " ")
 	}
 
@@ -0,0 +1,228 @@
+package konveyor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.lsp.dev/uri"
+)
+
+// diffContextLines is the number of unchanged lines around the changes of a
+// hunk of a unified diff.
+const diffContextLines = 3
+
+// TextEdit replaces a range of a file with a new text, like the text edits of
+// the language server protocol.
+type TextEdit struct {
+	URI     uri.URI `yaml:"uri" json:"uri"`
+	Range   Range   `yaml:"range" json:"range"`
+	NewText string  `yaml:"newText" json:"newText"`
+}
+
+// Range is the range of a file between two positions, the end is exclusive.
+type Range struct {
+	Start Position `yaml:"start" json:"start"`
+	End   Position `yaml:"end" json:"end"`
+}
+
+// Position is a zero-based line and character of a file.
+type Position struct {
+	Line      int `yaml:"line" json:"line"`
+	Character int `yaml:"character" json:"character"`
+}
+
+func (p Position) before(other Position) bool {
+	if p.Line != other.Line {
+		return p.Line < other.Line
+	}
+	return p.Character < other.Character
+}
+
+// Edits returns the edits of all the incidents of the rulesets.
+func Edits(ruleSets []RuleSet) []TextEdit {
+	edits := []TextEdit{}
+	for _, rs := range ruleSets {
+		for _, violations := range []map[string]Violation{rs.Violations, rs.Insights} {
+			for _, violation := range violations {
+				for _, incident := range violation.Incidents {
+					edits = append(edits, incident.Edits...)
+				}
+			}
+		}
+	}
+	return edits
+}
+
+// UnifiedDiff returns the changes that the edits make to their files as a
+// unified diff, files are read with the given function. Identical edits are
+// applied once, edits that overlap an edit of the same file that starts
+// before them are skipped and returned.
+func UnifiedDiff(edits []TextEdit, readFile func(path string) ([]byte, error)) (string, []TextEdit, error) {
+	files := map[uri.URI][]TextEdit{}
+	for _, edit := range edits {
+		files[edit.URI] = append(files[edit.URI], edit)
+	}
+	uris := []string{}
+	for fileURI := range files {
+		uris = append(uris, string(fileURI))
+	}
+	sort.Strings(uris)
+
+	diff := strings.Builder{}
+	skipped := []TextEdit{}
+	for _, fileURI := range uris {
+		path := uri.URI(fileURI).Filename()
+		content, err := readFile(path)
+		if err != nil {
+			return "", nil, err
+		}
+		fileEdits, fileSkipped := nonOverlappingEdits(files[uri.URI(fileURI)])
+		skipped = append(skipped, fileSkipped...)
+		diff.WriteString(fileDiff(path, string(content), fileEdits))
+	}
+	return diff.String(), skipped, nil
+}
+
+// nonOverlappingEdits sorts the edits and removes the duplicates and the edits
+// that overlap an edit before them.
+func nonOverlappingEdits(edits []TextEdit) ([]TextEdit, []TextEdit) {
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].Range.Start != edits[j].Range.Start {
+			return edits[i].Range.Start.before(edits[j].Range.Start)
+		}
+		return edits[i].Range.End.before(edits[j].Range.End)
+	})
+	kept := []TextEdit{}
+	skipped := []TextEdit{}
+	for _, edit := range edits {
+		if len(kept) > 0 {
+			previous := kept[len(kept)-1]
+			if previous.Range == edit.Range && previous.NewText == edit.NewText {
+				continue
+			}
+			if edit.Range.Start.before(previous.Range.End) || edit.Range.Start == previous.Range.Start {
+				skipped = append(skipped, edit)
+				continue
+			}
+		}
+		kept = append(kept, edit)
+	}
+	return kept, skipped
+}
+
+// diffRegion is a range of lines of a file that edits change, along with the
+// lines that replace them.
+type diffRegion struct {
+	first    int
+	last     int
+	newLines []string
+}
+
+func fileDiff(path string, content string, edits []TextEdit) string {
+	noNewLine := !strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+
+	regions := []diffRegion{}
+	regionEdits := [][]TextEdit{}
+	for _, edit := range edits {
+		if edit.Range.Start.Line < 0 || edit.Range.End.Line >= len(lines) {
+			continue
+		}
+		n := len(regions)
+		// edits on the same or adjacent lines are in the same region
+		if n > 0 && edit.Range.Start.Line <= regions[n-1].last+1 {
+			if edit.Range.End.Line > regions[n-1].last {
+				regions[n-1].last = edit.Range.End.Line
+			}
+			regionEdits[n-1] = append(regionEdits[n-1], edit)
+			continue
+		}
+		regions = append(regions, diffRegion{first: edit.Range.Start.Line, last: edit.Range.End.Line})
+		regionEdits = append(regionEdits, []TextEdit{edit})
+	}
+	if len(regions) == 0 {
+		return ""
+	}
+	for i := range regions {
+		regions[i].newLines = applyEdits(lines[regions[i].first:regions[i].last+1], regions[i].first, regionEdits[i])
+	}
+
+	diff := strings.Builder{}
+	diff.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", path, path))
+	line := func(prefix string, text string, last bool) {
+		diff.WriteString(prefix + text + "\n")
+		if last && noNewLine {
+			diff.WriteString("\\ No newline at end of file\n")
+		}
+	}
+	offset := 0
+	for i := 0; i < len(regions); {
+		// regions with few lines between them are in the same hunk
+		j := i
+		for j+1 < len(regions) && regions[j+1].first-regions[j].last <= 2*diffContextLines+1 {
+			j++
+		}
+		from := max(regions[i].first-diffContextLines, 0)
+		to := min(regions[j].last+diffContextLines, len(lines)-1)
+		oldCount, newCount := to-from+1, to-from+1
+		for _, region := range regions[i : j+1] {
+			newCount += len(region.newLines) - (region.last - region.first + 1)
+		}
+		diff.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", from+1, oldCount, from+1+offset, newCount))
+		next := from
+		for _, region := range regions[i : j+1] {
+			for ; next < region.first; next++ {
+				line(" ", lines[next], next == len(lines)-1)
+			}
+			for ; next <= region.last; next++ {
+				line("-", lines[next], next == len(lines)-1)
+			}
+			for k, newLine := range region.newLines {
+				line("+", newLine, region.last == len(lines)-1 && k == len(region.newLines)-1)
+			}
+		}
+		for ; next <= to; next++ {
+			line(" ", lines[next], next == len(lines)-1)
+		}
+		offset += newCount - oldCount
+		i = j + 1
+	}
+	return diff.String()
+}
+
+// applyEdits applies the sorted edits to the lines, the first of which is the
+// given line of the file, and returns the lines that replace them.
+func applyEdits(lines []string, first int, edits []TextEdit) []string {
+	offset := func(p Position) int {
+		o := 0
+		for _, l := range lines[:p.Line-first] {
+			o += len(l) + 1
+		}
+		return o + byteOffset(lines[p.Line-first], p.Character)
+	}
+	text := strings.Join(lines, "\n")
+	for i := len(edits) - 1; i >= 0; i-- {
+		start, end := offset(edits[i].Range.Start), offset(edits[i].Range.End)
+		text = text[:start] + edits[i].NewText + text[end:]
+	}
+	return strings.Split(text, "\n")
+}
+
+// byteOffset returns the offset in bytes of the character of the line, the end
+// of the line when the line is shorter. Characters are UTF-16 code units as in
+// LSP positions, a character outside of the basic multilingual plane is two.
+func byteOffset(line string, character int) int {
+	i := 0
+	for offset, c := range line {
+		if i >= character {
+			return offset
+		}
+		if c > 0xFFFF {
+			i += 2
+		} else {
+			i++
+		}
+	}
+	return len(line)
+}
@@ -0,0 +1,193 @@
+package konveyor
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"go.lsp.dev/uri"
+)
+
+func lineEdit(line, start, end int, text string) TextEdit {
+	return TextEdit{
+		URI:     uri.File("/app/Main.java"),
+		Range:   Range{Start: Position{Line: line, Character: start}, End: Position{Line: line, Character: end}},
+		NewText: text,
+	}
+}
+
+func TestFileDiff(t *testing.T) {
+	lines := ""
+	for i := 1; i <= 10; i++ {
+		lines += fmt.Sprintf("line%d\n", i)
+	}
+	tests := []struct {
+		name    string
+		content string
+		edits   []TextEdit
+		want    string
+	}{
+		{
+			name:    "edit with lines of context",
+			content: lines,
+			edits:   []TextEdit{lineEdit(4, 0, 5, "five")},
+			want: `--- /app/Main.java
++++ /app/Main.java
+@@ -2,7 +2,7 @@
+ line2
+ line3
+ line4
+-line5
++five
+ line6
+ line7
+ line8
+`,
+		},
+		{
+			name:    "edits far apart in separate hunks",
+			content: lines,
+			edits:   []TextEdit{lineEdit(0, 0, 5, "one\nuno"), lineEdit(9, 0, 6, "ten")},
+			want: `--- /app/Main.java
++++ /app/Main.java
+@@ -1,4 +1,5 @@
+-line1
++one
++uno
+ line2
+ line3
+ line4
+@@ -7,4 +8,4 @@
+ line7
+ line8
+ line9
+-line10
++ten
+`,
+		},
+		{
+			name:    "edits close together in the same hunk",
+			content: lines,
+			edits:   []TextEdit{lineEdit(2, 4, 5, "3!"), lineEdit(6, 4, 5, "7!")},
+			want: `--- /app/Main.java
++++ /app/Main.java
+@@ -1,10 +1,10 @@
+ line1
+ line2
+-line3
++line3!
+ line4
+ line5
+ line6
+-line7
++line7!
+ line8
+ line9
+ line10
+`,
+		},
+		{
+			name:    "edits of the same line",
+			content: "import javax.ejb.EJB; import javax.ejb.Stateless;\n",
+			edits:   []TextEdit{lineEdit(0, 7, 16, "jakarta.ejb"), lineEdit(0, 29, 38, "jakarta.ejb")},
+			want: `--- /app/Main.java
++++ /app/Main.java
+@@ -1,1 +1,1 @@
+-import javax.ejb.EJB; import javax.ejb.Stateless;
++import jakarta.ejb.EJB; import jakarta.ejb.Stateless;
+`,
+		},
+		{
+			name:    "no newline at the end of the file",
+			content: "a\nb",
+			edits:   []TextEdit{lineEdit(1, 0, 1, "c")},
+			want: `--- /app/Main.java
++++ /app/Main.java
+@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++c
+\ No newline at end of file
+`,
+		},
+		{
+			name:    "characters outside of the basic multilingual plane",
+			content: "s = \"😀\"; javax\n",
+			edits:   []TextEdit{lineEdit(0, 10, 15, "jakarta")},
+			want: `--- /app/Main.java
++++ /app/Main.java
+@@ -1,1 +1,1 @@
+-s = "😀"; javax
++s = "😀"; jakarta
+`,
+		},
+		{
+			name:    "edit after the end of the file",
+			content: "a\n",
+			edits:   []TextEdit{lineEdit(3, 0, 1, "c")},
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fileDiff("/app/Main.java", tt.content, tt.edits); got != tt.want {
+				t.Errorf("fileDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	files := map[string]string{
+		"/app/A.java": "import javax.ejb.EJB;\n",
+		"/app/B.java": "import javax.ejb.Stateless;\n",
+	}
+	readFile := func(path string) ([]byte, error) {
+		content, ok := files[path]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(content), nil
+	}
+	edit := func(path string, start, end int, text string) TextEdit {
+		return TextEdit{
+			URI:     uri.File(path),
+			Range:   Range{Start: Position{Character: start}, End: Position{Character: end}},
+			NewText: text,
+		}
+	}
+	overlapping := edit("/app/A.java", 13, 20, "Inject")
+
+	diff, skipped, err := UnifiedDiff([]TextEdit{
+		edit("/app/B.java", 7, 16, "jakarta.ejb"),
+		edit("/app/A.java", 7, 16, "jakarta.ejb"),
+		edit("/app/A.java", 7, 16, "jakarta.ejb"),
+		overlapping,
+	}, readFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `--- /app/A.java
++++ /app/A.java
+@@ -1,1 +1,1 @@
+-import javax.ejb.EJB;
++import jakarta.ejb.EJB;
+--- /app/B.java
++++ /app/B.java
+@@ -1,1 +1,1 @@
+-import javax.ejb.Stateless;
++import jakarta.ejb.Stateless;
+`
+	if diff != want {
+		t.Errorf("UnifiedDiff() = %q, want %q", diff, want)
+	}
+	if !reflect.DeepEqual(skipped, []TextEdit{overlapping}) {
+		t.Errorf("expected the overlapping edit to be skipped, got %v", skipped)
+	}
+
+	if _, _, err := UnifiedDiff([]TextEdit{edit("/app/C.java", 0, 1, "")}, readFile); err == nil {
+		t.Errorf("expected an error for a file that can not be read")
+	}
+}
@@ -0,0 +1,147 @@
+package konveyor
+
+import (
+	"path/filepath"
+	"strings"
+
+	"go.lsp.dev/uri"
+)
+
+// ExternalDirectory groups the incidents in files outside of the analyzed
+// locations, such as the sources of dependencies, in an EffortReport.
+const ExternalDirectory = "<external>"
+
+// EffortTotal is the effort of a number of incidents, the effort of a rule
+// counts once for every incident of the rule.
+type EffortTotal struct {
+	Incidents int `yaml:"incidents" json:"incidents"`
+	Effort    int `yaml:"effort" json:"effort"`
+}
+
+// EffortSummary is the effort of a group of incidents, in total and per
+// category of their rules.
+type EffortSummary struct {
+	EffortTotal `yaml:",inline"`
+
+	// Categories holds the effort of the incidents of the rules with a category.
+	Categories map[Category]EffortTotal `yaml:"categories,omitempty" json:"categories,omitempty"`
+}
+
+func (s EffortSummary) add(category *Category, effort int) EffortSummary {
+	s.Incidents++
+	s.Effort += effort
+	if category != nil {
+		if s.Categories == nil {
+			s.Categories = map[Category]EffortTotal{}
+		}
+		total := s.Categories[*category]
+		total.Incidents++
+		total.Effort += effort
+		s.Categories[*category] = total
+	}
+	return s
+}
+
+// EffortReport adds up the effort of the violations of an analysis, an
+// estimate of the work needed to migrate the analyzed application.
+type EffortReport struct {
+	// Total is the effort of all the violations.
+	Total EffortSummary `yaml:"total" json:"total"`
+
+	// RuleSets is the effort of the violations of each ruleset.
+	RuleSets map[string]EffortSummary `yaml:"ruleSets" json:"ruleSets"`
+
+	// Labels is the effort of the violations of the rules with a label, by the
+	// key and then the value of the label, such as konveyor.io/target and quarkus.
+	Labels map[string]map[string]EffortSummary `yaml:"labels" json:"labels"`
+
+	// Directories is the effort of the incidents in each top-level directory
+	// of the analyzed locations, files right in a location are in ".".
+	Directories map[string]EffortSummary `yaml:"directories" json:"directories"`
+}
+
+// NewEffortReport adds up the effort of the violations of the rulesets, the
+// top-level directories of incidents are found in the given locations.
+// Insights have no effort and are not part of the report.
+func NewEffortReport(ruleSets []RuleSet, locations []string) EffortReport {
+	report := EffortReport{
+		RuleSets:    map[string]EffortSummary{},
+		Labels:      map[string]map[string]EffortSummary{},
+		Directories: map[string]EffortSummary{},
+	}
+	for _, rs := range ruleSets {
+		for _, violation := range rs.Violations {
+			effort := 0
+			if violation.Effort != nil {
+				effort = *violation.Effort
+			}
+			labels := map[string]map[string]bool{}
+			for _, label := range violation.Labels {
+				key, value, ok := strings.Cut(label, "=")
+				if !ok {
+					continue
+				}
+				if labels[key] == nil {
+					labels[key] = map[string]bool{}
+				}
+				labels[key][value] = true
+			}
+			for _, incident := range violation.Incidents {
+				report.Total = report.Total.add(violation.Category, effort)
+				report.RuleSets[rs.Name] = report.RuleSets[rs.Name].add(violation.Category, effort)
+				for key, values := range labels {
+					if report.Labels[key] == nil {
+						report.Labels[key] = map[string]EffortSummary{}
+					}
+					for value := range values {
+						report.Labels[key][value] = report.Labels[key][value].add(violation.Category, effort)
+					}
+				}
+				directory := topLevelDirectory(incident.URI, locations)
+				report.Directories[directory] = report.Directories[directory].add(violation.Category, effort)
+			}
+		}
+	}
+	return report
+}
+
+// topLevelDirectory returns the first directory of the path of the file in the
+// location it is in.
+func topLevelDirectory(fileURI uri.URI, locations []string) string {
+	rel, _, ok := locationRelativePath(fileURI, locations)
+	if !ok {
+		return ExternalDirectory
+	}
+	if directory, _, ok := strings.Cut(rel, "/"); ok {
+		return directory
+	}
+	return "."
+}
+
+// locationRelativePath returns the slash separated path of the file relative
+// to the first location it is in, along with the index of the location.
+func locationRelativePath(fileURI uri.URI, locations []string) (string, int, bool) {
+	if !strings.HasPrefix(string(fileURI), uri.FileScheme+"://") {
+		return "", 0, false
+	}
+	file := fileURI.Filename()
+	for i, location := range locations {
+		candidates := []string{location}
+		if !filepath.IsAbs(location) {
+			// the engine gives the files of a relative location as
+			// file:///<location>/<path> rather than their absolute path
+			candidates = append(candidates, filepath.Join(string(filepath.Separator), location))
+			if abs, err := filepath.Abs(location); err == nil {
+				candidates = append(candidates, abs)
+			}
+		}
+		for _, candidate := range candidates {
+			rel, err := filepath.Rel(candidate, file)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+			return filepath.ToSlash(rel), i, true
+		}
+	}
+	return "", 0, false
+}
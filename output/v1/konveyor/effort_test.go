@@ -0,0 +1,135 @@
+package konveyor
+
+import (
+	"reflect"
+	"testing"
+
+	"go.lsp.dev/uri"
+)
+
+func TestTopLevelDirectory(t *testing.T) {
+	tests := []struct {
+		name      string
+		uri       uri.URI
+		locations []string
+		want      string
+	}{
+		{
+			name:      "absolute location",
+			uri:       "file:///work/app/src/Main.java",
+			locations: []string{"/work/app"},
+			want:      "src",
+		},
+		{
+			name:      "file right in the location",
+			uri:       "file:///work/app/pom.xml",
+			locations: []string{"/work/app"},
+			want:      ".",
+		},
+		{
+			name:      "relative location",
+			uri:       "file:///examples/java/src/Main.java",
+			locations: []string{"examples/java"},
+			want:      "src",
+		},
+		{
+			name:      "relative location starting with a dot",
+			uri:       "file:///examples/java/src/Main.java",
+			locations: []string{"./examples/java"},
+			want:      "src",
+		},
+		{
+			name:      "file outside of the locations",
+			uri:       "file:///root/.m2/repository/io/konveyor/Util.java",
+			locations: []string{"/work/app", "examples/java"},
+			want:      ExternalDirectory,
+		},
+		{
+			name:      "not a file",
+			uri:       "jdt://contents/rt.jar/java.lang/Object.class",
+			locations: []string{"/work/app"},
+			want:      ExternalDirectory,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := topLevelDirectory(tt.uri, tt.locations); got != tt.want {
+				t.Errorf("topLevelDirectory() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewEffortReport(t *testing.T) {
+	mandatory, optional := Mandatory, Optional
+	three, one := 3, 1
+	ruleSets := []RuleSet{
+		{
+			Name: "konveyor",
+			Violations: map[string]Violation{
+				"ejb-00001": {
+					Category: &mandatory,
+					Effort:   &three,
+					Labels:   []string{"konveyor.io/target=quarkus", "konveyor.io/target=jakarta-ee", "konveyor.io/source=java-ee", "no-value"},
+					Incidents: []Incident{
+						{URI: "file:///work/app/src/Main.java"},
+						{URI: "file:///examples/java/pom.xml"},
+					},
+				},
+				"log-00001": {
+					Category: &optional,
+					Effort:   &one,
+					Incidents: []Incident{
+						{URI: "file:///examples/java/src/Log.java"},
+						{URI: "file:///root/.m2/repository/io/konveyor/Util.java"},
+					},
+				},
+			},
+			Insights: map[string]Violation{
+				"info-00001": {Incidents: []Incident{{URI: "file:///work/app/src/Main.java"}}},
+			},
+		},
+		{
+			Name: "other",
+			Violations: map[string]Violation{
+				"config-00001": {
+					Incidents: []Incident{{URI: "file:///work/app/config/app.properties"}},
+				},
+			},
+		},
+	}
+	summary := func(incidents, effort int, categories map[Category]EffortTotal) EffortSummary {
+		return EffortSummary{EffortTotal: EffortTotal{Incidents: incidents, Effort: effort}, Categories: categories}
+	}
+	ejb := summary(2, 6, map[Category]EffortTotal{Mandatory: {Incidents: 2, Effort: 6}})
+	want := EffortReport{
+		Total: summary(5, 8, map[Category]EffortTotal{
+			Mandatory: {Incidents: 2, Effort: 6},
+			Optional:  {Incidents: 2, Effort: 2},
+		}),
+		RuleSets: map[string]EffortSummary{
+			"konveyor": summary(4, 8, map[Category]EffortTotal{
+				Mandatory: {Incidents: 2, Effort: 6},
+				Optional:  {Incidents: 2, Effort: 2},
+			}),
+			"other": summary(1, 0, nil),
+		},
+		Labels: map[string]map[string]EffortSummary{
+			"konveyor.io/target": {"quarkus": ejb, "jakarta-ee": ejb},
+			"konveyor.io/source": {"java-ee": ejb},
+		},
+		Directories: map[string]EffortSummary{
+			"src": summary(2, 4, map[Category]EffortTotal{
+				Mandatory: {Incidents: 1, Effort: 3},
+				Optional:  {Incidents: 1, Effort: 1},
+			}),
+			".":               summary(1, 3, map[Category]EffortTotal{Mandatory: {Incidents: 1, Effort: 3}}),
+			"config":          summary(1, 0, nil),
+			ExternalDirectory: summary(1, 1, map[Category]EffortTotal{Optional: {Incidents: 1, Effort: 1}}),
+		},
+	}
+	got := NewEffortReport(ruleSets, []string{"/work/app", "examples/java"})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NewEffortReport() = %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,142 @@
+package konveyor
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"go.lsp.dev/uri"
+)
+
+// RuleRef identifies a rule across rulesets, rule IDs are only unique within a ruleset.
+type RuleRef struct {
+	RuleSet string
+	RuleID  string
+}
+
+func (r RuleRef) String() string {
+	return fmt.Sprintf("%s/%s", r.RuleSet, r.RuleID)
+}
+
+type incidentLocation struct {
+	uri  uri.URI
+	line int
+}
+
+// incidentRef is an incident of a violation or an insight of a ruleset.
+type incidentRef struct {
+	ruleSet int
+	insight bool
+	rule    RuleRef
+	index   int
+}
+
+// GroupIncidents updates the rulesets in place so that incidents found at the
+// same location by more than one rule are only reported once where a rule
+// supersedes another. Superseded rules are given for every rule, also
+// indirectly through other rules. When crossReference is true, the incidents
+// left at a shared location list the other rules reporting it in RelatedRules.
+// A violation left without incidents makes its rule unmatched.
+func GroupIncidents(ruleSets []RuleSet, supersedes map[RuleRef][]RuleRef, crossReference bool) {
+	locations := map[incidentLocation][]incidentRef{}
+	for i, rs := range ruleSets {
+		for _, violations := range []struct {
+			violations map[string]Violation
+			insight    bool
+		}{
+			{violations: rs.Violations},
+			{violations: rs.Insights, insight: true},
+		} {
+			for ruleID, violation := range violations.violations {
+				for j, incident := range violation.Incidents {
+					location := incidentLocation{uri: incident.URI, line: -1}
+					if incident.LineNumber != nil {
+						location.line = *incident.LineNumber
+					}
+					locations[location] = append(locations[location], incidentRef{
+						ruleSet: i,
+						insight: violations.insight,
+						rule:    RuleRef{RuleSet: rs.Name, RuleID: ruleID},
+						index:   j,
+					})
+				}
+			}
+		}
+	}
+
+	superseded := map[incidentRef]bool{}
+	related := map[incidentRef][]string{}
+	for _, incidents := range locations {
+		reporting := map[RuleRef]bool{}
+		for _, incident := range incidents {
+			reporting[incident.rule] = true
+		}
+		if len(reporting) < 2 {
+			continue
+		}
+		for _, incident := range incidents {
+			for _, other := range supersedes[incident.rule] {
+				delete(reporting, other)
+			}
+		}
+		for _, incident := range incidents {
+			if !reporting[incident.rule] {
+				superseded[incident] = true
+				continue
+			}
+			if !crossReference {
+				continue
+			}
+			for rule := range reporting {
+				if rule != incident.rule {
+					related[incident] = append(related[incident], rule.String())
+				}
+			}
+			sort.Strings(related[incident])
+		}
+	}
+	if len(superseded) == 0 && len(related) == 0 {
+		return
+	}
+
+	for i := range ruleSets {
+		rs := &ruleSets[i]
+		for _, violations := range []struct {
+			violations map[string]Violation
+			insight    bool
+		}{
+			{violations: rs.Violations},
+			{violations: rs.Insights, insight: true},
+		} {
+			for ruleID, violation := range violations.violations {
+				incidents := []Incident{}
+				for j, incident := range violation.Incidents {
+					ref := incidentRef{
+						ruleSet: i,
+						insight: violations.insight,
+						rule:    RuleRef{RuleSet: rs.Name, RuleID: ruleID},
+						index:   j,
+					}
+					if superseded[ref] {
+						continue
+					}
+					if rules, ok := related[ref]; ok {
+						incident.RelatedRules = rules
+					}
+					incidents = append(incidents, incident)
+				}
+				if len(incidents) == 0 {
+					delete(violations.violations, ruleID)
+					// The rule can already be unmatched, such as a tagging rule with a
+					// message, or have its violations and insights superseded.
+					if !slices.Contains(rs.Unmatched, ruleID) {
+						rs.Unmatched = append(rs.Unmatched, ruleID)
+					}
+					continue
+				}
+				violation.Incidents = incidents
+				violations.violations[ruleID] = violation
+			}
+		}
+	}
+}
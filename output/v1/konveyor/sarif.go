@@ -0,0 +1,425 @@
+package konveyor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.lsp.dev/uri"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+
+	sarifToolName = "konveyor-analyzer"
+	sarifToolURI  = "https://github.com/konveyor/analyzer-lsp"
+
+	// sarifFingerprintKey names the partial fingerprint of a result, see Incident.Fingerprint.
+	sarifFingerprintKey = "konveyorIncident/v1"
+
+	// sarifMaxEffort is the effort at or above which a rule gets the highest rank.
+	sarifMaxEffort = 10
+
+	// sarifSourceRoot is the base of the artifact URIs relative to the first
+	// location, the other locations are numbered from 2 such as %SRCROOT2%.
+	sarifSourceRoot = "SRCROOT"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	Invocations        []sarifInvocation                `json:"invocations"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult                    `json:"results"`
+}
+
+type sarifInvocation struct {
+	// ExecutionSuccessful is false when the analysis was canceled before all the rules were evaluated.
+	ExecutionSuccessful bool `json:"executionSuccessful"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string            `json:"name"`
+	InformationURI string            `json:"informationUri"`
+	Rules          []sarifDescriptor `json:"rules"`
+}
+
+type sarifDescriptor struct {
+	ID                   string                 `json:"id"`
+	ShortDescription     *sarifMessage          `json:"shortDescription,omitempty"`
+	HelpURI              string                 `json:"helpUri,omitempty"`
+	Help                 *sarifMessage          `json:"help,omitempty"`
+	DefaultConfiguration sarifConfiguration     `json:"defaultConfiguration"`
+	Properties           map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifConfiguration struct {
+	Level string  `json:"level"`
+	Rank  float64 `json:"rank"`
+}
+
+type sarifMessage struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID              string                 `json:"ruleId"`
+	RuleIndex           int                    `json:"ruleIndex"`
+	Kind                string                 `json:"kind"`
+	Level               string                 `json:"level"`
+	Rank                float64                `json:"rank"`
+	Message             sarifMessage           `json:"message"`
+	Locations           []sarifLocation        `json:"locations,omitempty"`
+	PartialFingerprints map[string]string      `json:"partialFingerprints,omitempty"`
+	Fixes               []sarifFix             `json:"fixes,omitempty"`
+	Properties          map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifFix struct {
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifColumnRegion `json:"deletedRegion"`
+	InsertedContent *sarifSnippet     `json:"insertedContent,omitempty"`
+}
+
+// sarifColumnRegion is a region with one-based lines and columns, the end column is exclusive.
+type sarifColumnRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+	ContextRegion    *sarifRegion          `json:"contextRegion,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int           `json:"startLine"`
+	EndLine   int           `json:"endLine,omitempty"`
+	Snippet   *sarifSnippet `json:"snippet,omitempty"`
+}
+
+type sarifSnippet struct {
+	Text string `json:"text"`
+}
+
+// MarshalSARIF writes the rulesets as a SARIF 2.1.0 log with a single run.
+// Every rule that produced a violation or an insight is described once in the
+// driver rules, rule IDs are qualified by the ruleset name as they are only
+// unique within a ruleset. The files in the locations, the files and
+// directories that were analyzed, are given relative to the location.
+func MarshalSARIF(ruleSets []RuleSet, locations []string) ([]byte, error) {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           sarifToolName,
+				InformationURI: sarifToolURI,
+				Rules:          []sarifDescriptor{},
+			},
+		},
+		Invocations: []sarifInvocation{{ExecutionSuccessful: true}},
+		Results:     []sarifResult{},
+	}
+	artifacts := sarifArtifacts{locations: locations}
+
+	descriptors := map[string]int{}
+	sorted := make([]RuleSet, len(ruleSets))
+	copy(sorted, ruleSets)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	for _, rs := range sorted {
+		if rs.Incomplete {
+			run.Invocations[0].ExecutionSuccessful = false
+		}
+		for _, violations := range []struct {
+			violations map[string]Violation
+			insight    bool
+		}{
+			{violations: rs.Violations},
+			{violations: rs.Insights, insight: true},
+		} {
+			ruleIDs := make([]string, 0, len(violations.violations))
+			for ruleID := range violations.violations {
+				ruleIDs = append(ruleIDs, ruleID)
+			}
+			sort.Strings(ruleIDs)
+			for _, ruleID := range ruleIDs {
+				v := violations.violations[ruleID]
+				v.sortFields()
+				descriptor := sarifRuleDescriptor(rs, ruleID, v, violations.insight)
+				// A rule that creates tags can have both violations and
+				// insights, it is described by its violations.
+				index, ok := descriptors[descriptor.ID]
+				if !ok {
+					run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, descriptor)
+					index = len(run.Tool.Driver.Rules) - 1
+					descriptors[descriptor.ID] = index
+				}
+				for _, incident := range v.Incidents {
+					run.Results = append(run.Results, sarifIncidentResult(&artifacts, descriptor, index, ruleID, v, incident, violations.insight))
+				}
+			}
+		}
+	}
+
+	run.OriginalURIBaseIDs = artifacts.baseIDs
+	return json.MarshalIndent(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+}
+
+func sarifRuleDescriptor(rs RuleSet, ruleID string, v Violation, insight bool) sarifDescriptor {
+	id := ruleID
+	if rs.Name != "" {
+		id = fmt.Sprintf("%s/%s", rs.Name, ruleID)
+	}
+	descriptor := sarifDescriptor{
+		ID: id,
+		DefaultConfiguration: sarifConfiguration{
+			Level: sarifLevel(v.Category, insight),
+			Rank:  sarifRank(v.Effort),
+		},
+		Properties: map[string]interface{}{
+			"ruleSet": rs.Name,
+		},
+	}
+	if v.Description != "" {
+		descriptor.ShortDescription = &sarifMessage{Text: v.Description}
+	}
+	if rs.Description != "" {
+		descriptor.Properties["ruleSetDescription"] = rs.Description
+	}
+	if len(v.Labels) > 0 {
+		descriptor.Properties["tags"] = v.Labels
+	}
+	if v.Category != nil {
+		descriptor.Properties["category"] = string(*v.Category)
+	}
+	if v.Effort != nil {
+		descriptor.Properties["effort"] = *v.Effort
+	}
+	if len(v.Links) > 0 {
+		descriptor.HelpURI = v.Links[0].URL
+		text := []string{}
+		markdown := []string{}
+		for _, link := range v.Links {
+			title := link.Title
+			if title == "" {
+				title = link.URL
+			}
+			text = append(text, fmt.Sprintf("%s: %s", title, link.URL))
+			markdown = append(markdown, fmt.Sprintf("- [%s](%s)", title, link.URL))
+		}
+		descriptor.Help = &sarifMessage{
+			Text:     strings.Join(text, "\n"),
+			Markdown: strings.Join(markdown, "\n"),
+		}
+	}
+	return descriptor
+}
+
+func sarifIncidentResult(artifacts *sarifArtifacts, descriptor sarifDescriptor, index int, ruleID string, v Violation, incident Incident, insight bool) sarifResult {
+	kind := "fail"
+	if insight {
+		kind = "informational"
+	}
+	message := incident.Message
+	if message == "" {
+		message = v.Description
+	}
+	result := sarifResult{
+		RuleID:    descriptor.ID,
+		RuleIndex: index,
+		Kind:      kind,
+		Level:     sarifLevel(v.Category, insight),
+		Rank:      descriptor.DefaultConfiguration.Rank,
+		Message:   sarifMessage{Text: message},
+		PartialFingerprints: map[string]string{
+			sarifFingerprintKey: incident.Fingerprint(ruleID),
+		},
+	}
+	if len(incident.Variables) > 0 {
+		result.Properties = map[string]interface{}{
+			"variables": incident.Variables,
+		}
+	}
+	if fix := sarifIncidentFix(artifacts, incident.Edits); fix != nil {
+		result.Fixes = []sarifFix{*fix}
+	}
+	if incident.URI == "" {
+		return result
+	}
+	location := sarifPhysicalLocation{
+		ArtifactLocation: artifacts.location(incident.URI),
+	}
+	if incident.LineNumber != nil && *incident.LineNumber > 0 {
+		location.Region = &sarifRegion{StartLine: *incident.LineNumber}
+		if line := incident.codeLine(); line != "" {
+			location.Region.Snippet = &sarifSnippet{Text: line}
+		}
+		// A context region is only valid next to the region it surrounds.
+		location.ContextRegion = sarifContextRegion(incident.CodeSnip)
+	}
+	result.Locations = []sarifLocation{{PhysicalLocation: location}}
+	return result
+}
+
+// sarifIncidentFix turns the edits of an incident into a fix with the changes
+// to every file they edit.
+func sarifIncidentFix(artifacts *sarifArtifacts, edits []TextEdit) *sarifFix {
+	if len(edits) == 0 {
+		return nil
+	}
+	fix := sarifFix{}
+	changes := map[string]int{}
+	for _, edit := range edits {
+		i, ok := changes[string(edit.URI)]
+		if !ok {
+			i = len(fix.ArtifactChanges)
+			changes[string(edit.URI)] = i
+			fix.ArtifactChanges = append(fix.ArtifactChanges, sarifArtifactChange{
+				ArtifactLocation: artifacts.location(edit.URI),
+			})
+		}
+		replacement := sarifReplacement{
+			DeletedRegion: sarifColumnRegion{
+				StartLine:   edit.Range.Start.Line + 1,
+				StartColumn: edit.Range.Start.Character + 1,
+				EndLine:     edit.Range.End.Line + 1,
+				EndColumn:   edit.Range.End.Character + 1,
+			},
+		}
+		if edit.NewText != "" {
+			replacement.InsertedContent = &sarifSnippet{Text: edit.NewText}
+		}
+		fix.ArtifactChanges[i].Replacements = append(fix.ArtifactChanges[i].Replacements, replacement)
+	}
+	return &fix
+}
+
+// sarifArtifacts gives the locations of the files of a run, relative to the
+// location they are in, and the base URIs of the locations they are given
+// relative to.
+type sarifArtifacts struct {
+	locations []string
+	baseIDs   map[string]sarifArtifactLocation
+}
+
+func (a *sarifArtifacts) location(fileURI uri.URI) sarifArtifactLocation {
+	rel, i, ok := locationRelativePath(fileURI, a.locations)
+	if !ok || rel == "." {
+		return sarifArtifactLocation{URI: string(fileURI)}
+	}
+	baseID := sarifSourceRoot
+	if i > 0 {
+		baseID = fmt.Sprintf("%s%d", sarifSourceRoot, i+1)
+	}
+	baseID = "%" + baseID + "%"
+	if a.baseIDs == nil {
+		a.baseIDs = map[string]sarifArtifactLocation{}
+	}
+	if _, ok := a.baseIDs[baseID]; !ok {
+		location := a.locations[i]
+		if abs, err := filepath.Abs(location); err == nil {
+			location = abs
+		}
+		// base URIs must end with a slash for the relative URIs to resolve
+		// under them
+		a.baseIDs[baseID] = sarifArtifactLocation{URI: strings.TrimSuffix(string(uri.File(location)), "/") + "/"}
+	}
+	return sarifArtifactLocation{URI: (&url.URL{Path: rel}).String(), URIBaseID: baseID}
+}
+
+// sarifContextRegion turns a code snip into the region it covers, with the line
+// numbers removed from the snippet text.
+func sarifContextRegion(codeSnip string) *sarifRegion {
+	region := &sarifRegion{}
+	lines := []string{}
+	for _, line := range strings.Split(codeSnip, "\n") {
+		match := codeSnipLineRegex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		n, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		if region.StartLine == 0 {
+			region.StartLine = n
+		}
+		region.EndLine = n
+		lines = append(lines, match[2])
+	}
+	if region.StartLine == 0 {
+		return nil
+	}
+	region.Snippet = &sarifSnippet{Text: strings.Join(lines, "\n")}
+	return region
+}
+
+// sarifLevel maps the category of a violation to a SARIF level, violations
+// without a category are reported as warnings.
+func sarifLevel(category *Category, insight bool) string {
+	if insight {
+		return "note"
+	}
+	if category == nil {
+		return "warning"
+	}
+	switch *category {
+	case Mandatory:
+		return "error"
+	case Optional:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// sarifRank scales the effort of a violation to the 0-100 SARIF rank range.
+func sarifRank(effort *int) float64 {
+	if effort == nil || *effort <= 0 {
+		return 0
+	}
+	if *effort >= sarifMaxEffort {
+		return 100
+	}
+	return float64(*effort) * 100 / sarifMaxEffort
+}
@@ -0,0 +1,131 @@
+package konveyor
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the tests")
+
+func TestMarshalSARIF(t *testing.T) {
+	mandatory, optional, potential := Mandatory, Optional, Potential
+	three, twelve := 3, 12
+	line, noLine := 4, 0
+	tests := []struct {
+		name      string
+		ruleSets  []RuleSet
+		locations []string
+	}{
+		{
+			name:      "rulesets",
+			locations: []string{"/app/src", "/app"},
+			ruleSets: []RuleSet{
+				{
+					Name:        "konveyor",
+					Description: "Migration to Quarkus",
+					Incomplete:  true,
+					Violations: map[string]Violation{
+						"ejb-00001": {
+							Description: "EJB annotations",
+							Category:    &mandatory,
+							Effort:      &three,
+							Labels:      []string{"konveyor.io/target=quarkus", "konveyor.io/source=java-ee"},
+							Links: []Link{
+								{URL: "https://quarkus.io/guides/cdi", Title: "CDI"},
+								{URL: "https://jakarta.ee/specifications/cdi/"},
+							},
+							Incidents: []Incident{
+								{
+									URI:        "file:///app/src/Main.java",
+									Message:    "Replace @Stateless with @ApplicationScoped",
+									LineNumber: &line,
+									CodeSnip:   "3  import javax.ejb.Stateless;\n4  @Stateless\n5  public class Main {",
+									Variables:  map[string]interface{}{"annotation": "Stateless"},
+									Edits: []TextEdit{
+										{
+											URI:     "file:///app/src/Main.java",
+											Range:   Range{Start: Position{Line: 3, Character: 0}, End: Position{Line: 3, Character: 10}},
+											NewText: "@ApplicationScoped",
+										},
+										{
+											URI:   "file:///app/src/Main.java",
+											Range: Range{Start: Position{Line: 2, Character: 0}, End: Position{Line: 3, Character: 0}},
+										},
+									},
+								},
+							},
+						},
+						"config-00001": {
+							Description: "Properties files",
+							Category:    &optional,
+							Effort:      &twelve,
+							Incidents: []Incident{
+								{URI: "file:///app/src/app.properties", Message: "Move the properties", LineNumber: &noLine},
+							},
+						},
+						"log-00001": {
+							Description: "Logging",
+							Category:    &potential,
+							Incidents:   []Incident{{URI: "file:///app/pom.xml"}},
+						},
+						"tech-00002": {
+							Description: "Dependency on EJB",
+							Category:    &optional,
+							Incidents:   []Incident{{URI: "file:///root/.m2/repository/javax/ejb/ejb-api.pom", Message: "EJB is used"}},
+						},
+						"jni-00001": {
+							Incidents: []Incident{{Message: "Native libraries are used"}},
+						},
+					},
+					Insights: map[string]Violation{
+						"tech-00002": {
+							Description: "Dependency on EJB",
+							Incidents:   []Incident{{URI: "file:///app/my%20lib/pom.xml"}},
+						},
+						"tech-00001": {
+							Description: "Java EE",
+							Incidents:   []Incident{{URI: "file:///app/pom.xml", Message: "Java EE is used"}},
+						},
+					},
+				},
+				{
+					Name: "empty",
+				},
+			},
+		},
+		{
+			name: "no rulesets",
+		},
+		{
+			name:     "rulesets without violations",
+			ruleSets: []RuleSet{{Name: "konveyor", Unmatched: []string{"ejb-00001"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MarshalSARIF(tt.ruleSets, tt.locations)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", "sarif", filepath.Base(t.Name())+".json")
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, append(got, '\n'), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(append(got, '\n'), want) {
+				t.Errorf("MarshalSARIF() does not match %s, run the tests with -update to update it:\n%s", golden, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package konveyor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Duration is a time.Duration written with its unit, such as 1.5s or 2m3.25s,
+// rather than as a number of nanoseconds. A number is still read as
+// nanoseconds, as written by earlier versions.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return err
+	}
+	return d.set(value)
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value interface{}
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	return d.set(value)
+}
+
+func (d *Duration) set(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		duration, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(duration)
+	case int:
+		*d = Duration(v)
+	case int64:
+		*d = Duration(v)
+	case uint64:
+		*d = Duration(v)
+	case float64:
+		*d = Duration(v)
+	default:
+		return fmt.Errorf("invalid duration %v", value)
+	}
+	return nil
+}
+
+// RuleStats describes what it took to evaluate a rule.
+type RuleStats struct {
+	// Duration is the wall-clock time spent evaluating the conditions of the rule.
+	Duration Duration `yaml:"duration" json:"duration"`
+
+	// ProviderCalls is the number of calls made to providers while evaluating the rule.
+	ProviderCalls int `yaml:"providerCalls" json:"providerCalls"`
+
+	// Providers are the names of the providers that were called.
+	Providers []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+
+	// IncidentsFound is the number of incidents returned by the conditions of the rule.
+	IncidentsFound int `yaml:"incidentsFound" json:"incidentsFound"`
+
+	// IncidentsReported is the number of incidents left after scopes, selectors,
+	// limits and the baseline filtered them.
+	IncidentsReported int `yaml:"incidentsReported" json:"incidentsReported"`
+
+	// Cached is true when the result of the rule came from the rule cache.
+	Cached bool `yaml:"cached,omitempty" json:"cached,omitempty"`
+}
+
+// Add merges the stats of another evaluation of the same rule, such as the
+// message part of a rule that also creates tags.
+func (s RuleStats) Add(other RuleStats) RuleStats {
+	s.Duration += other.Duration
+	s.ProviderCalls += other.ProviderCalls
+	for _, name := range other.Providers {
+		found := false
+		for _, existing := range s.Providers {
+			if existing == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			s.Providers = append(s.Providers, name)
+		}
+	}
+	sort.Strings(s.Providers)
+	if other.IncidentsFound > s.IncidentsFound {
+		s.IncidentsFound = other.IncidentsFound
+	}
+	if other.IncidentsReported > s.IncidentsReported {
+		s.IncidentsReported = other.IncidentsReported
+	}
+	s.Cached = s.Cached && other.Cached
+	return s
+}
+
+// StatsReport lists the stats of every evaluated rule, slowest rules first.
+type StatsReport struct {
+	// Rules is the number of evaluated rules.
+	Rules int `yaml:"rules" json:"rules"`
+
+	// Duration is the time spent evaluating all the rules, rules evaluated
+	// concurrently add up.
+	Duration Duration `yaml:"duration" json:"duration"`
+
+	// ProviderCalls is the number of calls made to providers by all the rules.
+	ProviderCalls int `yaml:"providerCalls" json:"providerCalls"`
+
+	RuleStats []RuleStatsEntry `yaml:"ruleStats" json:"ruleStats"`
+}
+
+// RuleStatsEntry is the stats of a rule in a StatsReport.
+type RuleStatsEntry struct {
+	RuleSet   string `yaml:"ruleSet" json:"ruleSet"`
+	RuleID    string `yaml:"ruleID" json:"ruleID"`
+	RuleStats `yaml:",inline"`
+}
+
+// NewStatsReport collects the stats of the rulesets in a report.
+func NewStatsReport(ruleSets []RuleSet) StatsReport {
+	report := StatsReport{RuleStats: []RuleStatsEntry{}}
+	for _, rs := range ruleSets {
+		for ruleID, stats := range rs.Stats {
+			report.Rules++
+			report.Duration += stats.Duration
+			report.ProviderCalls += stats.ProviderCalls
+			report.RuleStats = append(report.RuleStats, RuleStatsEntry{
+				RuleSet:   rs.Name,
+				RuleID:    ruleID,
+				RuleStats: stats,
+			})
+		}
+	}
+	sort.Slice(report.RuleStats, func(i, j int) bool {
+		a, b := report.RuleStats[i], report.RuleStats[j]
+		if a.Duration != b.Duration {
+			return a.Duration > b.Duration
+		}
+		if a.RuleSet != b.RuleSet {
+			return a.RuleSet < b.RuleSet
+		}
+		return a.RuleID < b.RuleID
+	})
+	return report
+}
@@ -0,0 +1,60 @@
+package konveyor
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestDuration(t *testing.T) {
+	stats := RuleStats{Duration: Duration(1500 * time.Millisecond), ProviderCalls: 2}
+
+	b, err := yaml.Marshal(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "duration: 1.5s\nproviderCalls: 2\nincidentsFound: 0\nincidentsReported: 0\n"
+	if string(b) != want {
+		t.Errorf("yaml.Marshal() = %q, want %q", b, want)
+	}
+	b, err = json.Marshal(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"duration":"1.5s","providerCalls":2,"incidentsFound":0,"incidentsReported":0}`; string(b) != want {
+		t.Errorf("json.Marshal() = %s, want %s", b, want)
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		json    string
+		want    Duration
+		wantErr bool
+	}{
+		{name: "with a unit", yaml: "duration: 2m3.25s", json: `{"duration":"2m3.25s"}`, want: Duration(123250 * time.Millisecond)},
+		{name: "nanoseconds of earlier versions", yaml: "duration: 1500000000", json: `{"duration":1500000000}`, want: Duration(1500 * time.Millisecond)},
+		{name: "invalid", yaml: "duration: soon", json: `{"duration":"soon"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fromYAML, fromJSON := RuleStats{}, RuleStats{}
+			yamlErr := yaml.Unmarshal([]byte(tt.yaml), &fromYAML)
+			jsonErr := json.Unmarshal([]byte(tt.json), &fromJSON)
+			if tt.wantErr {
+				if yamlErr == nil || jsonErr == nil {
+					t.Errorf("expected errors, got %v and %v", yamlErr, jsonErr)
+				}
+				return
+			}
+			if yamlErr != nil || jsonErr != nil {
+				t.Fatalf("unexpected errors %v and %v", yamlErr, jsonErr)
+			}
+			if fromYAML.Duration != tt.want || fromJSON.Duration != tt.want {
+				t.Errorf("expected %s, got %s from YAML and %s from JSON", tt.want, fromYAML.Duration, fromJSON.Duration)
+			}
+		})
+	}
+}
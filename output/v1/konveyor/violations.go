@@ -1,8 +1,13 @@
 package konveyor
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"go.lsp.dev/uri"
@@ -43,6 +48,34 @@ type RuleSet struct {
 
 	// Skipped is a list of rule IDs that were skipped
 	Skipped []string `yaml:"skipped,omitempty" json:"skipped,omitempty"`
+
+	// Incomplete is true when the analysis was canceled before all the rules
+	// in this ruleset were evaluated, the rules that were not evaluated are
+	// in Errors.
+	Incomplete bool `yaml:"incomplete,omitempty" json:"incomplete,omitempty"`
+
+	// Stats is a map containing how the rules in this ruleset were
+	// evaluated, only set when stats are requested. Keys are rule IDs.
+	Stats map[string]RuleStats `yaml:"stats,omitempty" json:"stats,omitempty"`
+
+	// Provenance is the bundle the ruleset was loaded from, when it was
+	// distributed as an archive or an OCI layout.
+	Provenance *Provenance `yaml:"provenance,omitempty" json:"provenance,omitempty"`
+}
+
+// Provenance describes the bundle that a ruleset was loaded from.
+type Provenance struct {
+	// Bundle is the path of the archive or OCI layout directory.
+	Bundle string `yaml:"bundle" json:"bundle"`
+	// Name and Version are given by the manifest of the bundle, or by the
+	// annotations of an OCI image.
+	Name    string `yaml:"name,omitempty" json:"name,omitempty"`
+	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+	// Checksum is the checksum of the content of the bundle that was verified
+	// against its manifest.
+	Checksum string `yaml:"checksum,omitempty" json:"checksum,omitempty"`
+	// Digest is the digest of the manifest of an OCI image.
+	Digest string `yaml:"digest,omitempty" json:"digest,omitempty"`
 }
 
 // Sorts all fields in a canonical way on a RuleSet
@@ -131,6 +164,18 @@ type Incident struct {
 	// Extras json.RawMessage
 	LineNumber *int                   `yaml:"lineNumber,omitempty" json:"lineNumber,omitempty"`
 	Variables  map[string]interface{} `yaml:"variables,omitempty" json:"variables,omitempty"`
+
+	// LineHash is the hash of the matched line of the file, see LineHash, for
+	// incidents without the line in their code snip. It identifies the incident
+	// in the baseline of later runs like the line of the code snip does.
+	LineHash string `yaml:"lineHash,omitempty" json:"lineHash,omitempty"`
+
+	// RelatedRules are the other rules, given as ruleset/ruleID, that found an
+	// incident at the same location when incidents are grouped.
+	RelatedRules []string `yaml:"relatedRules,omitempty" json:"relatedRules,omitempty"`
+
+	// Edits are the changes to the code that the fix of the rule suggests.
+	Edits []TextEdit `yaml:"edits,omitempty" json:"edits,omitempty"`
 }
 
 // Lexicographically compares two Incidents
@@ -164,6 +209,52 @@ func (i *Incident) cmpLess(other *Incident) bool {
 	return false
 }
 
+var codeSnipLineRegex = regexp.MustCompile(`^\s*([0-9]+)  (.*)$`)
+
+// Fingerprint identifies an incident of the given rule by the file it is in and
+// the matched line of the source, so that it stays the same when lines are
+// added or removed elsewhere in the file. The line is taken from the code snip,
+// which has the lines of the file as they are, or from the line hash of an
+// incident without a code snip, such as an incident past the
+// --limit-code-snips of its file. An incident with neither, such as an
+// incident that is not in a local file, is identified by its message instead.
+func (i *Incident) Fingerprint(ruleID string) string {
+	content := i.LineHash
+	if line := i.codeLine(); line != "" {
+		content = LineHash(line)
+	}
+	if content == "" {
+		// Without the source we can only rely on the message.
+		content = i.Message
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", ruleID, i.URI, content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// LineHash returns the hash of a line of source with whitespace normalized.
+func LineHash(line string) string {
+	h := sha256.Sum256([]byte(strings.Join(strings.Fields(line), " ")))
+	return hex.EncodeToString(h[:])
+}
+
+// codeLine returns the matched line from the code snip with whitespace normalized.
+func (i *Incident) codeLine() string {
+	if i.LineNumber == nil || i.CodeSnip == "" {
+		return ""
+	}
+	for _, line := range strings.Split(i.CodeSnip, "\n") {
+		match := codeSnipLineRegex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		if n, err := strconv.Atoi(match[1]); err == nil && n == *i.LineNumber {
+			return strings.Join(strings.Fields(match[2]), " ")
+		}
+	}
+	return ""
+}
+
 // Link defines an external hyperlink
 type Link struct {
 	URL string `yaml:"url" json:"url"`
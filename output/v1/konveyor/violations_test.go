@@ -0,0 +1,93 @@
+package konveyor
+
+import (
+	"testing"
+)
+
+func TestIncidentFingerprint(t *testing.T) {
+	line, moved := 4, 9
+	incident := Incident{
+		URI:        "file:///app/src/Main.java",
+		Message:    "Replace @Stateless",
+		LineNumber: &line,
+		CodeSnip:   "3  import javax.ejb.Stateless;\n4  @Stateless\n5  public class Main {",
+	}
+	tests := []struct {
+		name     string
+		incident Incident
+		same     bool
+	}{
+		{
+			name: "same line moved in the file",
+			incident: Incident{
+				URI:        incident.URI,
+				Message:    incident.Message,
+				LineNumber: &moved,
+				CodeSnip:   "8  \n9  @Stateless\n10  public class Main {",
+			},
+			same: true,
+		},
+		{
+			name: "same line with other whitespace and message",
+			incident: Incident{
+				URI:        incident.URI,
+				Message:    "Replace @Stateless with @ApplicationScoped",
+				LineNumber: &line,
+				CodeSnip:   "4      @Stateless  ",
+			},
+			same: true,
+		},
+		{
+			name: "other line",
+			incident: Incident{
+				URI:        incident.URI,
+				Message:    incident.Message,
+				LineNumber: &line,
+				CodeSnip:   "4  @Singleton",
+			},
+		},
+		{
+			name: "other file",
+			incident: Incident{
+				URI:        "file:///app/src/Other.java",
+				Message:    incident.Message,
+				LineNumber: &line,
+				CodeSnip:   incident.CodeSnip,
+			},
+		},
+		{
+			// An incident past the code snip limit of its file has the hash of its line.
+			name: "same line with a line hash instead of a code snip",
+			incident: Incident{
+				URI:        incident.URI,
+				Message:    incident.Message,
+				LineNumber: &moved,
+				LineHash:   LineHash("  @Stateless"),
+			},
+			same: true,
+		},
+		{
+			name: "same line without a code snip or a line hash",
+			incident: Incident{
+				URI:        incident.URI,
+				Message:    incident.Message,
+				LineNumber: &line,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := tt.incident.Fingerprint("ejb-00001") == incident.Fingerprint("ejb-00001"); same != tt.same {
+				t.Errorf("expected the fingerprints to be the same: %t, got %t", tt.same, same)
+			}
+		})
+	}
+
+	withoutSnip := Incident{URI: incident.URI, Message: incident.Message, LineNumber: &moved}
+	if (&Incident{URI: incident.URI, Message: incident.Message, LineNumber: &line}).Fingerprint("ejb-00001") != withoutSnip.Fingerprint("ejb-00001") {
+		t.Errorf("expected incidents without a code snip to be identified by their message")
+	}
+	if incident.Fingerprint("ejb-00001") == incident.Fingerprint("ejb-00002") {
+		t.Errorf("expected incidents of other rules to have other fingerprints")
+	}
+}
@@ -0,0 +1,368 @@
+package parser
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// BundleManifestFileName is the optional manifest at the root of a bundle.
+	BundleManifestFileName = "manifest.yaml"
+
+	ociLayoutFileName    = "oci-layout"
+	ociIndexFileName     = "index.json"
+	ociVersionAnnotation = "org.opencontainers.image.version"
+	ociTitleAnnotation   = "org.opencontainers.image.title"
+	ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+	// orasUnpackAnnotation marks the layers of directories pushed by oras,
+	// they are archives to extract rather than files.
+	orasUnpackAnnotation = "io.deis.oras.content.unpack"
+)
+
+// BundleManifest describes the rules of a bundle, it is optional. The checksum
+// is verified when it is set.
+type BundleManifest struct {
+	Name    string `yaml:"name,omitempty"`
+	Version string `yaml:"version,omitempty"`
+	// Checksum is sha256: followed by the SHA-256 of the lines of sha256sum
+	// for every file of the bundle but the manifest, sorted by path.
+	Checksum string `yaml:"checksum,omitempty"`
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ociIndex struct {
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	Layers      []ociDescriptor   `json:"layers"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// isBundle returns whether the path is a bundle of rules, a .zip or .tar.gz
+// archive or an OCI layout directory.
+func isBundle(path string) bool {
+	name := strings.ToLower(path)
+	if strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz") {
+		return true
+	}
+	info, err := os.Stat(filepath.Join(path, ociLayoutFileName))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// openBundle extracts a bundle to a temporary directory and verifies it, the
+// directory is removed by the returned function.
+func openBundle(path string) (string, *konveyor.Provenance, func(), error) {
+	dir, err := os.MkdirTemp("", "rules-bundle-")
+	if err != nil {
+		return "", nil, nil, err
+	}
+	cleanup := func() {
+		os.RemoveAll(dir)
+	}
+	provenance, err := extractBundle(path, dir)
+	if err != nil {
+		cleanup()
+		return "", nil, nil, fmt.Errorf("unable to open bundle %s: %w", path, err)
+	}
+	return dir, provenance, cleanup, nil
+}
+
+func extractBundle(path string, dir string) (*konveyor.Provenance, error) {
+	provenance := &konveyor.Provenance{Bundle: path}
+	name := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		if err := extractZip(path, dir); err != nil {
+			return nil, err
+		}
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if err := extractTarGz(f, dir); err != nil {
+			return nil, err
+		}
+	default:
+		if err := extractOCILayout(path, dir, provenance); err != nil {
+			return nil, err
+		}
+	}
+
+	manifest, err := readBundleManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	// the manifest is not a rules file
+	defer os.Remove(filepath.Join(dir, BundleManifestFileName))
+	if manifest != nil {
+		if manifest.Checksum != "" {
+			checksum, err := bundleChecksum(dir)
+			if err != nil {
+				return nil, err
+			}
+			if !strings.EqualFold(checksum, manifest.Checksum) {
+				return nil, fmt.Errorf("checksum %s does not match the checksum %s of the manifest", checksum, manifest.Checksum)
+			}
+			provenance.Checksum = checksum
+		}
+		if manifest.Name != "" {
+			provenance.Name = manifest.Name
+		}
+		if manifest.Version != "" {
+			provenance.Version = manifest.Version
+		}
+	}
+	return provenance, nil
+}
+
+func readBundleManifest(dir string) (*BundleManifest, error) {
+	content, err := os.ReadFile(filepath.Join(dir, BundleManifestFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	manifest := BundleManifest{}
+	if err := yaml.UnmarshalStrict(content, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// bundleChecksum returns the checksum of the files of an extracted bundle but
+// its manifest, the same as
+//
+//	find . -type f ! -path ./manifest.yaml | sed 's|^\./||' | LC_ALL=C sort | xargs sha256sum | sha256sum
+func bundleChecksum(dir string) (string, error) {
+	files := []string{}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel = filepath.ToSlash(rel); rel != BundleManifestFileName {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+	sum := sha256.New()
+	for _, file := range files {
+		fileSum, err := fileDigest(filepath.Join(dir, filepath.FromSlash(file)))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(sum, "%s  %s\n", fileSum, file)
+	}
+	return "sha256:" + hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sum := sha256.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// bundlePath returns the path in the directory of a file of an archive, files
+// outside of the directory are not allowed.
+func bundlePath(dir string, name string) (string, error) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file %s is outside of the bundle", name)
+	}
+	return path, nil
+}
+
+func writeBundleFile(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func extractZip(path string, dir string) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	for _, file := range archive.File {
+		target, err := bundlePath(dir, file.Name)
+		if err != nil {
+			return err
+		}
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		r, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = writeBundleFile(target, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractTarGz(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	return extractTar(gz, dir)
+}
+
+// extractTar extracts the directories and regular files of a tar archive,
+// links are skipped.
+func extractTar(r io.Reader, dir string) error {
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target, err := bundlePath(dir, header.Name)
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeBundleFile(target, archive); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// extractOCILayout extracts the layers of the image of an OCI layout
+// directory, the digest of every blob is verified.
+func extractOCILayout(path string, dir string, provenance *konveyor.Provenance) error {
+	index := ociIndex{}
+	content, err := os.ReadFile(filepath.Join(path, ociIndexFileName))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, &index); err != nil {
+		return fmt.Errorf("invalid %s: %w", ociIndexFileName, err)
+	}
+	if len(index.Manifests) != 1 {
+		return fmt.Errorf("OCI layout must have a single image, it has %d", len(index.Manifests))
+	}
+	descriptor := index.Manifests[0]
+	content, err = readOCIBlob(path, descriptor.Digest)
+	if err != nil {
+		return err
+	}
+	manifest := ociManifest{}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return fmt.Errorf("invalid image manifest: %w", err)
+	}
+	provenance.Digest = descriptor.Digest
+	provenance.Name = descriptor.Annotations[ociRefNameAnnotation]
+	provenance.Version = manifest.Annotations[ociVersionAnnotation]
+
+	for _, layer := range manifest.Layers {
+		content, err := readOCIBlob(path, layer.Digest)
+		if err != nil {
+			return err
+		}
+		title := layer.Annotations[ociTitleAnnotation]
+		switch {
+		case title != "" && layer.Annotations[orasUnpackAnnotation] != "true":
+			// a file pushed as a layer of an artifact
+			target, pathErr := bundlePath(dir, title)
+			if pathErr != nil {
+				return pathErr
+			}
+			err = writeBundleFile(target, bytes.NewReader(content))
+		case strings.HasSuffix(layer.MediaType, "tar+gzip") || strings.HasSuffix(layer.MediaType, "tar.gzip"):
+			err = extractTarGz(bytes.NewReader(content), dir)
+		case strings.HasSuffix(layer.MediaType, ".tar"):
+			err = extractTar(bytes.NewReader(content), dir)
+		default:
+			return fmt.Errorf("layer %s has unsupported media type %s", layer.Digest, layer.MediaType)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to extract layer %s: %w", layer.Digest, err)
+		}
+	}
+	return nil
+}
+
+// readOCIBlob reads a blob of an OCI layout and verifies its digest.
+func readOCIBlob(path string, digest string) ([]byte, error) {
+	algorithm, encoded, ok := strings.Cut(digest, ":")
+	if !ok || algorithm != "sha256" || strings.ContainsAny(encoded, `/\.`) {
+		return nil, fmt.Errorf("unsupported digest %s", digest)
+	}
+	content, err := os.ReadFile(filepath.Join(path, "blobs", algorithm, encoded))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) != strings.ToLower(encoded) {
+		return nil, fmt.Errorf("blob %s does not match its digest", digest)
+	}
+	return content, nil
+}
@@ -0,0 +1,227 @@
+package parser_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+	ruleparser "github.com/konveyor/analyzer-lsp/parser"
+	"github.com/konveyor/analyzer-lsp/provider"
+)
+
+var bundleFiles = map[string]string{
+	"ruleset.yaml": "name: bundled\n",
+	"rules.yaml": `- ruleID: bundled-001
+  message: Properties file
+  category: optional
+  effort: 1
+  when:
+    builtin.file:
+      pattern: "*.properties"
+`,
+}
+
+// bundleChecksum is the checksum of the files of a bundle as written in its
+// manifest, sha256sum of the files sorted by path.
+func bundleChecksum(files map[string]string) string {
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := ""
+	for _, name := range names {
+		sum := sha256.Sum256([]byte(files[name]))
+		lines += fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), name)
+	}
+	sum := sha256.Sum256([]byte(lines))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func withManifest(files map[string]string, manifest string) map[string]string {
+	withManifest := map[string]string{ruleparser.BundleManifestFileName: manifest}
+	for name, content := range files {
+		withManifest[name] = content
+	}
+	return withManifest
+}
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	w := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeOCILayout writes an OCI layout with an image of a single layer with the
+// files, it returns the digest of the image manifest.
+func writeOCILayout(t *testing.T, dir string, files map[string]string, tamper bool) string {
+	writeBlob := func(content []byte) string {
+		sum := sha256.Sum256(content)
+		digest := hex.EncodeToString(sum[:])
+		if tamper {
+			content = append(content, '\n')
+		}
+		os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755)
+		if err := os.WriteFile(filepath.Join(dir, "blobs", "sha256", digest), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return "sha256:" + digest
+	}
+	layer := writeBlob(tarGz(t, files))
+	manifest, _ := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"layers":        []map[string]interface{}{{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": layer}},
+		"annotations":   map[string]string{"org.opencontainers.image.version": "2.0.0"},
+	})
+	tamper = false
+	manifestDigest := writeBlob(manifest)
+	index, _ := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"manifests": []map[string]interface{}{{
+			"mediaType":   "application/vnd.oci.image.manifest.v1+json",
+			"digest":      manifestDigest,
+			"annotations": map[string]string{"org.opencontainers.image.ref.name": "rules"},
+		}},
+	})
+	os.WriteFile(filepath.Join(dir, "index.json"), index, 0644)
+	os.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion": "1.0.0"}`), 0644)
+	return manifestDigest
+}
+
+func TestLoadRulesBundle(t *testing.T) {
+	dir := t.TempDir()
+	checksum := bundleChecksum(bundleFiles)
+	tests := []struct {
+		name         string
+		write        func(path string) *konveyor.Provenance
+		bundle       string
+		errorMessage string
+	}{
+		{
+			name:   "zip with a manifest",
+			bundle: "rules.zip",
+			write: func(path string) *konveyor.Provenance {
+				writeZip(t, path, withManifest(bundleFiles, fmt.Sprintf("name: rules\nversion: 1.2.0\nchecksum: %s\n", checksum)))
+				return &konveyor.Provenance{Bundle: path, Name: "rules", Version: "1.2.0", Checksum: checksum}
+			},
+		},
+		{
+			name:   "tar.gz without a manifest",
+			bundle: "rules.tar.gz",
+			write: func(path string) *konveyor.Provenance {
+				os.WriteFile(path, tarGz(t, bundleFiles), 0644)
+				return &konveyor.Provenance{Bundle: path}
+			},
+		},
+		{
+			name:   "OCI layout",
+			bundle: "oci",
+			write: func(path string) *konveyor.Provenance {
+				digest := writeOCILayout(t, path, bundleFiles, false)
+				return &konveyor.Provenance{Bundle: path, Name: "rules", Version: "2.0.0", Digest: digest}
+			},
+		},
+		{
+			name:   "checksum that does not match",
+			bundle: "changed.zip",
+			write: func(path string) *konveyor.Provenance {
+				files := withManifest(bundleFiles, fmt.Sprintf("checksum: %s\n", checksum))
+				files["rules.yaml"] += "\n"
+				writeZip(t, path, files)
+				return nil
+			},
+			errorMessage: "does not match the checksum",
+		},
+		{
+			name:   "OCI blob that does not match its digest",
+			bundle: "tampered",
+			write: func(path string) *konveyor.Provenance {
+				writeOCILayout(t, path, bundleFiles, true)
+				return nil
+			},
+			errorMessage: "does not match its digest",
+		},
+		{
+			name:   "file outside of the bundle",
+			bundle: "outside.zip",
+			write: func(path string) *konveyor.Provenance {
+				writeZip(t, path, map[string]string{"../rules.yaml": bundleFiles["rules.yaml"]})
+				return nil
+			},
+			errorMessage: "is outside of the bundle",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.bundle)
+			want := tt.write(path)
+			ruleParser := ruleparser.RuleParser{
+				ProviderNameToClient: map[string]provider.InternalProviderClient{
+					"builtin": testProvider{caps: []provider.Capability{{Name: "file"}}},
+				},
+				Log: logr.Discard(),
+			}
+			ruleSets, _, err := ruleParser.LoadRules(path)
+			if tt.errorMessage != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorMessage) {
+					t.Fatalf("expected error %q, got %v", tt.errorMessage, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ruleSets) != 1 || ruleSets[0].Name != "bundled" || len(ruleSets[0].Rules) != 1 {
+				t.Fatalf("expected ruleset bundled with a rule, got %v", ruleSets)
+			}
+			if !reflect.DeepEqual(ruleSets[0].Provenance, want) {
+				t.Errorf("expected provenance %#v, got %#v", want, ruleSets[0].Provenance)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/konveyor/analyzer-lsp/engine"
+	"gopkg.in/yaml.v2"
+)
+
+// parameterReference matches a reference to a parameter of a ruleset, such as
+// ${package}.
+var parameterReference = regexp.MustCompile(`\$\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}`)
+
+// RuleSetImport imports the rules of another ruleset into a ruleset.
+type RuleSetImport struct {
+	// Path is the directory of the imported ruleset, relative to the
+	// directory of the importing ruleset.
+	Path string `yaml:"path"`
+	// Rules are the IDs of the rules that are imported, all of the rules are
+	// imported when it is empty.
+	Rules []string `yaml:"rules,omitempty"`
+	// Parameters are the values of the parameters of the imported ruleset.
+	Parameters map[string]string `yaml:"parameters,omitempty"`
+}
+
+// RuleSetParameter is a value that is substituted into the rules of a
+// ruleset where they reference it as ${name}.
+type RuleSetParameter struct {
+	Name        string  `yaml:"name"`
+	Description string  `yaml:"description,omitempty"`
+	Default     *string `yaml:"default,omitempty"`
+}
+
+// ruleSetFile is the content of the golden file of a ruleset.
+type ruleSetFile struct {
+	engine.RuleSet `yaml:",inline"`
+	Imports        []RuleSetImport    `yaml:"imports,omitempty"`
+	Parameters     []RuleSetParameter `yaml:"parameters,omitempty"`
+	// Overrides replace the fields of imported rules, each override has the
+	// ruleID of the rule along with the fields that are replaced.
+	Overrides []map[string]interface{} `yaml:"overrides,omitempty"`
+}
+
+// ruleFile is the rules of a file before they are parsed.
+type ruleFile struct {
+	path  string
+	rules []map[string]interface{}
+}
+
+// readRuleSetFile reads the golden file of a ruleset, it returns nil when the
+// directory has none.
+func readRuleSetFile(dir string) (*ruleSetFile, error) {
+	goldenFile := filepath.Join(dir, RULE_SET_GOLDEN_FILE_NAME)
+	info, err := os.Stat(goldenFile)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, nil
+	}
+	content, err := os.ReadFile(goldenFile)
+	if err != nil {
+		return nil, err
+	}
+	set := ruleSetFile{}
+	if err := yaml.Unmarshal(content, &set); err != nil {
+		return nil, err
+	}
+	if len(set.Rules) != 0 {
+		return nil, fmt.Errorf("rules should not be added in the ruleset")
+	}
+	return &set, nil
+}
+
+// parameterValues returns the values of the parameters of a ruleset, the given
+// values or else the defaults.
+func parameterValues(set *ruleSetFile, values map[string]string) (map[string]string, error) {
+	if set == nil {
+		if len(values) > 0 {
+			return nil, fmt.Errorf("parameters are given for a directory that is not a ruleset")
+		}
+		return nil, nil
+	}
+	declared := map[string]bool{}
+	resolved := map[string]string{}
+	for _, parameter := range set.Parameters {
+		if parameter.Name == "" {
+			return nil, fmt.Errorf("parameters of ruleset %s must have a name", set.Name)
+		}
+		declared[parameter.Name] = true
+		if value, ok := values[parameter.Name]; ok {
+			resolved[parameter.Name] = value
+		} else if parameter.Default != nil {
+			resolved[parameter.Name] = *parameter.Default
+		} else {
+			return nil, fmt.Errorf("parameter %s of ruleset %s has no value", parameter.Name, set.Name)
+		}
+	}
+	for name := range values {
+		if !declared[name] {
+			return nil, fmt.Errorf("ruleset %s has no parameter %s", set.Name, name)
+		}
+	}
+	return resolved, nil
+}
+
+// substituteParameters replaces the references to parameters in the strings of
+// a value, references to names that are not parameters are kept as they are.
+func substituteParameters(value interface{}, parameters map[string]string) interface{} {
+	if len(parameters) == 0 {
+		return value
+	}
+	switch v := value.(type) {
+	case string:
+		return parameterReference.ReplaceAllStringFunc(v, func(reference string) string {
+			if value, ok := parameters[parameterReference.FindStringSubmatch(reference)[1]]; ok {
+				return value
+			}
+			return reference
+		})
+	case map[interface{}]interface{}:
+		substituted := make(map[interface{}]interface{}, len(v))
+		for key, item := range v {
+			substituted[key] = substituteParameters(item, parameters)
+		}
+		return substituted
+	case []interface{}:
+		substituted := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			substituted = append(substituted, substituteParameters(item, parameters))
+		}
+		return substituted
+	default:
+		return v
+	}
+}
+
+// substituteRuleParameters replaces the references to parameters in every
+// field of the rules but their IDs.
+func substituteRuleParameters(rules []map[string]interface{}, parameters map[string]string) {
+	for _, rule := range rules {
+		for key, value := range rule {
+			if key != "ruleID" {
+				rule[key] = substituteParameters(value, parameters)
+			}
+		}
+	}
+}
+
+// readRuleFile reads the rules of a file with the parameters substituted.
+func (r *RuleParser) readRuleFile(path string, parameters map[string]string) ([]map[string]interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		r.Log.V(8).Error(err, "filepath", path)
+		return nil, err
+	}
+	rules := []map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &rules); err != nil {
+		r.Log.V(8).Info("unable to load rule set, failed to convert file to yaml -- skipping", "file", path, "error", err)
+		return nil, nil
+	}
+	substituteRuleParameters(rules, parameters)
+	return rules, nil
+}
+
+// importRules returns the rules that a ruleset imports, with the parameters of
+// the imported rulesets substituted and the overrides of the ruleset applied.
+// The parameters are the values of the parameters of the importing ruleset,
+// importing lists the rulesets being imported to find cycles.
+func (r *RuleParser) importRules(dir string, set *ruleSetFile, parameters map[string]string, importing []string) ([]ruleFile, error) {
+	imported := []ruleFile{}
+	for _, ruleSetImport := range set.Imports {
+		if ruleSetImport.Path == "" {
+			return nil, fmt.Errorf("imports of ruleset %s must have a path", set.Name)
+		}
+		importDir, err := filepath.Abs(filepath.Join(dir, ruleSetImport.Path))
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range importing {
+			if d == importDir {
+				return nil, fmt.Errorf("rulesets import each other in a cycle: %s", strings.Join(append(importing, importDir), " -> "))
+			}
+		}
+		files, err := r.readImportedRuleSet(importDir, ruleSetImport, parameters, append(importing, importDir))
+		if err != nil {
+			return nil, fmt.Errorf("unable to import %s into ruleset %s: %w", ruleSetImport.Path, set.Name, err)
+		}
+		imported = append(imported, files...)
+	}
+
+	for _, override := range set.Overrides {
+		ruleID, ok := override["ruleID"].(string)
+		if !ok {
+			return nil, fmt.Errorf("overrides of ruleset %s must have a ruleID", set.Name)
+		}
+		for key := range override {
+			if !ruleFields[key] {
+				return nil, fmt.Errorf("override of rule %s has unknown field %s", ruleID, key)
+			}
+		}
+		found := false
+		for _, file := range imported {
+			for _, rule := range file.rules {
+				if rule["ruleID"] != ruleID {
+					continue
+				}
+				found = true
+				for key, value := range override {
+					if key != "ruleID" {
+						rule[key] = substituteParameters(value, parameters)
+					}
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("override of rule %s does not match an imported rule of ruleset %s", ruleID, set.Name)
+		}
+	}
+	return imported, nil
+}
+
+// readImportedRuleSet reads the rules of the rule files of an imported ruleset
+// along with the rules it imports.
+func (r *RuleParser) readImportedRuleSet(dir string, ruleSetImport RuleSetImport, parameters map[string]string, importing []string) ([]ruleFile, error) {
+	set, err := readRuleSetFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	for name, value := range ruleSetImport.Parameters {
+		values[name], _ = substituteParameters(value, parameters).(string)
+	}
+	values, err = parameterValues(set, values)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := []ruleFile{}
+	for _, entry := range entries {
+		if entry.IsDir() || !isRuleFile(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		rules, err := r.readRuleFile(path, values)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, ruleFile{path: path, rules: rules})
+	}
+	if set != nil {
+		nested, err := r.importRules(dir, set, values, importing)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, nested...)
+	}
+
+	if len(ruleSetImport.Rules) == 0 {
+		return files, nil
+	}
+	selected := map[string]bool{}
+	for _, ruleID := range ruleSetImport.Rules {
+		selected[ruleID] = false
+	}
+	for i, file := range files {
+		rules := []map[string]interface{}{}
+		for _, rule := range file.rules {
+			ruleID, _ := rule["ruleID"].(string)
+			if _, ok := selected[ruleID]; ok {
+				selected[ruleID] = true
+				rules = append(rules, rule)
+			}
+		}
+		files[i].rules = rules
+	}
+	for _, ruleID := range ruleSetImport.Rules {
+		if !selected[ruleID] {
+			return nil, fmt.Errorf("rule %s is not in the ruleset", ruleID)
+		}
+	}
+	return files, nil
+}
+
+// isRuleFile returns whether a file of a ruleset directory has rules, it is
+// not the golden file of the ruleset or a tests file.
+func isRuleFile(name string) bool {
+	return name != RULE_SET_GOLDEN_FILE_NAME &&
+		!strings.HasSuffix(name, ".test.yaml") &&
+		!strings.HasSuffix(name, ".test.yml")
+}
@@ -0,0 +1,158 @@
+package parser_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/konveyor/analyzer-lsp/engine"
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+	ruleparser "github.com/konveyor/analyzer-lsp/parser"
+	"github.com/konveyor/analyzer-lsp/provider"
+)
+
+func TestLoadRulesImports(t *testing.T) {
+	type expectedRule struct {
+		message   string
+		category  konveyor.Category
+		effort    int
+		labels    []string
+		condition interface{}
+	}
+	tests := []struct {
+		name         string
+		ruleSet      string
+		rules        map[string]expectedRule
+		errorMessage string
+	}{
+		{
+			name:    "parameters of a ruleset default to their default values",
+			ruleSet: "base",
+			rules: map[string]expectedRule{
+				"base-001": {
+					message:   "Replace the use of javax.ejb",
+					category:  konveyor.Potential,
+					effort:    1,
+					labels:    []string{"konveyor.io/source=base"},
+					condition: map[interface{}]interface{}{"pattern": "import javax.ejb"},
+				},
+				"base-002": {
+					message:   "Remove the configuration of javax.ejb, ${server.port} is kept",
+					category:  konveyor.Potential,
+					effort:    1,
+					condition: map[interface{}]interface{}{"pattern": "javax.ejb.xml"},
+				},
+			},
+		},
+		{
+			name:    "imported rules with parameters and overrides",
+			ruleSet: "target",
+			rules: map[string]expectedRule{
+				"target-001": {
+					message:   "Properties file",
+					category:  konveyor.Optional,
+					effort:    1,
+					condition: map[interface{}]interface{}{"pattern": "*.properties"},
+				},
+				"base-001": {
+					message:   "Replace the use of jakarta.ejb",
+					category:  konveyor.Mandatory,
+					effort:    5,
+					labels:    []string{"konveyor.io/target=jakarta"},
+					condition: map[interface{}]interface{}{"pattern": "import jakarta.ejb"},
+				},
+			},
+		},
+		{
+			name:    "imports of the ruleset of a single rule file",
+			ruleSet: "target/rules.yaml",
+			rules: map[string]expectedRule{
+				"target-001": {
+					message:   "Properties file",
+					category:  konveyor.Optional,
+					effort:    1,
+					condition: map[interface{}]interface{}{"pattern": "*.properties"},
+				},
+				"base-001": {
+					message:   "Replace the use of jakarta.ejb",
+					category:  konveyor.Mandatory,
+					effort:    5,
+					labels:    []string{"konveyor.io/target=jakarta"},
+					condition: map[interface{}]interface{}{"pattern": "import jakarta.ejb"},
+				},
+			},
+		},
+		{
+			name:         "rulesets that import each other",
+			ruleSet:      "cycle-a",
+			errorMessage: "rulesets import each other in a cycle",
+		},
+		{
+			name:         "unknown parameter of an imported ruleset",
+			ruleSet:      "unknown-parameter",
+			errorMessage: "ruleset base has no parameter version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ruleParser := ruleparser.RuleParser{
+				ProviderNameToClient: map[string]provider.InternalProviderClient{
+					"builtin": testProvider{
+						caps: []provider.Capability{{Name: "file"}, {Name: "filecontent"}},
+					},
+				},
+				Log: logr.Discard(),
+			}
+			ruleSets, _, err := ruleParser.LoadRules(filepath.Join("testdata", "imports", tt.ruleSet))
+			if tt.errorMessage != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorMessage) {
+					t.Fatalf("expected error %q, got %v", tt.errorMessage, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// A single rule file is named after the directory of its ruleset.
+			name := strings.Split(tt.ruleSet, "/")[0]
+			if len(ruleSets) != 1 || ruleSets[0].Name != name {
+				t.Fatalf("expected ruleset %s, got %v", name, ruleSets)
+			}
+			if len(ruleSets[0].Rules) != len(tt.rules) {
+				t.Fatalf("expected %d rules, got %d", len(tt.rules), len(ruleSets[0].Rules))
+			}
+			for _, rule := range ruleSets[0].Rules {
+				want, ok := tt.rules[rule.RuleID]
+				if !ok {
+					t.Errorf("unexpected rule %s", rule.RuleID)
+					continue
+				}
+				if rule.Perform.Message.Text == nil || *rule.Perform.Message.Text != want.message {
+					t.Errorf("rule %s: expected message %q, got %v", rule.RuleID, want.message, rule.Perform.Message.Text)
+				}
+				if rule.Category == nil || *rule.Category != want.category {
+					t.Errorf("rule %s: expected category %s, got %v", rule.RuleID, want.category, rule.Category)
+				}
+				if rule.Effort == nil || *rule.Effort != want.effort {
+					t.Errorf("rule %s: expected effort %d, got %v", rule.RuleID, want.effort, rule.Effort)
+				}
+				if len(want.labels) > 0 && !reflect.DeepEqual(rule.Labels, want.labels) {
+					t.Errorf("rule %s: expected labels %v, got %v", rule.RuleID, want.labels, rule.Labels)
+				}
+				entry, ok := rule.When.(engine.ConditionEntry)
+				if !ok {
+					t.Fatalf("rule %s: expected a condition, got %T", rule.RuleID, rule.When)
+				}
+				condition, ok := entry.ProviderSpecificConfig.(provider.ProviderCondition)
+				if !ok {
+					t.Fatalf("rule %s: expected a provider condition, got %T", rule.RuleID, entry.ProviderSpecificConfig)
+				}
+				if !reflect.DeepEqual(condition.ConditionInfo, want.condition) {
+					t.Errorf("rule %s: expected condition %v, got %v", rule.RuleID, want.condition, condition.ConditionInfo)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/konveyor/analyzer-lsp/engine"
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+	"github.com/konveyor/analyzer-lsp/provider"
+	"github.com/swaggest/jsonschema-go"
+	"github.com/swaggest/openapi-go/openapi3"
+)
+
+const (
+	jsonSchemaDraft       = "http://json-schema.org/draft-07/schema#"
+	jsonSchemaDefinitions = "#/definitions/"
+	// jsonSchemaTemplate matches a template of a chained condition, such as
+	// {{poms.extras.filepaths}}.
+	jsonSchemaTemplate = `^\s*\{\{.*\}\}\s*$`
+)
+
+// CreateJSONSchema returns a JSON Schema for rule files, a list of rules, and
+// for the ruleset.yaml files of rulesets. The conditions of rules are the
+// capabilities of the providers, with the input that each capability takes.
+func CreateJSONSchema(providers map[string]provider.InternalProviderClient) jsonschema.Schema {
+	definitions := map[string]jsonschema.SchemaOrBool{
+		"rule":           ruleJSONSchema(),
+		"ruleset":        rulesetJSONSchema(),
+		"link":           linkJSONSchema(),
+		"import":         importJSONSchema(),
+		"parameter":      parameterJSONSchema(),
+		"override":       overrideJSONSchema(),
+		"customVariable": customVariableJSONSchema(),
+		"fix":            fixJSONSchema(),
+		"count":          countJSONSchema(),
+	}
+
+	// The components of the spec are empty, capabilities are reflected with
+	// their types inlined.
+	spec := &openapi3.Spec{Components: &openapi3.Components{Schemas: &openapi3.ComponentsSchemas{}}}
+	capabilities := []string{}
+	for providerName, client := range providers {
+		for _, c := range client.Capabilities() {
+			name := fmt.Sprintf("%s.%s", providerName, c.Name)
+			input := c.Input.ToJSONSchema(spec)
+			if input.TypeObject != nil {
+				delete(input.TypeObject.ExtraProperties, "components")
+				for property, schema := range input.TypeObject.Properties {
+					input.TypeObject.Properties[property] = templateJSONSchema(schema)
+				}
+			}
+			definitions[name] = input
+			capabilities = append(capabilities, name)
+		}
+	}
+	sort.Strings(capabilities)
+	definitions["condition"] = conditionJSONSchema(capabilities)
+
+	schema := jsonschema.Schema{}
+	schema.WithSchema(jsonSchemaDraft).
+		WithTitle("Konveyor analyzer rules").
+		WithDescription("A list of rules, or the ruleset.yaml file of a ruleset").
+		WithOneOf(
+			listJSONSchema(refJSONSchema("rule"), "rules"),
+			refJSONSchema("ruleset"),
+		).
+		WithDefinitions(definitions)
+	return schema
+}
+
+func ruleJSONSchema() jsonschema.SchemaOrBool {
+	return objectJSONSchema("a rule, its conditions and the actions taken on the incidents", map[string]jsonschema.SchemaOrBool{
+		"ruleID":          typeJSONSchema(jsonschema.String, "ID of the rule, unique in its ruleset"),
+		"description":     typeJSONSchema(jsonschema.String, "description of the rule"),
+		"labels":          listJSONSchema(typeJSONSchema(jsonschema.String, ""), "labels of the rule, such as konveyor.io/source=java"),
+		"category":        enumJSONSchema("category of the incidents", konveyor.Mandatory, konveyor.Optional, konveyor.Potential),
+		"effort":          typeJSONSchema(jsonschema.Integer, "effort to fix an incident of the rule"),
+		"message":         typeJSONSchema(jsonschema.String, "message of the incidents, a template that can use their variables"),
+		"links":           listJSONSchema(refJSONSchema("link"), "links to documentation about the incidents"),
+		"tag":             listJSONSchema(typeJSONSchema(jsonschema.String, ""), "tags added to the application when the rule matches"),
+		"fix":             refJSONSchema("fix"),
+		"customVariables": listJSONSchema(refJSONSchema("customVariable"), "variables of the incidents, found with patterns"),
+		"timeout":         typeJSONSchema(jsonschema.String, "how long the rule can take to evaluate, a duration such as 30s or 5m"),
+		"dependsOn":       listJSONSchema(typeJSONSchema(jsonschema.String, ""), "IDs of the rules that must match for the rule to be evaluated"),
+		"supersedes":      listJSONSchema(typeJSONSchema(jsonschema.String, ""), "IDs of the rules whose incidents are not reported when the rule matches"),
+		"when":            refJSONSchema("condition"),
+	}, "ruleID", "when")
+}
+
+func rulesetJSONSchema() jsonschema.SchemaOrBool {
+	return objectJSONSchema("the ruleset.yaml file of a ruleset", map[string]jsonschema.SchemaOrBool{
+		"name":        typeJSONSchema(jsonschema.String, "name of the ruleset"),
+		"description": typeJSONSchema(jsonschema.String, "description of the ruleset"),
+		"labels":      listJSONSchema(typeJSONSchema(jsonschema.String, ""), "labels added to every rule of the ruleset"),
+		"tags":        listJSONSchema(typeJSONSchema(jsonschema.String, ""), "tags of the ruleset"),
+		"imports":     listJSONSchema(refJSONSchema("import"), "rulesets whose rules are imported into the ruleset"),
+		"parameters":  listJSONSchema(refJSONSchema("parameter"), "values substituted into the rules of the ruleset where they reference them as ${name}"),
+		"overrides":   listJSONSchema(refJSONSchema("override"), "fields replaced on imported rules"),
+	}, "name")
+}
+
+func importJSONSchema() jsonschema.SchemaOrBool {
+	parameters := typeJSONSchema(jsonschema.Object, "values of the parameters of the imported ruleset")
+	parameters.TypeObject.WithAdditionalProperties(typeJSONSchema(jsonschema.String, ""))
+	return objectJSONSchema("a ruleset whose rules are imported", map[string]jsonschema.SchemaOrBool{
+		"path":       typeJSONSchema(jsonschema.String, "directory of the imported ruleset, relative to the ruleset"),
+		"rules":      listJSONSchema(typeJSONSchema(jsonschema.String, ""), "IDs of the imported rules, all of the rules when it is not set"),
+		"parameters": parameters,
+	}, "path")
+}
+
+func parameterJSONSchema() jsonschema.SchemaOrBool {
+	return objectJSONSchema("a parameter of the ruleset", map[string]jsonschema.SchemaOrBool{
+		"name":        typeJSONSchema(jsonschema.String, "name of the parameter"),
+		"description": typeJSONSchema(jsonschema.String, "description of the parameter"),
+		"default":     typeJSONSchema(jsonschema.String, "value used when the importing ruleset does not give one"),
+	}, "name")
+}
+
+// overrideJSONSchema returns the schema of an override, the fields of a rule
+// of which only the ID is required.
+func overrideJSONSchema() jsonschema.SchemaOrBool {
+	override := ruleJSONSchema()
+	override.TypeObject.WithDescription("fields replaced on an imported rule").WithRequired("ruleID")
+	return override
+}
+
+func linkJSONSchema() jsonschema.SchemaOrBool {
+	return objectJSONSchema("a link to documentation", map[string]jsonschema.SchemaOrBool{
+		"url":   typeJSONSchema(jsonschema.String, "URL of the link"),
+		"title": typeJSONSchema(jsonschema.String, "title of the link"),
+	}, "url")
+}
+
+func customVariableJSONSchema() jsonschema.SchemaOrBool {
+	return objectJSONSchema("a variable of the incidents", map[string]jsonschema.SchemaOrBool{
+		"name":               typeJSONSchema(jsonschema.String, "name of the variable"),
+		"pattern":            typeJSONSchema(jsonschema.String, "regular expression matched against the source"),
+		"nameOfCaptureGroup": typeJSONSchema(jsonschema.String, "capture group of the pattern whose match is the value"),
+		"defaultValue":       typeJSONSchema(jsonschema.String, "value used when the pattern does not match"),
+		"source": enumJSONSchema("text the pattern is matched against",
+			engine.LineVariableSource, engine.LinesVariableSource, engine.FileVariableSource, engine.ProviderVariableSource),
+		"linesBefore": minimumJSONSchema(typeJSONSchema(jsonschema.Integer, "lines before the incident matched by the lines source"), 0),
+		"linesAfter":  minimumJSONSchema(typeJSONSchema(jsonschema.Integer, "lines after the incident matched by the lines source"), 0),
+		"variable":    typeJSONSchema(jsonschema.String, "variable of the provider used by the variable source"),
+	}, "name")
+}
+
+func fixJSONSchema() jsonschema.SchemaOrBool {
+	return objectJSONSchema("edits suggested for the incidents", map[string]jsonschema.SchemaOrBool{
+		"replace": typeJSONSchema(jsonschema.String, "regular expression of the text to replace"),
+		"with":    typeJSONSchema(jsonschema.String, "replacement of the matches of replace"),
+		"text":    typeJSONSchema(jsonschema.String, "text replacing the lines of the incident"),
+	})
+}
+
+func countJSONSchema() jsonschema.SchemaOrBool {
+	return objectJSONSchema("matches when the number of incidents of the condition is within min and max", map[string]jsonschema.SchemaOrBool{
+		"condition": refJSONSchema("condition"),
+		"of":        enumJSONSchema("what is counted", engine.IncidentsCount, engine.FilesCount),
+		"min":       minimumJSONSchema(typeJSONSchema(jsonschema.Integer, "smallest count that matches"), 0),
+		"max":       minimumJSONSchema(typeJSONSchema(jsonschema.Integer, "largest count that matches"), 0),
+	}, "condition")
+}
+
+// conditionJSONSchema returns the schema of a condition, which is exactly one
+// of and, or, count or a capability of a provider, along with the fields that
+// chain and change it.
+func conditionJSONSchema(capabilities []string) jsonschema.SchemaOrBool {
+	properties := map[string]jsonschema.SchemaOrBool{
+		"and":        listJSONSchema(refJSONSchema("condition"), "matches when all of the conditions match"),
+		"or":         listJSONSchema(refJSONSchema("condition"), "matches when any of the conditions match"),
+		"count":      refJSONSchema("count"),
+		"from":       typeJSONSchema(jsonschema.String, "name of the condition whose output the condition uses"),
+		"as":         typeJSONSchema(jsonschema.String, "name of the output of the condition"),
+		"ignore":     typeJSONSchema(jsonschema.Boolean, "do not report the incidents of the condition"),
+		"not":        typeJSONSchema(jsonschema.Boolean, "match when the condition does not match"),
+		"correlate":  enumJSONSchema("keep the incidents of an and condition that are close to each other", engine.FileCorrelation, engine.LinesCorrelation),
+		"within":     minimumJSONSchema(typeJSONSchema(jsonschema.Integer, "number of lines between the incidents correlated by lines"), 0),
+		"evaluation": enumJSONSchema("how the conditions of and or an or condition are evaluated", engine.EagerEvaluation, engine.LazyEvaluation),
+	}
+	conditions := []string{"and", "or", "count"}
+	for _, capability := range capabilities {
+		properties[capability] = refJSONSchema(capability)
+		conditions = append(conditions, capability)
+	}
+	oneOf := []jsonschema.SchemaOrBool{}
+	for _, condition := range conditions {
+		oneOf = append(oneOf, (&jsonschema.Schema{}).WithRequired(condition).ToSchemaOrBool())
+	}
+	condition := objectJSONSchema("a condition of a rule", properties)
+	condition.TypeObject.WithOneOf(oneOf...)
+	return condition
+}
+
+// templateJSONSchema allows the values of the schema that are not strings, and
+// the items and properties of the values, to be a template of a chained
+// condition that the output of another condition fills in.
+func templateJSONSchema(schema jsonschema.SchemaOrBool) jsonschema.SchemaOrBool {
+	if schema.TypeObject == nil {
+		return schema
+	}
+	s := schema.TypeObject
+	for property, propertySchema := range s.Properties {
+		s.Properties[property] = templateJSONSchema(propertySchema)
+	}
+	if s.Items != nil && s.Items.SchemaOrBool != nil {
+		items := templateJSONSchema(*s.Items.SchemaOrBool)
+		s.Items.SchemaOrBool = &items
+	}
+	if s.Type == nil || (s.Type.SimpleTypes != nil && *s.Type.SimpleTypes == jsonschema.String) {
+		return schema
+	}
+	template := typeJSONSchema(jsonschema.String, "template filled in with the output of a chained condition")
+	template.TypeObject.WithPattern(jsonSchemaTemplate)
+	wrapped := (&jsonschema.Schema{}).WithAnyOf(schema, template)
+	if s.Description != nil {
+		wrapped.WithDescription(*s.Description)
+	}
+	return wrapped.ToSchemaOrBool()
+}
+
+// objectJSONSchema returns the schema of an object with the properties, other
+// properties are not allowed.
+func objectJSONSchema(description string, properties map[string]jsonschema.SchemaOrBool, required ...string) jsonschema.SchemaOrBool {
+	schema := typeJSONSchema(jsonschema.Object, description)
+	schema.TypeObject.WithProperties(properties).
+		WithAdditionalProperties(*(&jsonschema.SchemaOrBool{}).WithTypeBoolean(false))
+	if len(required) > 0 {
+		schema.TypeObject.WithRequired(required...)
+	}
+	return schema
+}
+
+func typeJSONSchema(t jsonschema.SimpleType, description string) jsonschema.SchemaOrBool {
+	schema := (&jsonschema.Schema{}).WithType(t.Type())
+	if description != "" {
+		schema.WithDescription(description)
+	}
+	return schema.ToSchemaOrBool()
+}
+
+func listJSONSchema(items jsonschema.SchemaOrBool, description string) jsonschema.SchemaOrBool {
+	schema := typeJSONSchema(jsonschema.Array, description)
+	schema.TypeObject.WithItems(*(&jsonschema.Items{}).WithSchemaOrBool(items))
+	return schema
+}
+
+func enumJSONSchema[T ~string](description string, values ...T) jsonschema.SchemaOrBool {
+	schema := typeJSONSchema(jsonschema.String, description)
+	for _, value := range values {
+		schema.TypeObject.Enum = append(schema.TypeObject.Enum, string(value))
+	}
+	return schema
+}
+
+func minimumJSONSchema(schema jsonschema.SchemaOrBool, minimum float64) jsonschema.SchemaOrBool {
+	schema.TypeObject.WithMinimum(minimum)
+	return schema
+}
+
+func refJSONSchema(name string) jsonschema.SchemaOrBool {
+	return (&jsonschema.Schema{}).WithRef(jsonSchemaDefinitions + name).ToSchemaOrBool()
+}
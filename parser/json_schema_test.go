@@ -0,0 +1,356 @@
+package parser_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	ruleparser "github.com/konveyor/analyzer-lsp/parser"
+	"github.com/konveyor/analyzer-lsp/provider"
+	"github.com/konveyor/analyzer-lsp/provider/lib"
+	"github.com/swaggest/openapi-go/openapi3"
+	"gopkg.in/yaml.v3"
+)
+
+type fileCondition struct {
+	Pattern string `json:"pattern"`
+}
+
+func TestCreateJSONSchema(t *testing.T) {
+	fileCap, err := provider.ToProviderCap(openapi3.NewReflector(), logr.Discard(), fileCondition{}, "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	providers := map[string]provider.InternalProviderClient{
+		"builtin": testProvider{caps: []provider.Capability{fileCap}},
+	}
+	b, err := json.Marshal(ruleparser.CreateJSONSchema(providers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := map[string]interface{}{}
+	if err := json.Unmarshal(b, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path []string
+		want interface{}
+	}{
+		{
+			name: "rule files are a list of rules",
+			path: []string{"oneOf", "0", "items", "$ref"},
+			want: "#/definitions/rule",
+		},
+		{
+			name: "rules must have an ID and conditions",
+			path: []string{"definitions", "rule", "required"},
+			want: []interface{}{"ruleID", "when"},
+		},
+		{
+			name: "unknown fields of rules are not allowed",
+			path: []string{"definitions", "rule", "additionalProperties"},
+			want: false,
+		},
+		{
+			name: "source of custom variables",
+			path: []string{"definitions", "customVariable", "properties", "source", "enum"},
+			want: []interface{}{"line", "lines", "file", "variable"},
+		},
+		{
+			name: "nested conditions",
+			path: []string{"definitions", "condition", "properties", "and", "items", "$ref"},
+			want: "#/definitions/condition",
+		},
+		{
+			name: "capability of a provider",
+			path: []string{"definitions", "condition", "properties", "builtin.file", "$ref"},
+			want: "#/definitions/builtin.file",
+		},
+		{
+			name: "input of a capability",
+			path: []string{"definitions", "builtin.file", "properties", "pattern", "type"},
+			want: "string",
+		},
+		{
+			name: "conditions have exactly one condition",
+			path: []string{"definitions", "condition", "oneOf", "3", "required"},
+			want: []interface{}{"builtin.file"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got interface{} = schema
+			for _, key := range tt.path {
+				switch v := got.(type) {
+				case map[string]interface{}:
+					got = v[key]
+				case []interface{}:
+					var i int
+					if err := json.Unmarshal([]byte(key), &i); err != nil || i >= len(v) {
+						t.Fatalf("no item %s in %v", key, v)
+					}
+					got = v[i]
+				default:
+					t.Fatalf("no key %s in %v", key, v)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schema %v = %#v, want %#v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// javaReferencedCondition is the input of the referenced capability of the
+// java provider.
+type javaReferencedCondition struct {
+	Pattern   string `json:"pattern"`
+	Location  string `json:"location,omitempty"`
+	Annotated struct {
+		Pattern  string `json:"pattern"`
+		Elements []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"elements,omitempty"`
+	} `json:"annotated,omitempty"`
+	Filepaths []string `json:"filepaths,omitempty"`
+}
+
+type referencedCondition struct {
+	Pattern string `json:"pattern"`
+}
+
+type k8sResourceCondition struct {
+	ApiVersion     string `json:"apiVersion"`
+	Kind           string `json:"kind"`
+	DeprecatedIn   string `json:"deprecatedIn"`
+	RemovedIn      string `json:"removedIn"`
+	ReplacementAPI string `json:"replacementAPI"`
+}
+
+func TestCreateJSONSchemaRuleExample(t *testing.T) {
+	builtin, err := lib.GetProviderClient(provider.Config{Name: "builtin"}, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	capabilities := func(inputs map[string]interface{}) []provider.Capability {
+		caps := []provider.Capability{}
+		for name, input := range inputs {
+			c, err := provider.ToProviderCap(openapi3.NewReflector(), logr.Discard(), input, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			caps = append(caps, c)
+		}
+		return caps
+	}
+	providers := map[string]provider.InternalProviderClient{
+		"builtin": builtin,
+		"java": testProvider{caps: capabilities(map[string]interface{}{
+			"referenced": javaReferencedCondition{},
+			"dependency": provider.DependencyConditionCap{},
+		})},
+		"go": testProvider{caps: capabilities(map[string]interface{}{
+			"referenced": referencedCondition{},
+			"dependency": provider.DependencyConditionCap{},
+		})},
+		"python": testProvider{caps: capabilities(map[string]interface{}{
+			"referenced": referencedCondition{},
+		})},
+		"yaml": testProvider{caps: capabilities(map[string]interface{}{
+			"k8sResourceMatched": k8sResourceCondition{},
+		})},
+	}
+	b, err := json.Marshal(ruleparser.CreateJSONSchema(providers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := map[string]interface{}{}
+	if err := json.Unmarshal(b, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile("../rule-example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rules interface{}
+	if err := yaml.Unmarshal(content, &rules); err != nil {
+		t.Fatal(err)
+	}
+	// the values of YAML as they are in JSON
+	b, err = json.Marshal(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &rules); err != nil {
+		t.Fatal(err)
+	}
+	// error-rule-001 has an invalid xpath on purpose to show errors in the demo
+	valid := []interface{}{}
+	for _, rule := range rules.([]interface{}) {
+		if rule.(map[string]interface{})["ruleID"] != "error-rule-001" {
+			valid = append(valid, rule)
+		}
+	}
+	rules = valid
+	if problems := validateJSONSchema(t, schema, schema, rules, "$"); len(problems) > 0 {
+		t.Errorf("rule-example.yaml does not match the schema:\n%s", strings.Join(problems, "\n"))
+	}
+
+	// a typed input must still have its type when it is not a template
+	invalid := []interface{}{map[string]interface{}{
+		"ruleID": "invalid",
+		"when":   map[string]interface{}{"builtin.xml": map[string]interface{}{"xpath": "//a", "filepaths": "pom.xml"}},
+	}}
+	if problems := validateJSONSchema(t, schema, schema, invalid, "$"); len(problems) == 0 {
+		t.Errorf("expected filepaths that is neither a list nor a template to not match the schema")
+	}
+}
+
+// validateJSONSchema returns the problems of the value against the schema, for
+// the keywords that the schema of rules uses.
+func validateJSONSchema(t *testing.T, root map[string]interface{}, schema map[string]interface{}, value interface{}, path string) []string {
+	problems := []string{}
+	keywords := []string{}
+	for keyword := range schema {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	for _, keyword := range keywords {
+		argument := schema[keyword]
+		switch keyword {
+		case "$schema", "title", "description", "definitions":
+		case "$ref":
+			name := strings.TrimPrefix(argument.(string), "#/definitions/")
+			definition, ok := root["definitions"].(map[string]interface{})[name].(map[string]interface{})
+			if !ok {
+				t.Fatalf("%s: unknown reference %s", path, argument)
+			}
+			problems = append(problems, validateJSONSchema(t, root, definition, value, path)...)
+		case "type":
+			types := []interface{}{argument}
+			if list, ok := argument.([]interface{}); ok {
+				types = list
+			}
+			matched := false
+			for _, typ := range types {
+				matched = matched || jsonSchemaType(value, typ.(string))
+			}
+			if !matched {
+				problems = append(problems, fmt.Sprintf("%s: is not of type %v", path, argument))
+			}
+		case "properties":
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for name, property := range argument.(map[string]interface{}) {
+				if v, ok := object[name]; ok {
+					problems = append(problems, validateJSONSchema(t, root, property.(map[string]interface{}), v, path+"."+name)...)
+				}
+			}
+		case "additionalProperties":
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			properties, _ := schema["properties"].(map[string]interface{})
+			for name, v := range object {
+				if _, ok := properties[name]; ok {
+					continue
+				}
+				switch additional := argument.(type) {
+				case bool:
+					if !additional {
+						problems = append(problems, fmt.Sprintf("%s: unknown property %s", path, name))
+					}
+				case map[string]interface{}:
+					problems = append(problems, validateJSONSchema(t, root, additional, v, path+"."+name)...)
+				}
+			}
+		case "required":
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, name := range argument.([]interface{}) {
+				if _, ok := object[name.(string)]; !ok {
+					problems = append(problems, fmt.Sprintf("%s: missing property %s", path, name))
+				}
+			}
+		case "items":
+			list, ok := value.([]interface{})
+			if !ok {
+				continue
+			}
+			for i, item := range list {
+				problems = append(problems, validateJSONSchema(t, root, argument.(map[string]interface{}), item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		case "enum":
+			found := false
+			for _, v := range argument.([]interface{}) {
+				found = found || reflect.DeepEqual(v, value)
+			}
+			if !found {
+				problems = append(problems, fmt.Sprintf("%s: %v is not one of %v", path, value, argument))
+			}
+		case "minimum":
+			if number, ok := value.(float64); ok && number < argument.(float64) {
+				problems = append(problems, fmt.Sprintf("%s: %v is less than %v", path, value, argument))
+			}
+		case "pattern":
+			if s, ok := value.(string); ok && !regexp.MustCompile(argument.(string)).MatchString(s) {
+				problems = append(problems, fmt.Sprintf("%s: %q does not match %s", path, s, argument))
+			}
+		case "oneOf", "anyOf":
+			matched := 0
+			optionProblems := []string{}
+			for i, option := range argument.([]interface{}) {
+				p := validateJSONSchema(t, root, option.(map[string]interface{}), value, path)
+				if len(p) == 0 {
+					matched++
+				}
+				for _, problem := range p {
+					optionProblems = append(optionProblems, fmt.Sprintf("  %s %d: %s", keyword, i, problem))
+				}
+			}
+			if matched == 0 || (keyword == "oneOf" && matched > 1) {
+				problems = append(problems, fmt.Sprintf("%s: %d of the schemas of %s match", path, matched, keyword))
+				if matched == 0 {
+					problems = append(problems, optionProblems...)
+				}
+			}
+		default:
+			t.Fatalf("%s: keyword %s is not validated", path, keyword)
+		}
+	}
+	return problems
+}
+
+func jsonSchemaType(value interface{}, typ string) bool {
+	switch v := value.(type) {
+	case string:
+		return typ == "string"
+	case bool:
+		return typ == "boolean"
+	case float64:
+		return typ == "number" || (typ == "integer" && v == math.Trunc(v))
+	case []interface{}:
+		return typ == "array"
+	case map[string]interface{}:
+		return typ == "object"
+	case nil:
+		return typ == "null"
+	}
+	return false
+}
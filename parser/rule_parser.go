@@ -1,11 +1,15 @@
 package parser
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	path "path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/konveyor/analyzer-lsp/engine"
@@ -43,43 +47,101 @@ type RuleParser struct {
 	Log                  logr.Logger
 	NoDependencyRules    bool
 	DepLabelSelector     *labels.LabelSelector[*provider.Dep]
+	// ProviderTimeouts are the default timeouts of rules that use each provider.
+	ProviderTimeouts map[string]time.Duration
+	// ProviderCostHints are how expensive the conditions of each provider are.
+	ProviderCostHints map[string]int
+	// Strict rejects the rules when any of them has a problem that is
+	// otherwise skipped or ignored, such as an unknown field.
+	Strict bool
+	// SkippedProviders are providers without a client, their conditions are
+	// left out of the rules and ValidateRules does not check them.
+	SkippedProviders []string
 }
 
 func (r *RuleParser) loadRuleSet(dir string) *engine.RuleSet {
-	goldenFile := path.Join(dir, RULE_SET_GOLDEN_FILE_NAME)
-	info, err := os.Stat(goldenFile)
+	set, err := readRuleSetFile(dir)
 	if err != nil {
 		r.Log.V(8).Error(err, "unable to load rule set")
 		return nil
 	}
-	if !info.Mode().IsRegular() {
+	if set == nil {
 		return nil
 	}
-	content, err := os.ReadFile(goldenFile)
-	if err != nil {
-		r.Log.V(8).Error(err, "unable to load rule set")
-		return nil
-	}
-
-	set := engine.RuleSet{}
+	return &set.RuleSet
+}
 
-	// Assume that there is a rule set header.
-	err = yaml.Unmarshal(content, &set)
+// This will load the rules from the filestytem, using the provided provider clients
+func (r *RuleParser) LoadRules(filepath string) ([]engine.RuleSet, map[string]provider.InternalProviderClient, error) {
+	if r.Strict {
+		diagnostics, err := r.ValidateRules(filepath)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(diagnostics) > 0 {
+			return nil, nil, &DiagnosticsError{Diagnostics: diagnostics}
+		}
+	}
+	if isBundle(filepath) {
+		dir, provenance, cleanup, err := openBundle(filepath)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer cleanup()
+		ruleSets, clients, err := r.loadRules(dir)
+		for i := range ruleSets {
+			ruleSets[i].Provenance = provenance
+		}
+		if err == nil {
+			err = validateRuleDependencies(ruleSets)
+		}
+		return ruleSets, clients, err
+	}
+	ruleSets, clients, err := r.loadRules(filepath)
+	if err == nil {
+		err = validateRuleDependencies(ruleSets)
+	}
+	return ruleSets, clients, err
+}
 
-	if err != nil {
-		r.Log.V(8).Error(err, "unable to load rule set")
-		return nil
+// validateRuleDependencies validates the dependencies of the rules that were
+// loaded together. A dependency on a rule of a ruleset that was not loaded with
+// them, such as a ruleset of another path, is left to the engine.
+func validateRuleDependencies(ruleSets []engine.RuleSet) error {
+	loaded := map[string]bool{}
+	for _, ruleSet := range ruleSets {
+		loaded[ruleSet.Name] = true
 	}
-	if len(set.Rules) != 0 {
-		r.Log.V(8).Error(fmt.Errorf("rules should not be added in the ruleset"), "unable to load rule set")
-		return nil
+	local := make([]engine.RuleSet, len(ruleSets))
+	for i, ruleSet := range ruleSets {
+		local[i] = ruleSet
+		local[i].Rules = make([]engine.Rule, len(ruleSet.Rules))
+		for j, rule := range ruleSet.Rules {
+			dependsOn := []string{}
+			for _, dependency := range rule.DependsOn {
+				if !strings.Contains(dependency, "/") || loadedRuleSetPrefix(dependency, loaded) {
+					dependsOn = append(dependsOn, dependency)
+				}
+			}
+			rule.DependsOn = dependsOn
+			local[i].Rules[j] = rule
+		}
 	}
+	return engine.ValidateRuleDependencies(local)
+}
 
-	return &set
+// loadedRuleSetPrefix reports whether the dependency is prefixed by the name of
+// a loaded ruleset.
+func loadedRuleSetPrefix(dependency string, loaded map[string]bool) bool {
+	for i := range dependency {
+		if dependency[i] == '/' && loaded[dependency[:i]] {
+			return true
+		}
+	}
+	return false
 }
 
-// This will load the rules from the filestytem, using the provided provider clients
-func (r *RuleParser) LoadRules(filepath string) ([]engine.RuleSet, map[string]provider.InternalProviderClient, error) {
+func (r *RuleParser) loadRules(filepath string) ([]engine.RuleSet, map[string]provider.InternalProviderClient, error) {
 	// Load Rules from file containing rules.
 	info, err := os.Stat(filepath)
 	if err != nil {
@@ -88,16 +150,37 @@ func (r *RuleParser) LoadRules(filepath string) ([]engine.RuleSet, map[string]pr
 
 	// If a single file, then it must have the ruleset metadata.
 	if info.Mode().IsRegular() {
-		rules, m, err := r.LoadRule(filepath)
+		set, err := readRuleSetFile(path.Dir(filepath))
+		if err != nil {
+			r.Log.V(8).Error(err, "unable to load rule set")
+			set = nil
+		}
+		parameters, err := parameterValues(set, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		rules, m, err := r.loadRuleFile(filepath, parameters)
 		if err != nil {
 			r.Log.V(8).Error(err, "unable to load rule set")
 			return nil, nil, err
 		}
+		// The imports of the ruleset are loaded along with the file as they
+		// are when its whole directory is loaded.
+		if set != nil && len(set.Imports) > 0 {
+			if m == nil {
+				m = map[string]provider.InternalProviderClient{}
+			}
+			var errs []error
+			rules, errs = r.loadImportedRules(path.Dir(filepath), set, parameters, rules, m)
+			if len(errs) > 0 {
+				return nil, nil, &parserErrors{errs: errs}
+			}
+		}
 
-		ruleSet := r.loadRuleSet(path.Dir(filepath))
+		ruleSet := defaultRuleSet
 		// if nil, use the default rule set
-		if ruleSet == nil {
-			ruleSet = defaultRuleSet
+		if set != nil {
+			ruleSet = &set.RuleSet
 		}
 		ruleSet.Rules = rules
 
@@ -111,9 +194,18 @@ func (r *RuleParser) LoadRules(filepath string) ([]engine.RuleSet, map[string]pr
 	if err != nil {
 		return nil, nil, err
 	}
-	var ruleSet *engine.RuleSet
-	rules := []engine.Rule{}
 	parserErr := &parserErrors{}
+	// The ruleset is read first as its parameters are used by its rules.
+	set, err := readRuleSetFile(filepath)
+	if err != nil {
+		r.Log.V(8).Error(err, "unable to load rule set")
+		set = nil
+	}
+	parameters, err := parameterValues(set, nil)
+	if err != nil {
+		parserErr.errs = append(parserErr.errs, err)
+	}
+	rules := []engine.Rule{}
 	for _, f := range files {
 		info, err := os.Stat(path.Join(filepath, f.Name()))
 		if err != nil {
@@ -121,7 +213,7 @@ func (r *RuleParser) LoadRules(filepath string) ([]engine.RuleSet, map[string]pr
 			continue
 		}
 		if info.IsDir() {
-			r, m, err := r.LoadRules(path.Join(filepath, f.Name()))
+			r, m, err := r.loadRules(path.Join(filepath, f.Name()))
 			if err != nil {
 				parserErr.errs = append(parserErr.errs, err)
 				continue
@@ -136,16 +228,14 @@ func (r *RuleParser) LoadRules(filepath string) ([]engine.RuleSet, map[string]pr
 		}
 		if info.Mode().IsRegular() {
 			if f.Name() == RULE_SET_GOLDEN_FILE_NAME {
-				ruleSet = r.loadRuleSet(filepath)
 				continue
 			}
 			// skip rule tests
-			if strings.HasSuffix(f.Name(), ".test.yaml") ||
-				strings.HasSuffix(f.Name(), ".test.yml") {
+			if !isRuleFile(f.Name()) {
 				r.Log.V(7).Info("excluding test file from parsing", "file", f.Name())
 				continue
 			}
-			r, m, err := r.LoadRule(path.Join(filepath, f.Name()))
+			r, m, err := r.loadRuleFile(path.Join(filepath, f.Name()), parameters)
 			if err != nil {
 				parserErr.errs = append(parserErr.errs, err)
 				continue
@@ -158,9 +248,15 @@ func (r *RuleParser) LoadRules(filepath string) ([]engine.RuleSet, map[string]pr
 		}
 	}
 
-	if ruleSet != nil {
-		ruleSet.Rules = rules
-		ruleSets = append(ruleSets, *ruleSet)
+	if set != nil && len(set.Imports) > 0 {
+		var errs []error
+		rules, errs = r.loadImportedRules(filepath, set, parameters, rules, clientMap)
+		parserErr.errs = append(parserErr.errs, errs...)
+	}
+
+	if set != nil {
+		set.Rules = rules
+		ruleSets = append(ruleSets, set.RuleSet)
 	}
 	// Return nil if there are no captured errors
 	if len(parserErr.errs) == 0 {
@@ -169,23 +265,62 @@ func (r *RuleParser) LoadRules(filepath string) ([]engine.RuleSet, map[string]pr
 	return ruleSets, clientMap, parserErr
 }
 
-func (r *RuleParser) LoadRule(filepath string) ([]engine.Rule, map[string]provider.InternalProviderClient, error) {
-	content, err := os.ReadFile(filepath)
+// loadImportedRules loads the rules imported by the ruleset of the directory
+// and appends them to its rules. The clients of the imported rules are added
+// to the client map.
+func (r *RuleParser) loadImportedRules(dir string, set *ruleSetFile, parameters map[string]string, rules []engine.Rule, clientMap map[string]provider.InternalProviderClient) ([]engine.Rule, []error) {
+	importDir, err := path.Abs(dir)
 	if err != nil {
-		r.Log.V(8).Error(err, "filepath", filepath)
-		return nil, nil, err
+		return rules, []error{err}
+	}
+	errs := []error{}
+	imported, err := r.importRules(dir, set, parameters, []string{importDir})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	ruleIDs := map[string]bool{}
+	for _, rule := range rules {
+		ruleIDs[rule.RuleID] = true
+	}
+	for _, file := range imported {
+		importedRules, m, err := r.parseRules(file.rules, file.path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, rule := range importedRules {
+			if ruleIDs[rule.RuleID] {
+				errs = append(errs, fmt.Errorf("duplicated rule id: %v imported from %s", rule.RuleID, file.path))
+				continue
+			}
+			ruleIDs[rule.RuleID] = true
+			rules = append(rules, rule)
+		}
+		for k, v := range m {
+			clientMap[k] = v
+		}
 	}
+	return rules, errs
+}
+
+func (r *RuleParser) LoadRule(filepath string) ([]engine.Rule, map[string]provider.InternalProviderClient, error) {
+	return r.loadRuleFile(filepath, nil)
+}
+
+// loadRuleFile loads the rules of a file with the parameters of its ruleset
+// substituted.
+func (r *RuleParser) loadRuleFile(filepath string, parameters map[string]string) ([]engine.Rule, map[string]provider.InternalProviderClient, error) {
 	// Determine if the content has a ruleset header.
 	// if not, only for a given folder does a ruleset header have to exist.
-	ruleMap := []map[string]interface{}{}
-
-	// Assume that there is a rule set header.
-	err = yaml.Unmarshal(content, &ruleMap)
-	if err != nil {
-		r.Log.V(8).Info("unable to load rule set, failed to convert file to yaml -- skipping", "file", filepath, "error", err)
-		return nil, nil, nil
+	ruleMap, err := r.readRuleFile(filepath, parameters)
+	if err != nil || ruleMap == nil {
+		return nil, nil, err
 	}
+	return r.parseRules(ruleMap, filepath)
+}
 
+// parseRules parses the rules of a file.
+func (r *RuleParser) parseRules(ruleMap []map[string]interface{}, filepath string) ([]engine.Rule, map[string]provider.InternalProviderClient, error) {
 	// rules that provide metadata
 	infoRules := []engine.Rule{}
 	// all rules
@@ -209,9 +344,19 @@ func (r *RuleParser) LoadRule(filepath string) ([]engine.Rule, map[string]provid
 			continue
 		}
 
+		// providers used by the conditions of this rule
+		ruleProviders := map[string]bool{}
+
+		// Hash the rule before any of the maps are modified below.
+		definitionHash, err := hashRuleDefinition(ruleMap)
+		if err != nil {
+			r.Log.V(8).Error(err, "unable to hash rule definition", "ruleID", ruleID, "file", filepath)
+			return nil, nil, err
+		}
+
 		// Rules contain When blocks and actions
 		// When is where we need to handle conditions
-		actions := []string{"message", "tag"}
+		actions := []string{"message", "tag", "fix"}
 
 		perform := engine.Perform{}
 		for _, action := range actions {
@@ -263,6 +408,13 @@ func (r *RuleParser) LoadRule(filepath string) ([]engine.Rule, map[string]provid
 						}
 						perform.Tag = append(perform.Tag, tag)
 					}
+				case "fix":
+					fix, err := r.getFix(val)
+					if err != nil {
+						r.Log.V(8).Info("invalid fix", "ruleID", ruleID, "error", err.Error())
+						return nil, nil, err
+					}
+					perform.Fix = fix
 				}
 			}
 		}
@@ -277,16 +429,57 @@ func (r *RuleParser) LoadRule(filepath string) ([]engine.Rule, map[string]provid
 			RuleMeta: engine.RuleMeta{
 				RuleID: ruleID,
 			},
+			DefinitionHash: definitionHash,
 		}
 
 		r.addRuleFields(&rule, ruleMap)
 
+		if timeoutRaw, ok := ruleMap["timeout"]; ok {
+			timeoutString, ok := timeoutRaw.(string)
+			if !ok {
+				r.Log.V(8).Info("timeout must be a duration", "ruleID", ruleID, "file", filepath)
+				return nil, nil, fmt.Errorf("timeout must be a duration such as 30s or 5m, not %v", timeoutRaw)
+			}
+			timeout, err := time.ParseDuration(timeoutString)
+			if err != nil || timeout <= 0 {
+				r.Log.V(8).Info("timeout must be a positive duration", "ruleID", ruleID, "file", filepath)
+				return nil, nil, fmt.Errorf("timeout must be a duration such as 30s or 5m, not %v", timeoutRaw)
+			}
+			rule.Timeout = timeout
+		}
+
+		if dependsOnRaw, ok := ruleMap["dependsOn"]; ok {
+			rule.DependsOn, err = r.ruleReferences("dependsOn", dependsOnRaw, ruleID)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, dependency := range rule.DependsOn {
+				if dependency == ruleID {
+					return nil, nil, fmt.Errorf("rule %s can not depend on itself", ruleID)
+				}
+			}
+		}
+
+		if supersedesRaw, ok := ruleMap["supersedes"]; ok {
+			rule.Supersedes, err = r.ruleReferences("supersedes", supersedesRaw, ruleID)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, superseded := range rule.Supersedes {
+				if superseded == ruleID {
+					return nil, nil, fmt.Errorf("rule %s can not supersede itself", ruleID)
+				}
+			}
+		}
+
 		whenMap, ok := ruleMap["when"].(map[interface{}]interface{})
 		if !ok {
 			r.Log.V(8).Info("a rule must have a single condition", "ruleID", ruleID, "file", filepath)
 			return nil, nil, fmt.Errorf("a Rule must have a single condition")
 		}
 
+		rule.UsesTags = usedTags(whenMap)
+
 		var from string
 		var as string
 		var ignorable bool
@@ -331,6 +524,17 @@ func (r *RuleParser) LoadRule(filepath string) ([]engine.Rule, map[string]provid
 			}
 		}
 
+		correlate, within, err := getCorrelation(whenMap)
+		if err != nil {
+			r.Log.V(8).Info("invalid correlation of and condition", "ruleID", ruleID, "file", filepath)
+			return nil, nil, err
+		}
+		evaluation, err := getEvaluationMode(whenMap)
+		if err != nil {
+			r.Log.V(8).Info("invalid evaluation of and/or condition", "ruleID", ruleID, "file", filepath)
+			return nil, nil, err
+		}
+
 		noConditions := false
 		for k, value := range whenMap {
 			key, ok := k.(string)
@@ -355,13 +559,19 @@ func (r *RuleParser) LoadRule(filepath string) ([]engine.Rule, map[string]provid
 					noConditions = true
 				}
 
-				rule.When = engine.OrCondition{Conditions: conditions}
+				or := engine.OrCondition{Conditions: conditions, Evaluation: evaluation}
+				if err := or.Validate(); err != nil {
+					r.Log.V(8).Error(err, "invalid or clause", "ruleID", ruleID, "file", filepath)
+					return nil, nil, err
+				}
+				rule.When = or
 				snippers := []engine.CodeSnip{}
 				for k, prov := range provs {
 					if snip, ok := prov.(engine.CodeSnip); ok {
 						snippers = append(snippers, snip)
 					}
 					providers[k] = prov
+					ruleProviders[k] = true
 				}
 				if len(snippers) > 0 {
 					rule.Snipper = provider.CodeSnipProvider{
@@ -383,13 +593,43 @@ func (r *RuleParser) LoadRule(filepath string) ([]engine.Rule, map[string]provid
 				if len(conditions) == 0 {
 					noConditions = true
 				}
-				rule.When = engine.AndCondition{Conditions: conditions}
+				and := engine.AndCondition{Conditions: conditions, Correlate: correlate, Within: within, Evaluation: evaluation}
+				if err := and.Validate(); err != nil {
+					r.Log.V(8).Error(err, "invalid and clause", "ruleID", ruleID, "file", filepath)
+					return nil, nil, err
+				}
+				rule.When = and
 				snippers := []engine.CodeSnip{}
 				for k, prov := range provs {
 					if snip, ok := prov.(engine.CodeSnip); ok {
 						snippers = append(snippers, snip)
 					}
 					providers[k] = prov
+					ruleProviders[k] = true
+				}
+				if len(snippers) > 0 {
+					rule.Snipper = provider.CodeSnipProvider{
+						Providers: snippers,
+					}
+				}
+			case "count":
+				count, provs, err := r.getCountCondition(value)
+				if err != nil {
+					r.Log.V(8).Error(err, "failed parsing count condition", "ruleID", ruleID, "file", filepath)
+					return nil, nil, err
+				}
+				if count == nil {
+					noConditions = true
+					continue
+				}
+				rule.When = *count
+				snippers := []engine.CodeSnip{}
+				for k, prov := range provs {
+					if snip, ok := prov.(engine.CodeSnip); ok {
+						snippers = append(snippers, snip)
+					}
+					providers[k] = prov
+					ruleProviders[k] = true
 				}
 				if len(snippers) > 0 {
 					rule.Snipper = provider.CodeSnipProvider{
@@ -430,12 +670,26 @@ func (r *RuleParser) LoadRule(filepath string) ([]engine.Rule, map[string]provid
 					rule.Snipper = snipper
 				}
 				providers[providerKey] = provider
+				ruleProviders[providerKey] = true
 			}
 		}
 		if noConditions || rule.When == nil {
 			r.Log.V(5).Info("skipping rule no conditions found", "rule", rule.RuleID)
 			continue
 		}
+		if err := validateTemplateReferences(rule); err != nil {
+			r.Log.V(8).Error(err, "invalid template in conditions", "ruleID", ruleID, "file", filepath)
+			return nil, nil, err
+		}
+
+		// Rules without a timeout use the longest default timeout of the providers they use.
+		if rule.Timeout == 0 {
+			for name := range ruleProviders {
+				if timeout := r.ProviderTimeouts[name]; timeout > rule.Timeout {
+					rule.Timeout = timeout
+				}
+			}
+		}
 
 		ruleIDMap[rule.RuleID] = nil
 		if rule.Perform.Tag != nil {
@@ -450,6 +704,42 @@ func (r *RuleParser) LoadRule(filepath string) ([]engine.Rule, map[string]provid
 	return append(infoRules, rules...), providers, nil
 }
 
+// usedTags finds the tags that the hasTags conditions of a rule look up.
+func usedTags(condition interface{}) []string {
+	tags := []string{}
+	switch c := condition.(type) {
+	case map[interface{}]interface{}:
+		for k, v := range c {
+			if key, ok := k.(string); ok && strings.HasSuffix(key, ".hasTags") {
+				values, _ := v.([]interface{})
+				for _, value := range values {
+					if tag, ok := value.(string); ok {
+						tags = append(tags, tag)
+					}
+				}
+				continue
+			}
+			tags = append(tags, usedTags(v)...)
+		}
+	case []interface{}:
+		for _, v := range c {
+			tags = append(tags, usedTags(v)...)
+		}
+	}
+	return tags
+}
+
+// hashRuleDefinition returns a stable hash of the rule as it was written.
+func hashRuleDefinition(ruleMap map[string]interface{}) (string, error) {
+	// yaml sorts the keys of maps, so the same rule always serializes the same way.
+	content, err := yaml.Marshal(ruleMap)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
 func validateRuleID(ruleID string) (string, bool) {
 	if strings.Contains(ruleID, "\n") {
 		return "rule id can not contain string", false
@@ -483,7 +773,7 @@ func (r *RuleParser) addRuleFields(rule *engine.Rule, ruleMap map[string]interfa
 	}
 	rule.Description = description
 
-	if rule.Perform.Message.Text != nil {
+	if rule.Perform.Message.Text != nil || rule.Perform.Fix != nil {
 		category, ok := ruleMap["category"].(string)
 		if !ok {
 			r.Log.V(8).WithValues("ruleID", rule.RuleID).Info("unable to find category")
@@ -568,6 +858,44 @@ func (r *RuleParser) addCustomVarFields(m map[interface{}]interface{}, customVar
 		customVar.Pattern = reg
 	}
 
+	if source, ok := m["source"]; ok {
+		sourceString, ok := source.(string)
+		if !ok {
+			return fmt.Errorf("unable to get source as string")
+		}
+		customVar.Source = engine.CustomVariableSource(sourceString)
+	}
+	for key, lines := range map[string]*int{"linesBefore": &customVar.LinesBefore, "linesAfter": &customVar.LinesAfter} {
+		if raw, ok := m[key]; ok {
+			value, ok := raw.(int)
+			if !ok || value < 0 {
+				return fmt.Errorf("%s must be a number of lines, not %v", key, raw)
+			}
+			*lines = value
+		}
+	}
+	if variable, ok := m["variable"]; ok {
+		variableString, ok := variable.(string)
+		if !ok {
+			return fmt.Errorf("unable to get variable as string")
+		}
+		customVar.Variable = variableString
+	}
+
+	switch customVar.Source {
+	case "", engine.LineVariableSource, engine.LinesVariableSource, engine.FileVariableSource:
+		if customVar.Pattern == nil {
+			return fmt.Errorf("custom variable %s must have a pattern", customVar.Name)
+		}
+	case engine.ProviderVariableSource:
+		if customVar.Variable == "" {
+			return fmt.Errorf("custom variable %s must have the name of a provider variable", customVar.Name)
+		}
+	default:
+		return fmt.Errorf("unknown source %s of custom variable %s, must be one of %s, %s, %s or %s", customVar.Source, customVar.Name,
+			engine.LineVariableSource, engine.LinesVariableSource, engine.FileVariableSource, engine.ProviderVariableSource)
+	}
+
 	return nil
 }
 
@@ -618,6 +946,14 @@ func (r *RuleParser) getConditions(conditionsInterface []interface{}) ([]engine.
 				return nil, nil, fmt.Errorf("not must be a boolean, not %v", notKeywordRaw)
 			}
 		}
+		correlate, within, err := getCorrelation(conditionMap)
+		if err != nil {
+			return nil, nil, err
+		}
+		evaluation, err := getEvaluationMode(conditionMap)
+		if err != nil {
+			return nil, nil, err
+		}
 		for k, v := range conditionMap {
 			key, ok := k.(string)
 			if !ok {
@@ -639,14 +975,21 @@ func (r *RuleParser) getConditions(conditionsInterface []interface{}) ([]engine.
 				if len(conds) == 0 && len(conds) != len(iConditions) {
 					return []engine.ConditionEntry{}, nil, nil
 				}
+				and := engine.AndCondition{
+					Conditions: conds,
+					Correlate:  correlate,
+					Within:     within,
+					Evaluation: evaluation,
+				}
+				if err := and.Validate(); err != nil {
+					return nil, nil, err
+				}
 				ce = engine.ConditionEntry{
-					From:      from,
-					As:        as,
-					Ignorable: ignorable,
-					Not:       not,
-					ProviderSpecificConfig: engine.AndCondition{
-						Conditions: conds,
-					},
+					From:                   from,
+					As:                     as,
+					Ignorable:              ignorable,
+					Not:                    not,
+					ProviderSpecificConfig: and,
 				}
 				for k, prov := range provs {
 					providers[k] = prov
@@ -665,14 +1008,38 @@ func (r *RuleParser) getConditions(conditionsInterface []interface{}) ([]engine.
 				if len(conds) == 0 && len(conds) != len(iConditions) {
 					return []engine.ConditionEntry{}, nil, nil
 				}
+				or := engine.OrCondition{
+					Conditions: conds,
+					Evaluation: evaluation,
+				}
+				if err := or.Validate(); err != nil {
+					return nil, nil, err
+				}
 				ce = engine.ConditionEntry{
-					From:      from,
-					As:        as,
-					Ignorable: ignorable,
-					Not:       not,
-					ProviderSpecificConfig: engine.OrCondition{
-						Conditions: conds,
-					},
+					From:                   from,
+					As:                     as,
+					Ignorable:              ignorable,
+					Not:                    not,
+					ProviderSpecificConfig: or,
+				}
+				for k, prov := range provs {
+					providers[k] = prov
+				}
+			case "count":
+				count, provs, err := r.getCountCondition(v)
+				if err != nil {
+					return nil, nil, err
+				}
+				// The provider of the counted condition is not used
+				if count == nil {
+					return []engine.ConditionEntry{}, nil, nil
+				}
+				ce = engine.ConditionEntry{
+					From:                   from,
+					As:                     as,
+					Ignorable:              ignorable,
+					Not:                    not,
+					ProviderSpecificConfig: *count,
 				}
 				for k, prov := range provs {
 					providers[k] = prov
@@ -736,9 +1103,221 @@ func (r *RuleParser) getConditions(conditionsInterface []interface{}) ([]engine.
 	return conditions, providers, nil
 }
 
+// validateTemplateReferences checks that the templates in the conditions of a
+// rule only use the outputs of conditions with as and of the rules it depends
+// on.
+func validateTemplateReferences(rule engine.Rule) error {
+	names, references := conditionTemplates(rule.When)
+	names = append(names, rule.DependsOn...)
+	names = append(names, engine.TemplateContextPathScopeKey)
+	for _, reference := range references {
+		if _, ok := provider.TemplateVariable(reference, names); !ok {
+			return fmt.Errorf("rule %s uses template variable %s that is not set by a condition with as or a rule in dependsOn", rule.RuleID, reference)
+		}
+	}
+	return nil
+}
+
+// conditionTemplates returns the names given to the outputs of the conditions
+// with as, and the template variables that the provider conditions use.
+func conditionTemplates(condition engine.Conditional) ([]string, []string) {
+	names, references := []string{}, []string{}
+	add := func(c engine.Conditional) {
+		n, r := conditionTemplates(c)
+		names = append(names, n...)
+		references = append(references, r...)
+	}
+	switch c := condition.(type) {
+	case engine.ConditionEntry:
+		if c.As != "" {
+			names = append(names, c.As)
+		}
+		add(c.ProviderSpecificConfig)
+	case engine.AndCondition:
+		for _, entry := range c.Conditions {
+			add(entry)
+		}
+	case engine.OrCondition:
+		for _, entry := range c.Conditions {
+			add(entry)
+		}
+	case engine.CountCondition:
+		add(c.Condition)
+	case provider.ProviderCondition:
+		references = append(references, provider.TemplateReferences(c.ConditionInfo)...)
+	}
+	return names, references
+}
+
+// getCorrelation removes the correlation of the incidents of an and condition
+// from the map of the condition and parses it.
+func getCorrelation(conditionMap map[interface{}]interface{}) (engine.AndCorrelation, int, error) {
+	var correlate engine.AndCorrelation
+	var within int
+	correlateRaw, hasCorrelate := conditionMap["correlate"]
+	if hasCorrelate {
+		delete(conditionMap, "correlate")
+		value, ok := correlateRaw.(string)
+		if !ok {
+			return "", 0, fmt.Errorf("correlate must be a string, not %v", correlateRaw)
+		}
+		correlate = engine.AndCorrelation(value)
+	}
+	withinRaw, hasWithin := conditionMap["within"]
+	if hasWithin {
+		delete(conditionMap, "within")
+		value, ok := withinRaw.(int)
+		if !ok {
+			return "", 0, fmt.Errorf("within must be an integer, not %v", withinRaw)
+		}
+		within = value
+	}
+	if _, ok := conditionMap["and"]; !ok && (hasCorrelate || hasWithin) {
+		return "", 0, fmt.Errorf("correlate and within can only be used with an and condition")
+	}
+	return correlate, within, nil
+}
+
+// getEvaluationMode removes how the conditions of an and or an or condition
+// are evaluated from the map of the condition and parses it.
+func getEvaluationMode(conditionMap map[interface{}]interface{}) (engine.EvaluationMode, error) {
+	evaluationRaw, ok := conditionMap["evaluation"]
+	if !ok {
+		return "", nil
+	}
+	delete(conditionMap, "evaluation")
+	evaluation, ok := evaluationRaw.(string)
+	if !ok {
+		return "", fmt.Errorf("evaluation must be a string, not %v", evaluationRaw)
+	}
+	_, hasAnd := conditionMap["and"]
+	_, hasOr := conditionMap["or"]
+	if !hasAnd && !hasOr {
+		return "", fmt.Errorf("evaluation can only be used with an and or an or condition")
+	}
+	return engine.EvaluationMode(evaluation), nil
+}
+
+// getCountCondition parses a count condition, the condition whose incidents are
+// counted along with the bounds of the count. The condition is nil when the
+// provider of the counted condition is not used.
+func (r *RuleParser) getCountCondition(raw interface{}) (*engine.CountCondition, map[string]provider.InternalProviderClient, error) {
+	countMap, ok := raw.(map[interface{}]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("count must be an object with a condition and a min or a max")
+	}
+	count := &engine.CountCondition{}
+	var inner []engine.ConditionEntry
+	var providers map[string]provider.InternalProviderClient
+	for key, value := range countMap {
+		switch key {
+		case "condition":
+			condition, ok := value.(map[interface{}]interface{})
+			if !ok {
+				return nil, nil, fmt.Errorf("count condition must be an object")
+			}
+			var err error
+			inner, providers, err = r.getConditions([]interface{}{condition})
+			if err != nil {
+				return nil, nil, err
+			}
+		case "of":
+			of, ok := value.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("count of must be a string, not %v", value)
+			}
+			count.Of = engine.CountOf(of)
+		case "min", "max":
+			bound, ok := value.(int)
+			if !ok {
+				return nil, nil, fmt.Errorf("count %v must be an integer, not %v", key, value)
+			}
+			if key == "min" {
+				count.Min = &bound
+			} else {
+				count.Max = &bound
+			}
+		default:
+			return nil, nil, fmt.Errorf("unknown count field %v", key)
+		}
+	}
+	if _, ok := countMap["condition"]; !ok {
+		return nil, nil, fmt.Errorf("count must have a condition")
+	}
+	if err := count.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if len(inner) == 0 {
+		return nil, nil, nil
+	}
+	count.Condition = inner[0]
+	return count, providers, nil
+}
+
+// getFix parses the fix action of a rule, the pattern of the matches to replace
+// along with their replacement or the text replacing the incident.
+func (r *RuleParser) getFix(raw interface{}) (*engine.Fix, error) {
+	fixMap, ok := raw.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("fix must be an object with either replace and with or text")
+	}
+	fix := &engine.Fix{}
+	for key, value := range fixMap {
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("fix %v must be a string, not %v", key, value)
+		}
+		switch key {
+		case "replace":
+			pattern, err := regexp.Compile(str)
+			if err != nil {
+				return nil, fmt.Errorf("fix replace must be a regular expression: %w", err)
+			}
+			fix.Replace = pattern
+		case "with":
+			fix.With = str
+		case "text":
+			fix.Text = &str
+		default:
+			return nil, fmt.Errorf("unknown fix field %v", key)
+		}
+	}
+	if fix.Replace == nil && fix.With != "" {
+		return nil, fmt.Errorf("fix with can only be used with replace")
+	}
+	if err := fix.Validate(); err != nil {
+		return nil, err
+	}
+	return fix, nil
+}
+
+// ruleReferences parses a list of other rules given by their rule ID, prefixed
+// by the name of their ruleset and a slash when they are in another ruleset.
+func (r *RuleParser) ruleReferences(field string, raw interface{}, ruleID string) ([]string, error) {
+	values, ok := raw.([]interface{})
+	if !ok {
+		r.Log.V(8).Info(fmt.Sprintf("%s must be a list of strings", field), "ruleID", ruleID)
+		return nil, fmt.Errorf("%s must be a list of strings", field)
+	}
+	references := []string{}
+	for _, value := range values {
+		reference, ok := value.(string)
+		if !ok || reference == "" {
+			r.Log.V(8).Info(fmt.Sprintf("%s value must be a rule ID", field), "ruleID", ruleID, "value", value)
+			return nil, fmt.Errorf("%s value must be a rule ID, not %v", field, value)
+		}
+		references = append(references, reference)
+	}
+	return references, nil
+}
+
 func (r *RuleParser) getConditionForProvider(langProvider, capability string, value interface{}) (engine.Conditional, provider.InternalProviderClient, error) {
 	// Here there can only be a single provider.
 	client, ok := r.ProviderNameToClient[langProvider]
+	if !ok && slices.Contains(r.SkippedProviders, langProvider) {
+		// The conditions of skipped providers are left out of the rules.
+		return nil, nil, nil
+	}
 	if !ok {
 		return nil, nil, fmt.Errorf("unable to find provider for: %v", langProvider)
 	}
@@ -758,7 +1337,9 @@ func (r *RuleParser) getConditionForProvider(langProvider, capability string, va
 
 	if capability == "dependency" && !r.NoDependencyRules {
 		depCondition := provider.DependencyCondition{
-			Client: client,
+			Client:       client,
+			ProviderName: langProvider,
+			CostHint:     r.ProviderCostHints[langProvider],
 		}
 
 		fullCondition, ok := value.(map[interface{}]interface{})
@@ -813,9 +1394,11 @@ func (r *RuleParser) getConditionForProvider(langProvider, capability string, va
 
 	return provider.ProviderCondition{
 		Client:           client,
+		ProviderName:     langProvider,
 		Capability:       capability,
 		ConditionInfo:    value,
 		Ignore:           ignorable,
 		DepLabelSelector: selector,
+		CostHint:         r.ProviderCostHints[langProvider],
 	}, client, nil
 }
@@ -2,8 +2,10 @@ package parser_test
 
 import (
 	"context"
+	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/bombsimon/logrusr/v3"
@@ -52,6 +54,7 @@ func TestLoadRules(t *testing.T) {
 	allGoFiles := "all go files"
 	allGoOrJsonFiles := "all go or json files"
 	allGoAndJsonFiles := "all go and json files"
+	helmImage := "image set by a helm template"
 	effort := 3
 	testCases := []struct {
 		Name               string
@@ -233,6 +236,136 @@ func TestLoadRules(t *testing.T) {
 			ShouldErr:    true,
 			ErrorMessage: "unable to find ruleID in rule",
 		},
+		{
+			Name:         "rule invalid dependsOn",
+			testFileName: "invalid-depends-on.yaml",
+			providerNameClient: map[string]provider.InternalProviderClient{
+				"builtin": testProvider{
+					caps: []provider.Capability{{
+						Name: "file",
+					}},
+				},
+			},
+			ShouldErr:    true,
+			ErrorMessage: "dependsOn must be a list of strings",
+		},
+		{
+			Name:         "rule invalid timeout",
+			testFileName: "invalid-timeout.yaml",
+			providerNameClient: map[string]provider.InternalProviderClient{
+				"builtin": testProvider{
+					caps: []provider.Capability{{
+						Name: "file",
+					}},
+				},
+			},
+			ShouldErr:    true,
+			ErrorMessage: "timeout must be a duration such as 30s or 5m, not 30",
+		},
+		{
+			Name:         "rule supersedes itself",
+			testFileName: "invalid-supersedes.yaml",
+			providerNameClient: map[string]provider.InternalProviderClient{
+				"builtin": testProvider{
+					caps: []provider.Capability{{
+						Name: "file",
+					}},
+				},
+			},
+			ShouldErr:    true,
+			ErrorMessage: "rule file-001 can not supersede itself",
+		},
+		{
+			Name:         "rule fix without replace",
+			testFileName: "invalid-fix.yaml",
+			providerNameClient: map[string]provider.InternalProviderClient{
+				"builtin": testProvider{
+					caps: []provider.Capability{{
+						Name: "file",
+					}},
+				},
+			},
+			ShouldErr:    true,
+			ErrorMessage: "fix with can only be used with replace",
+		},
+		{
+			Name:         "count with min greater than max",
+			testFileName: "invalid-count.yaml",
+			providerNameClient: map[string]provider.InternalProviderClient{
+				"builtin": testProvider{
+					caps: []provider.Capability{{
+						Name: "file",
+					}},
+				},
+			},
+			ShouldErr:    true,
+			ErrorMessage: "count min 10 is greater than max 5",
+		},
+		{
+			Name:         "correlate without an and condition",
+			testFileName: "invalid-correlate.yaml",
+			providerNameClient: map[string]provider.InternalProviderClient{
+				"builtin": testProvider{
+					caps: []provider.Capability{{
+						Name: "file",
+					}},
+				},
+			},
+			ShouldErr:    true,
+			ErrorMessage: "correlate and within can only be used with an and condition",
+		},
+		{
+			Name:         "template variable not set by a condition",
+			testFileName: "invalid-template.yaml",
+			providerNameClient: map[string]provider.InternalProviderClient{
+				"builtin": testProvider{
+					caps: []provider.Capability{{
+						Name: "file",
+					}, {
+						Name: "xml",
+					}},
+				},
+			},
+			ShouldErr:    true,
+			ErrorMessage: "rule chain-001 uses template variable pom.filepaths that is not set by a condition with as or a rule in dependsOn",
+		},
+		{
+			Name:         "escaped template",
+			testFileName: "rule-escaped-template.yaml",
+			providerNameClient: map[string]provider.InternalProviderClient{
+				"builtin": testProvider{
+					caps: []provider.Capability{{
+						Name: "filecontent",
+					}},
+				},
+			},
+			ExpectedRuleSet: map[string]engine.RuleSet{
+				"konveyor-analysis": {
+					Rules: []engine.Rule{
+						{
+							RuleMeta: engine.RuleMeta{
+								RuleID:   "helm-001",
+								Category: &konveyor.Potential,
+							},
+							Perform: engine.Perform{
+								Message: engine.Message{
+									Text:  &helmImage,
+									Links: []konveyor.Link{},
+								},
+							},
+							When: engine.ConditionEntry{},
+						},
+					},
+				},
+			},
+			ExpectedProvider: map[string]provider.InternalProviderClient{
+				"builtin": testProvider{
+					caps: []provider.Capability{{
+						Name: "filecontent",
+					}},
+				},
+			},
+		},
 		{
 			Name:         "test-and-rule",
 			testFileName: "rule-and.yaml",
@@ -536,7 +669,7 @@ func TestLoadRules(t *testing.T) {
 				},
 			},
 			ShouldErr:    true,
-			ErrorMessage: "either message or tag must be set",
+			ErrorMessage: "either message, tag or fix must be set",
 		},
 		{
 			Name:         "test valid tag action",
@@ -918,3 +1051,75 @@ func compareConditions(cs1 []engine.ConditionEntry, cs2 []engine.ConditionEntry,
 		}
 	}
 }
+
+func TestLoadRulesDependencies(t *testing.T) {
+	rule := func(id string, tag bool, dependsOn ...string) string {
+		r := "- ruleID: " + id + "\n"
+		if tag {
+			r += "  tag: [Java]\n"
+		} else {
+			r += "  message: found\n"
+		}
+		if len(dependsOn) > 0 {
+			r += "  dependsOn: [" + strings.Join(dependsOn, ", ") + "]\n"
+		}
+		return r + "  when:\n    builtin.file:\n      pattern: pom.xml\n"
+	}
+	tests := []struct {
+		name         string
+		rules        string
+		errorMessage string
+	}{
+		{
+			name:  "dependencies in the ruleset and in a ruleset of another path",
+			rules: rule("java-001", false) + rule("java-002", false, "java-001", "builtin/file-001"),
+		},
+		{
+			name:         "unknown rule of the ruleset",
+			rules:        rule("java-001", false, "java-002"),
+			errorMessage: "depends on unknown rule java-002",
+		},
+		{
+			name:         "unknown rule prefixed by the ruleset",
+			rules:        rule("java-001", false, "java/java-002"),
+			errorMessage: "depends on unknown rule java/java-002",
+		},
+		{
+			name:         "cycle",
+			rules:        rule("java-001", false, "java-002") + rule("java-002", false, "java-001"),
+			errorMessage: "rules depend on each other in a cycle",
+		},
+		{
+			name:         "tagging rule depending on another rule",
+			rules:        rule("java-001", false) + rule("tag-001", true, "java-001"),
+			errorMessage: "can only depend on other rules that create tags",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "ruleset.yaml"), []byte("name: java\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "rules.yaml"), []byte(tt.rules), 0644); err != nil {
+				t.Fatal(err)
+			}
+			ruleParser := ruleparser.RuleParser{
+				ProviderNameToClient: map[string]provider.InternalProviderClient{
+					"builtin": testProvider{caps: []provider.Capability{{Name: "file"}}},
+				},
+				Log: logr.Discard(),
+			}
+			_, _, err := ruleParser.LoadRules(dir)
+			if tt.errorMessage == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMessage) {
+				t.Errorf("expected error %q, got %v", tt.errorMessage, err)
+			}
+		})
+	}
+}
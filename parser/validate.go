@@ -0,0 +1,435 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path"
+	fp "path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/konveyor/analyzer-lsp/engine"
+	"github.com/konveyor/analyzer-lsp/engine/labels"
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+	"github.com/konveyor/analyzer-lsp/provider"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+var (
+	ruleFields = fieldSet("ruleID", "description", "labels", "category", "effort", "message", "links", "tag", "fix",
+		"customVariables", "timeout", "dependsOn", "supersedes", "when")
+	ruleSetFields        = fieldSet("name", "description", "labels", "tags", "imports", "parameters", "overrides")
+	linkFields           = fieldSet("url", "title")
+	customVariableFields = fieldSet("name", "pattern", "nameOfCaptureGroup", "defaultValue", "source", "linesBefore", "linesAfter", "variable")
+	fixFields            = fieldSet("replace", "with", "text")
+	conditionFields      = fieldSet("from", "as", "ignore", "not", "correlate", "within", "evaluation")
+	countFields          = fieldSet("condition", "of", "min", "max")
+	// regexFields are the fields of provider conditions that are Go regular
+	// expressions, by provider and capability.
+	regexFields = map[string][]string{
+		"builtin.filecontent": {"filePattern"},
+		"builtin.xmlPublicID": {"regex"},
+		"dependency":          {"nameregex"},
+	}
+
+	yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+)
+
+func fieldSet(fields ...string) map[string]bool {
+	set := map[string]bool{}
+	for _, f := range fields {
+		set[f] = true
+	}
+	return set
+}
+
+// Diagnostic is a problem found in a rule file, at a line and column of the
+// file.
+type Diagnostic struct {
+	File    string `yaml:"file" json:"file"`
+	Line    int    `yaml:"line" json:"line"`
+	Column  int    `yaml:"column" json:"column"`
+	RuleID  string `yaml:"ruleID,omitempty" json:"ruleID,omitempty"`
+	Message string `yaml:"message" json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.RuleID != "" {
+		return fmt.Sprintf("%s:%d:%d: rule %s: %s", d.File, d.Line, d.Column, d.RuleID, d.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+}
+
+// DiagnosticsError is returned by strict parsers when rules have problems.
+type DiagnosticsError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *DiagnosticsError) Error() string {
+	lines := make([]string, 0, len(e.Diagnostics))
+	for _, d := range e.Diagnostics {
+		lines = append(lines, d.String())
+	}
+	return fmt.Sprintf("found %d problems in rules:\n%s", len(e.Diagnostics), strings.Join(lines, "\n"))
+}
+
+// ValidateRules checks the rules in a file, or in the rulesets of a directory,
+// and returns every problem found, including the ones that the parser skips
+// or ignores.
+func (r *RuleParser) ValidateRules(filepath string) ([]Diagnostic, error) {
+	if isBundle(filepath) {
+		dir, _, cleanup, err := openBundle(filepath)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		diagnostics, err := r.ValidateRules(dir)
+		// the files are given in the bundle rather than where it was extracted
+		for i := range diagnostics {
+			if rel, relErr := fp.Rel(dir, diagnostics[i].File); relErr == nil {
+				diagnostics[i].File = path.Join(filepath, fp.ToSlash(rel))
+			}
+		}
+		return diagnostics, err
+	}
+	info, err := os.Stat(filepath)
+	if err != nil {
+		return nil, err
+	}
+	v := &ruleValidator{parser: r, diagnostics: []Diagnostic{}}
+	if info.Mode().IsRegular() {
+		v.validateFile(filepath, map[string]string{})
+	} else if err := v.validateDir(filepath); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(v.diagnostics, func(i, j int) bool {
+		if v.diagnostics[i].File != v.diagnostics[j].File {
+			return v.diagnostics[i].File < v.diagnostics[j].File
+		}
+		if v.diagnostics[i].Line != v.diagnostics[j].Line {
+			return v.diagnostics[i].Line < v.diagnostics[j].Line
+		}
+		return v.diagnostics[i].Column < v.diagnostics[j].Column
+	})
+	return v.diagnostics, nil
+}
+
+type ruleValidator struct {
+	parser      *RuleParser
+	diagnostics []Diagnostic
+}
+
+func (v *ruleValidator) add(file string, node *yamlv3.Node, ruleID string, format string, args ...interface{}) {
+	v.diagnostics = append(v.diagnostics, Diagnostic{
+		File:    file,
+		Line:    node.Line,
+		Column:  node.Column,
+		RuleID:  ruleID,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// validateDir validates a ruleset, the rule files of a directory, along with
+// the rulesets of its subdirectories.
+func (v *ruleValidator) validateDir(dir string) error {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	// rule IDs of the ruleset and where they are defined
+	ruleIDs := map[string]string{}
+	for _, f := range files {
+		file := path.Join(dir, f.Name())
+		switch {
+		case f.IsDir():
+			if err := v.validateDir(file); err != nil {
+				return err
+			}
+		case f.Name() == RULE_SET_GOLDEN_FILE_NAME:
+			v.validateRuleSetFile(file)
+		case strings.HasSuffix(f.Name(), ".test.yaml") || strings.HasSuffix(f.Name(), ".test.yml"):
+		case strings.HasSuffix(f.Name(), ".yaml") || strings.HasSuffix(f.Name(), ".yml"):
+			v.validateFile(file, ruleIDs)
+		}
+	}
+	return nil
+}
+
+// readYAML parses a file into a YAML node, problems with the file are added
+// as diagnostics.
+func (v *ruleValidator) readYAML(file string) *yamlv3.Node {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		v.add(file, &yamlv3.Node{Line: 1, Column: 1}, "", "unable to read file: %v", err)
+		return nil
+	}
+	doc := yamlv3.Node{}
+	if err := yamlv3.Unmarshal(content, &doc); err != nil {
+		line := 1
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ = strconv.Atoi(m[1])
+		}
+		v.add(file, &yamlv3.Node{Line: line, Column: 1}, "", "invalid YAML: %v", err)
+		return nil
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	return doc.Content[0]
+}
+
+func (v *ruleValidator) validateRuleSetFile(file string) {
+	node := v.readYAML(file)
+	if node == nil {
+		return
+	}
+	if node.Kind != yamlv3.MappingNode {
+		v.add(file, node, "", "ruleset must be an object")
+		return
+	}
+	v.checkFields(file, node, "", "ruleset", ruleSetFields)
+	if name := mappingValue(node, "name"); name == nil || name.Value == "" {
+		v.add(file, node, "", "ruleset must have a name")
+	}
+}
+
+func (v *ruleValidator) validateFile(file string, ruleIDs map[string]string) {
+	node := v.readYAML(file)
+	if node == nil {
+		return
+	}
+	if node.Kind != yamlv3.SequenceNode {
+		v.add(file, node, "", "rules file must be a list of rules")
+		return
+	}
+	for _, rule := range node.Content {
+		v.validateRule(file, rule, ruleIDs)
+	}
+}
+
+func (v *ruleValidator) validateRule(file string, node *yamlv3.Node, ruleIDs map[string]string) {
+	if node.Kind != yamlv3.MappingNode {
+		v.add(file, node, "", "rule must be an object")
+		return
+	}
+	found := len(v.diagnostics)
+	ruleID := ""
+	if idNode := mappingValue(node, "ruleID"); idNode == nil {
+		v.add(file, node, "", "rule must have a ruleID")
+	} else if !isString(idNode) {
+		v.add(file, idNode, "", "ruleID must be a string")
+	} else {
+		ruleID = idNode.Value
+		location := fmt.Sprintf("%s:%d:%d", file, idNode.Line, idNode.Column)
+		if reason, ok := validateRuleID(ruleID); !ok {
+			v.add(file, idNode, ruleID, "%s", reason)
+		} else if first, ok := ruleIDs[ruleID]; ok {
+			v.add(file, idNode, ruleID, "duplicate rule ID, first defined at %s", first)
+		} else {
+			ruleIDs[ruleID] = location
+		}
+	}
+	v.checkFields(file, node, ruleID, "rule", ruleFields)
+
+	if category := mappingValue(node, "category"); category != nil {
+		c := konveyor.Category(strings.ToLower(category.Value))
+		if !isString(category) || (c != konveyor.Potential && c != konveyor.Mandatory && c != konveyor.Optional) {
+			v.add(file, category, ruleID, "category must be one of %s, %s or %s, not %s", konveyor.Mandatory, konveyor.Optional, konveyor.Potential, category.Value)
+		}
+	}
+	if effort := mappingValue(node, "effort"); effort != nil && (effort.Kind != yamlv3.ScalarNode || effort.Tag != "!!int") {
+		v.add(file, effort, ruleID, "effort must be an integer, not %s", effort.Value)
+	}
+	if description := mappingValue(node, "description"); description != nil && !isString(description) {
+		v.add(file, description, ruleID, "description must be a string")
+	}
+	if labelList := mappingValue(node, "labels"); labelList != nil {
+		if labelList.Kind != yamlv3.SequenceNode {
+			v.add(file, labelList, ruleID, "labels must be a list of strings")
+		} else {
+			for _, label := range labelList.Content {
+				if !isString(label) {
+					v.add(file, label, ruleID, "label must be a string")
+				} else if _, _, err := labels.ParseLabel(label.Value); err != nil {
+					v.add(file, label, ruleID, "invalid label %s: %v", label.Value, err)
+				}
+			}
+		}
+	}
+	if links := mappingValue(node, "links"); links != nil {
+		v.validateList(file, links, ruleID, "links", func(link *yamlv3.Node) {
+			v.checkFields(file, link, ruleID, "link", linkFields)
+			if url := mappingValue(link, "url"); url == nil || url.Value == "" {
+				v.add(file, link, ruleID, "link must have a url")
+			}
+		})
+	}
+	if variables := mappingValue(node, "customVariables"); variables != nil {
+		v.validateList(file, variables, ruleID, "customVariables", func(variable *yamlv3.Node) {
+			v.checkFields(file, variable, ruleID, "custom variable", customVariableFields)
+			if !v.checkRegex(file, mappingValue(variable, "pattern"), ruleID, "custom variable pattern") {
+				return
+			}
+			m := map[interface{}]interface{}{}
+			if err := decodeNode(variable, &m); err != nil {
+				v.add(file, variable, ruleID, "%v", err)
+				return
+			}
+			if err := v.parser.addCustomVarFields(m, &engine.CustomVariable{}); err != nil {
+				v.add(file, variable, ruleID, "invalid custom variable: %v", err)
+			}
+		})
+	}
+	if fix := mappingValue(node, "fix"); fix != nil && fix.Kind == yamlv3.MappingNode {
+		v.checkFields(file, fix, ruleID, "fix", fixFields)
+		v.checkRegex(file, mappingValue(fix, "replace"), ruleID, "fix replace")
+	}
+	if when := mappingValue(node, "when"); when != nil {
+		v.validateCondition(file, when, ruleID)
+	}
+
+	// The parser finds the remaining problems, which are reported at the rule
+	// when the problems above were not found.
+	if len(v.diagnostics) > found {
+		return
+	}
+	ruleMap := map[string]interface{}{}
+	if err := decodeNode(node, &ruleMap); err != nil {
+		v.add(file, node, ruleID, "%v", err)
+		return
+	}
+	if _, _, err := v.parser.parseRules([]map[string]interface{}{ruleMap}, file); err != nil {
+		v.add(file, node, ruleID, "%v", err)
+	}
+}
+
+func (v *ruleValidator) validateCondition(file string, node *yamlv3.Node, ruleID string) {
+	if node.Kind != yamlv3.MappingNode {
+		v.add(file, node, ruleID, "condition must be an object")
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch {
+		case conditionFields[key.Value]:
+		case key.Value == "and" || key.Value == "or":
+			v.validateList(file, value, ruleID, key.Value, func(condition *yamlv3.Node) {
+				v.validateCondition(file, condition, ruleID)
+			})
+		case key.Value == "count":
+			if value.Kind != yamlv3.MappingNode {
+				v.add(file, value, ruleID, "count must be an object")
+				continue
+			}
+			v.checkFields(file, value, ruleID, "count", countFields)
+			if condition := mappingValue(value, "condition"); condition != nil {
+				v.validateCondition(file, condition, ruleID)
+			}
+		default:
+			v.validateProviderCondition(file, key, value, ruleID)
+		}
+	}
+}
+
+func (v *ruleValidator) validateProviderCondition(file string, key *yamlv3.Node, value *yamlv3.Node, ruleID string) {
+	providerName, capability, ok := strings.Cut(key.Value, ".")
+	if !ok || strings.Contains(capability, ".") {
+		v.add(file, key, ruleID, "unknown condition %s, must be and, or, count or {provider}.{capability}", key.Value)
+		return
+	}
+	client, ok := v.parser.ProviderNameToClient[providerName]
+	if !ok && slices.Contains(v.parser.SkippedProviders, providerName) {
+		return
+	}
+	if !ok {
+		v.add(file, key, ruleID, "unknown provider %s", providerName)
+		return
+	}
+	if !provider.HasCapability(client.Capabilities(), capability) {
+		v.add(file, key, ruleID, "provider %s has no capability %s", providerName, capability)
+		return
+	}
+	fields := regexFields[key.Value]
+	if capability == "dependency" {
+		fields = regexFields[capability]
+	}
+	for _, field := range fields {
+		v.checkRegex(file, mappingValue(value, field), ruleID, field)
+	}
+}
+
+// validateList validates the items of a list with the function.
+func (v *ruleValidator) validateList(file string, node *yamlv3.Node, ruleID string, name string, validate func(*yamlv3.Node)) {
+	if node.Kind != yamlv3.SequenceNode {
+		v.add(file, node, ruleID, "%s must be a list", name)
+		return
+	}
+	for _, item := range node.Content {
+		if item.Kind != yamlv3.MappingNode {
+			v.add(file, item, ruleID, "items of %s must be objects", name)
+			continue
+		}
+		validate(item)
+	}
+}
+
+// checkFields adds a diagnostic for every field of the object that is not known.
+func (v *ruleValidator) checkFields(file string, node *yamlv3.Node, ruleID string, name string, known map[string]bool) {
+	if node.Kind != yamlv3.MappingNode {
+		v.add(file, node, ruleID, "%s must be an object", name)
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if key := node.Content[i]; !known[key.Value] {
+			v.add(file, key, ruleID, "unknown field %s of %s", key.Value, name)
+		}
+	}
+}
+
+// checkRegex adds a diagnostic when the node is not a regular expression, it
+// returns whether the node is one.
+func (v *ruleValidator) checkRegex(file string, node *yamlv3.Node, ruleID string, name string) bool {
+	if node == nil {
+		return true
+	}
+	if !isString(node) {
+		v.add(file, node, ruleID, "%s must be a regular expression", name)
+		return false
+	}
+	if _, err := regexp.Compile(node.Value); err != nil {
+		v.add(file, node, ruleID, "%s is not a valid regular expression: %v", name, err)
+		return false
+	}
+	return true
+}
+
+// mappingValue returns the value of a key of an object, nil when the node is
+// not an object or has no such key.
+func mappingValue(node *yamlv3.Node, key string) *yamlv3.Node {
+	if node == nil || node.Kind != yamlv3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func isString(node *yamlv3.Node) bool {
+	return node.Kind == yamlv3.ScalarNode && node.Tag == "!!str"
+}
+
+// decodeNode decodes a node the way the parser reads rules.
+func decodeNode(node *yamlv3.Node, out interface{}) error {
+	content, err := yamlv3.Marshal(node)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(content, out)
+}
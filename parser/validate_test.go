@@ -0,0 +1,65 @@
+package parser_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	ruleparser "github.com/konveyor/analyzer-lsp/parser"
+	"github.com/konveyor/analyzer-lsp/provider"
+)
+
+func TestValidateRules(t *testing.T) {
+	ruleParser := ruleparser.RuleParser{
+		ProviderNameToClient: map[string]provider.InternalProviderClient{
+			"builtin": testProvider{
+				caps: []provider.Capability{{Name: "file"}, {Name: "filecontent"}},
+			},
+		},
+		Log: logr.Discard(),
+	}
+	dir := filepath.Join("testdata", "validate")
+	diagnostics, err := ruleParser.ValidateRules(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, d := range diagnostics {
+		got = append(got, d.String())
+	}
+	rules := filepath.Join(dir, "rules.yaml")
+	moreRules := filepath.Join(dir, "more-rules.yaml")
+	want := []string{
+		moreRules + ":6:3: rule actions-001: either message, tag or fix must be set",
+		rules + ":1:11: rule valid-001: duplicate rule ID, first defined at " + moreRules + ":1:11",
+		rules + ":10:3: rule fields-001: unknown field categroy of rule",
+		rules + ":11:11: rule fields-001: effort must be an integer, not high",
+		rules + ":14:7: rule fields-001: invalid label =java: invalid label key ''",
+		rules + ":20:13: rule conditions-001: category must be one of mandatory, optional or potential, not required",
+		rules + ":23:16: rule conditions-001: custom variable pattern is not a valid regular expression: error parsing regexp: missing closing ]: `[a-z.`",
+		rules + ":26:9: rule conditions-001: unknown provider java",
+		rules + ":28:9: rule conditions-001: provider builtin has no capability references",
+		rules + ":32:24: rule conditions-001: filePattern is not a valid regular expression: error parsing regexp: missing argument to repetition operator: `*`",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateRules() =\n%v\nwant\n%v", got, want)
+	}
+
+	ruleParser.SkippedProviders = []string{"java"}
+	diagnostics, err = ruleParser.ValidateRules(filepath.Join("testdata", "validate-skipped"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diagnostics) != 0 {
+		t.Errorf("expected the conditions of a skipped provider not to be checked, got %v", diagnostics)
+	}
+	ruleParser.SkippedProviders = nil
+
+	ruleParser.Strict = true
+	if _, _, err := ruleParser.LoadRules(dir); err == nil {
+		t.Errorf("expected a strict parser to reject the rules")
+	} else if _, ok := err.(*ruleparser.DiagnosticsError); !ok {
+		t.Errorf("expected the problems of the rules, got %v", err)
+	}
+}
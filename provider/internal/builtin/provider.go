@@ -3,6 +3,9 @@ package builtin
 import (
 	"context"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/konveyor/analyzer-lsp/provider"
@@ -34,6 +37,7 @@ type fileCondition struct {
 }
 
 var _ provider.InternalProviderClient = &builtinProvider{}
+var _ provider.ConditionFileScoper = &builtinProvider{}
 
 type xmlCondition struct {
 	XPath      string            `yaml:"xpath" json:"xpath" title:"XPath" description:"Xpath query"`
@@ -189,3 +193,103 @@ func (p *builtinProvider) Evaluate(ctx context.Context, cap string, conditionInf
 
 func (p *builtinProvider) Stop() {
 }
+
+// FileScope returns the files that a condition reads, the files are matched
+// like the conditions find them or more broadly when it is unclear.
+func (p *builtinProvider) FileScope(capability string, conditionInfo interface{}) (func(path string) bool, bool) {
+	content, err := yaml.Marshal(map[string]interface{}{capability: conditionInfo})
+	if err != nil {
+		return nil, false
+	}
+	var cond builtinCondition
+	if err := yaml.Unmarshal(content, &cond); err != nil {
+		return nil, false
+	}
+	switch capability {
+	case "file":
+		if cond.File.Pattern == "" {
+			return nil, false
+		}
+		regex, _ := regexp.Compile(cond.File.Pattern)
+		return func(path string) bool {
+			// the pattern is matched against the name of the files or, when
+			// the files are scoped, against their path
+			if regex != nil {
+				return regex.MatchString(path) || regex.MatchString(filepath.Base(path))
+			}
+			return matchesFileGlob(cond.File.Pattern, path)
+		}, true
+	case "filecontent":
+		if cond.Filecontent.FilePattern == "" {
+			return nil, false
+		}
+		regex, err := regexp.Compile(cond.Filecontent.FilePattern)
+		if err != nil {
+			return nil, false
+		}
+		locations := []string{}
+		for _, config := range p.config.InitConfig {
+			if location, err := filepath.Abs(config.Location); err == nil && config.Location != "" {
+				locations = append(locations, location)
+			}
+		}
+		return func(path string) bool {
+			// the pattern is matched against the paths grep prints, which are
+			// relative to the location when it is
+			if regex.MatchString(path) || regex.MatchString(filepath.Base(path)) {
+				return true
+			}
+			for _, location := range locations {
+				if rel, err := filepath.Rel(location, path); err == nil && regex.MatchString(rel) {
+					return true
+				}
+			}
+			return false
+		}, true
+	case "xml":
+		return filesScope(cond.XML.Filepaths, "*.xml", "*.xhtml"), true
+	case "xmlPublicID":
+		return filesScope(nil, "*.xml", "*.xhtml"), true
+	case "json":
+		return filesScope(nil, "*.json"), true
+	case "hasTags":
+		// tags are not read from the files of the locations
+		return func(string) bool { return false }, true
+	}
+	return nil, false
+}
+
+// filesScope matches the files that provider.GetFiles finds, the given files or
+// the files matching the patterns when none are given.
+func filesScope(filepaths []string, patterns ...string) func(path string) bool {
+	if len(filepaths) == 1 {
+		filepaths = strings.Split(filepaths[0], " ")
+	}
+	if len(filepaths) > 0 {
+		patterns = filepaths
+	}
+	return func(path string) bool {
+		for _, pattern := range patterns {
+			if pattern == path || filepath.Base(pattern) == filepath.Base(path) {
+				return true
+			}
+			if regex, err := regexp.Compile(pattern); err == nil {
+				if regex.MatchString(filepath.Base(path)) {
+					return true
+				}
+			} else if matchesFileGlob(pattern, path) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// matchesFileGlob matches the name or the path of the file with the glob.
+func matchesFileGlob(pattern string, path string) bool {
+	if matched, err := filepath.Match(pattern, filepath.Base(path)); err == nil && matched {
+		return true
+	}
+	matched, err := filepath.Match(pattern, path)
+	return err == nil && matched
+}
@@ -136,3 +136,92 @@ func BenchmarkRunOSSpecificGrepCommand(b *testing.B) {
 			provider.ProviderContext{Template: map[string]engine.ChainTemplate{}})
 	}
 }
+
+func Test_builtinProvider_FileScope(t *testing.T) {
+	p := NewBuiltinProvider(provider.Config{InitConfig: []provider.InitConfig{{Location: "/app"}}}, testr.New(t))
+	tests := []struct {
+		name          string
+		capability    string
+		conditionInfo interface{}
+		scoped        bool
+		in            []string
+		out           []string
+	}{
+		{
+			name:          "file glob",
+			capability:    "file",
+			conditionInfo: map[interface{}]interface{}{"pattern": "*.properties"},
+			scoped:        true,
+			in:            []string{"/app/src/app.properties"},
+			out:           []string{"/app/src/Main.java"},
+		},
+		{
+			name:          "file regex",
+			capability:    "file",
+			conditionInfo: map[interface{}]interface{}{"pattern": "^pom\\.xml$"},
+			scoped:        true,
+			in:            []string{"/app/pom.xml"},
+			out:           []string{"/app/src/pom.xml.bak"},
+		},
+		{
+			name:          "filecontent with a file pattern relative to the location",
+			capability:    "filecontent",
+			conditionInfo: map[interface{}]interface{}{"pattern": "port", "filePattern": "^src/.*\\.properties$"},
+			scoped:        true,
+			in:            []string{"/app/src/app.properties"},
+			out:           []string{"/app/config/app.properties", "/app/src/Main.java"},
+		},
+		{
+			name:          "filecontent of every file",
+			capability:    "filecontent",
+			conditionInfo: map[interface{}]interface{}{"pattern": "port"},
+		},
+		{
+			name:          "xml files",
+			capability:    "xml",
+			conditionInfo: map[interface{}]interface{}{"xpath": "//dependency"},
+			scoped:        true,
+			in:            []string{"/app/pom.xml", "/app/web/index.xhtml"},
+			out:           []string{"/app/src/Main.java"},
+		},
+		{
+			name:          "xml of given files",
+			capability:    "xml",
+			conditionInfo: map[interface{}]interface{}{"xpath": "//dependency", "filepaths": []interface{}{"pom.xml"}},
+			scoped:        true,
+			in:            []string{"/app/pom.xml"},
+			out:           []string{"/app/web.xml"},
+		},
+		{
+			name:          "xml of files found by another condition",
+			capability:    "xml",
+			conditionInfo: map[interface{}]interface{}{"xpath": "//dependency", "filepaths": "{{poms.filepaths}}"},
+		},
+		{
+			name:          "tags",
+			capability:    "hasTags",
+			conditionInfo: []interface{}{"Java EE"},
+			scoped:        true,
+			out:           []string{"/app/pom.xml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			condition := provider.ProviderCondition{Client: p, Capability: tt.capability, ConditionInfo: tt.conditionInfo}
+			scope, scoped := condition.FileScope()
+			if scoped != tt.scoped {
+				t.Fatalf("FileScope() scoped = %v, want %v", scoped, tt.scoped)
+			}
+			for _, path := range tt.in {
+				if !scope(path) {
+					t.Errorf("expected %s to be read", path)
+				}
+			}
+			for _, path := range tt.out {
+				if scope(path) {
+					t.Errorf("expected %s not to be read", path)
+				}
+			}
+		})
+	}
+}
@@ -70,7 +70,7 @@ func GetFiles(configLocation string, filepaths []string, patterns ...string) ([]
 			}
 		}
 	} else if len(filepaths) == 1 {
-		// Currently, rendering will render a list as a space separated paths as a single string.
+		// Templates in the middle of a string render a list as space separated paths.
 		patterns := strings.Split(filepaths[0], " ")
 		for _, pattern := range patterns {
 			if p, err := filepath.Rel(configLocation, pattern); err == nil {
@@ -85,6 +85,11 @@ func GetFiles(configLocation string, filepaths []string, patterns ...string) ([]
 		}
 	} else {
 		for _, pattern := range filepaths {
+			// Files found by chained conditions are used as they are.
+			if info, err := os.Stat(pattern); err == nil && filepath.IsAbs(pattern) && !info.IsDir() {
+				xmlFiles = append(xmlFiles, pattern)
+				continue
+			}
 			files, err := FindFilesMatchingPattern(configLocation, pattern)
 			if err != nil {
 				continue
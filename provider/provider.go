@@ -9,7 +9,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/cbroglie/mustache"
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/go-version"
 	"github.com/konveyor/analyzer-lsp/engine"
@@ -82,16 +81,58 @@ type Capability struct {
 }
 
 type Config struct {
-	Name         string       `yaml:"name,omitempty" json:"name,omitempty"`
-	BinaryPath   string       `yaml:"binaryPath,omitempty" json:"binaryPath,omitempty"`
-	Address      string       `yaml:"address,omitempty" json:"address,omitempty"`
-	CertPath     string       `yaml:"certPath,omitempty" json:"certPath,omitempty"`
-	JWTToken     string       `yaml:"jwtToken,omitempty" json:"jwtToken,omitempty"`
-	Proxy        *Proxy       `yaml:"proxyConfig,omitempty" json:"proxyConfig,omitempty"`
-	InitConfig   []InitConfig `yaml:"initConfig,omitempty" json:"initConfig,omitempty"`
+	Name       string       `yaml:"name,omitempty" json:"name,omitempty"`
+	BinaryPath string       `yaml:"binaryPath,omitempty" json:"binaryPath,omitempty"`
+	Address    string       `yaml:"address,omitempty" json:"address,omitempty"`
+	CertPath   string       `yaml:"certPath,omitempty" json:"certPath,omitempty"`
+	JWTToken   string       `yaml:"jwtToken,omitempty" json:"jwtToken,omitempty"`
+	Proxy      *Proxy       `yaml:"proxyConfig,omitempty" json:"proxyConfig,omitempty"`
+	InitConfig []InitConfig `yaml:"initConfig,omitempty" json:"initConfig,omitempty"`
+	// RuleTimeout is the default timeout of rules that use this provider, such as 5m.
+	RuleTimeout string `yaml:"ruleTimeout,omitempty" json:"ruleTimeout,omitempty"`
+	// CostHint is how expensive the conditions of this provider are to
+	// evaluate compared to other providers, lazily evaluated and/or conditions
+	// evaluate cheaper conditions first.
+	CostHint     int `yaml:"costHint,omitempty" json:"costHint,omitempty"`
 	ContextLines int
 }
 
+const (
+	// BuiltinCostHint is the default cost hint of the builtin provider.
+	BuiltinCostHint = 1
+	// DefaultCostHint is the default cost hint of the other providers, most
+	// of which query a language server.
+	DefaultCostHint = 10
+)
+
+// GetCostHint returns the cost hint of the provider, the default one of the
+// provider when it is not set.
+func (c Config) GetCostHint() int {
+	if c.CostHint > 0 {
+		return c.CostHint
+	}
+	if c.Name == "builtin" {
+		return BuiltinCostHint
+	}
+	return DefaultCostHint
+}
+
+// GetRuleTimeout returns the default timeout of rules that use this provider,
+// zero when there is none.
+func (c Config) GetRuleTimeout() (time.Duration, error) {
+	if c.RuleTimeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(c.RuleTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ruleTimeout %q for provider %s: %w", c.RuleTimeout, c.Name, err)
+	}
+	if timeout < 0 {
+		return 0, fmt.Errorf("invalid ruleTimeout %q for provider %s: must not be negative", c.RuleTimeout, c.Name)
+	}
+	return timeout, nil
+}
+
 type Proxy httpproxy.Config
 
 func (p Proxy) ToEnvVars() map[string]string {
@@ -163,6 +204,12 @@ func GetConfig(filepath string) ([]Config, error) {
 		if c.Proxy == nil {
 			c.Proxy = (*Proxy)(httpproxy.FromEnvironment())
 		}
+		if _, err := c.GetRuleTimeout(); err != nil {
+			return nil, err
+		}
+		if c.CostHint < 0 {
+			return nil, fmt.Errorf("invalid costHint %d for provider %s: must not be negative", c.CostHint, c.Name)
+		}
 		for jdx := range c.InitConfig {
 			ic := &c.InitConfig[jdx]
 			// if a specific proxy config not present
@@ -445,6 +492,13 @@ type DependencyLocationResolver interface {
 
 type Dep = konveyor.Dep
 type DepDAGItem = konveyor.DepDAGItem
+
+// ConditionFileScoper is implemented by clients that can tell which files the
+// conditions of their capabilities read, see engine.FileScoper.
+type ConditionFileScoper interface {
+	FileScope(capability string, conditionInfo interface{}) (func(path string) bool, bool)
+}
+
 type Startable interface {
 	Start(context.Context) error
 }
@@ -467,22 +521,43 @@ func (p CodeSnipProvider) GetCodeSnip(u uri.URI, l engine.Location) (string, err
 
 type ProviderCondition struct {
 	Client           ServiceClient
+	ProviderName     string
 	Capability       string
 	ConditionInfo    interface{}
 	Rule             engine.Rule
 	Ignore           bool
 	DepLabelSelector *labels.LabelSelector[*Dep]
+	CostHint         int
 }
 
 func (p ProviderCondition) Ignorable() bool {
 	return p.Ignore
 }
 
+func (p ProviderCondition) Cost() int {
+	return p.CostHint
+}
+
+// FileScope returns the files of the locations the condition reads when its
+// client can tell from the condition, conditions with templates read the files
+// found by other conditions.
+func (p ProviderCondition) FileScope() (func(path string) bool, bool) {
+	scoper, ok := p.Client.(ConditionFileScoper)
+	if !ok || len(TemplateReferences(p.ConditionInfo)) > 0 {
+		return nil, false
+	}
+	return scoper.FileScope(p.Capability, p.ConditionInfo)
+}
+
 func (p ProviderCondition) Evaluate(ctx context.Context, log logr.Logger, condCtx engine.ConditionContext) (engine.ConditionResponse, error) {
 	ctx, span := tracing.StartNewSpan(
 		ctx, "provider-condition", attribute.Key("cap").String(p.Capability))
 	defer span.End()
 
+	conditionInfo, err := templateCondition(p.ConditionInfo, condCtx.Template)
+	if err != nil {
+		return engine.ConditionResponse{}, fmt.Errorf("unable to template condition %s.%s: %w", p.ProviderName, p.Capability, err)
+	}
 	providerInfo := struct {
 		ProviderContext `yaml:",inline"`
 		Capability      map[string]interface{} `yaml:",inline"`
@@ -493,23 +568,18 @@ func (p ProviderCondition) Evaluate(ctx context.Context, log logr.Logger, condCt
 			RuleID:   condCtx.RuleID,
 		},
 		Capability: map[string]interface{}{
-			p.Capability: p.ConditionInfo,
+			p.Capability: conditionInfo,
 		},
 	}
 
-	serializedInfo, err := yaml.Marshal(providerInfo)
-	if err != nil {
-		//TODO(fabianvf)
-		panic(err)
-	}
-	log = log.WithValues("provider info", "cap", p.Capability, "condInfo", serializedInfo, "ruleID", condCtx.RuleID)
-	templatedInfo, err := templateCondition(serializedInfo, condCtx.Template)
+	templatedInfo, err := yaml.Marshal(providerInfo)
 	if err != nil {
-		//TODO(fabianvf)
-		panic(err)
+		return engine.ConditionResponse{}, fmt.Errorf("unable to serialize condition %s.%s: %w", p.ProviderName, p.Capability, err)
 	}
+	log = log.WithValues("provider info", "cap", p.Capability, "condInfo", templatedInfo, "ruleID", condCtx.RuleID)
 	span.SetAttributes(attribute.Key("condition").String(string(templatedInfo)))
 	resp, err := p.Client.Evaluate(ctx, p.Capability, templatedInfo)
+	engine.RecordProviderCall(ctx, p.ProviderName)
 	if err != nil {
 		// If an error always just return the empty
 		return engine.ConditionResponse{}, err
@@ -523,6 +593,7 @@ func (p ProviderCondition) Evaluate(ctx context.Context, log logr.Logger, condCt
 	var deps map[uri.URI][]*Dep
 	if p.DepLabelSelector != nil {
 		deps, err = p.Client.GetDependencies(ctx)
+		engine.RecordProviderCall(ctx, p.ProviderName)
 		if err != nil {
 			return engine.ConditionResponse{}, err
 		}
@@ -605,30 +676,6 @@ func matchDepLabelSelector(s *labels.LabelSelector[*Dep], inc IncidentContext, d
 	return matched, nil
 }
 
-func templateCondition(condition []byte, ctx map[string]engine.ChainTemplate) ([]byte, error) {
-	//TODO(shanw-hurley):
-	// this is needed because for the initial yaml read, we convert this to a string,
-	// then when it is used here, we need the value to be whatever is in the context and not
-	// a string nested in the type.
-	// This may require some documentation, but I believe that it should be fine.
-	// example:
-	// xml:
-	//   filepaths: '{{poms.filepaths}}'
-	//    xpath: //dependencies/dependency
-	// converted to
-	// xml:
-	//   filepaths: {{poms.filepaths}}
-	//   xpath: //dependencies/dependency
-	s := strings.ReplaceAll(string(condition), `'{{`, "{{")
-	s = strings.ReplaceAll(s, `}}'`, "}}")
-
-	s, err := mustache.RenderRaw(s, true, ctx)
-	if err != nil {
-		return nil, err
-	}
-	return []byte(s), nil
-}
-
 type DependencyConditionCap struct {
 	Upperbound string `json:"upperbound,omitempty" title:"Upperbound" description:"Match versions lower than or equal to"`
 	Lowerbound string `json:"lowerbound,omitempty" title:"Lowerbound" description:"Match versions greater than or equal to"`
@@ -644,7 +691,13 @@ type DependencyConditionCap struct {
 type DependencyCondition struct {
 	DependencyConditionCap
 
-	Client Client
+	Client       Client
+	ProviderName string
+	CostHint     int
+}
+
+func (dc DependencyCondition) Cost() int {
+	return dc.CostHint
 }
 
 func (dc DependencyCondition) Evaluate(ctx context.Context, log logr.Logger, condCtx engine.ConditionContext) (engine.ConditionResponse, error) {
@@ -653,6 +706,7 @@ func (dc DependencyCondition) Evaluate(ctx context.Context, log logr.Logger, con
 
 	resp := engine.ConditionResponse{}
 	deps, err := dc.Client.GetDependencies(ctx)
+	engine.RecordProviderCall(ctx, dc.ProviderName)
 	if err != nil {
 		return resp, err
 	}
@@ -0,0 +1,200 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/konveyor/analyzer-lsp/engine"
+)
+
+// templateReference matches a reference to a variable of a chained condition,
+// such as {{poms.filepaths}} or {{{ poms.extras.name }}}. A reference preceded
+// by a backslash, such as \{{ .Values.image }}, is escaped: it is kept as
+// written without the backslash, for patterns that look for Go or Helm
+// templates.
+var templateReference = regexp.MustCompile(`(\\?)\{\{\{?\s*([^{}#^/!>&\s][^{}\s]*)\s*\}?\}\}`)
+
+// TemplateReferences returns the variables referenced in the strings of a
+// condition, escaped references are not variables.
+func TemplateReferences(condition interface{}) []string {
+	references := []string{}
+	walkTemplateStrings(condition, func(s string) (interface{}, error) {
+		for _, match := range templateReference.FindAllStringSubmatch(s, -1) {
+			if match[1] == "" {
+				references = append(references, match[2])
+			}
+		}
+		return s, nil
+	})
+	return references
+}
+
+// TemplateVariable returns the name of the chained condition, or rule
+// dependency, that a reference uses, the longest name that prefixes the
+// reference. It returns false when none of the names are used.
+func TemplateVariable(reference string, names []string) (string, bool) {
+	found := ""
+	for _, name := range names {
+		if (reference == name || strings.HasPrefix(reference, name+".")) && len(name) > len(found) {
+			found = name
+		}
+	}
+	return found, found != ""
+}
+
+// templateCondition replaces the references to the variables of chained
+// conditions in the strings of a condition. A string that is a single reference
+// is replaced by the value of the variable, keeping its type such as a list of
+// file paths, other references are replaced by the value written as text.
+func templateCondition(condition interface{}, ctx map[string]engine.ChainTemplate) (interface{}, error) {
+	names := make([]string, 0, len(ctx))
+	for name := range ctx {
+		names = append(names, name)
+	}
+	return walkTemplateStrings(condition, func(s string) (interface{}, error) {
+		if match := templateReference.FindStringSubmatchIndex(s); match != nil && match[2] == match[3] && strings.TrimSpace(s) == s[match[0]:match[1]] {
+			return resolveTemplateReference(s[match[4]:match[5]], names, ctx)
+		}
+		var err error
+		text := templateReference.ReplaceAllStringFunc(s, func(m string) string {
+			submatch := templateReference.FindStringSubmatch(m)
+			if submatch[1] != "" {
+				return strings.TrimPrefix(m, submatch[1])
+			}
+			value, resolveErr := resolveTemplateReference(submatch[2], names, ctx)
+			if resolveErr != nil {
+				err = resolveErr
+				return m
+			}
+			return templateText(value)
+		})
+		return text, err
+	})
+}
+
+// resolveTemplateReference returns the value of a variable of a chained
+// condition, the variables that the condition did not set are nil.
+func resolveTemplateReference(reference string, names []string, ctx map[string]engine.ChainTemplate) (interface{}, error) {
+	name, ok := TemplateVariable(reference, names)
+	if !ok {
+		return nil, fmt.Errorf("template variable %s is not set, it must be the output of a condition with as or of a rule in dependsOn", reference)
+	}
+	template := ctx[name]
+	var value interface{} = map[string]interface{}{
+		"filepaths":     template.Filepaths,
+		"excludedPaths": template.ExcludedPaths,
+		"extras":        template.Extras,
+	}
+	if reference == name {
+		return value, nil
+	}
+	for _, key := range strings.Split(strings.TrimPrefix(reference, name+"."), ".") {
+		value = lookupTemplateKey(value, key)
+		if value == nil {
+			return nil, nil
+		}
+	}
+	return value, nil
+}
+
+// lookupTemplateKey returns the value of a key of a map, keys are matched
+// without case when there is no exact match so that both {{poms.filepaths}} and
+// {{poms.Filepaths}} can be used.
+func lookupTemplateKey(value interface{}, key string) interface{} {
+	values := map[string]interface{}{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		values = v
+	case map[interface{}]interface{}:
+		for k, item := range v {
+			values[fmt.Sprint(k)] = item
+		}
+	default:
+		return nil
+	}
+	if item, ok := values[key]; ok {
+		return item
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if strings.EqualFold(k, key) {
+			return values[k]
+		}
+	}
+	return nil
+}
+
+// templateText writes a value in a string, lists are separated by spaces.
+func templateText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(v, " ")
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, templateText(item))
+		}
+		return strings.Join(items, " ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// walkTemplateStrings returns a copy of the value in which every string is
+// replaced by the result of the function.
+func walkTemplateStrings(value interface{}, f func(string) (interface{}, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return f(v)
+	case map[interface{}]interface{}:
+		walked := make(map[interface{}]interface{}, len(v))
+		for key, item := range v {
+			w, err := walkTemplateStrings(item, f)
+			if err != nil {
+				return nil, err
+			}
+			walked[key] = w
+		}
+		return walked, nil
+	case map[string]interface{}:
+		walked := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			w, err := walkTemplateStrings(item, f)
+			if err != nil {
+				return nil, err
+			}
+			walked[key] = w
+		}
+		return walked, nil
+	case []interface{}:
+		walked := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			w, err := walkTemplateStrings(item, f)
+			if err != nil {
+				return nil, err
+			}
+			walked = append(walked, w)
+		}
+		return walked, nil
+	case []string:
+		walked := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			w, err := f(item)
+			if err != nil {
+				return nil, err
+			}
+			walked = append(walked, w)
+		}
+		return walked, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/konveyor/analyzer-lsp/engine"
+)
+
+func TestTemplateCondition(t *testing.T) {
+	ctx := map[string]engine.ChainTemplate{
+		"poms": {
+			Filepaths: []string{"/app/pom.xml", "/app/module/pom.xml"},
+			Extras:    map[string]interface{}{"name": "parent"},
+		},
+		"rules/config": {
+			Filepaths: []string{"/app/config.xml"},
+		},
+	}
+	tests := []struct {
+		name      string
+		condition interface{}
+		want      interface{}
+		shouldErr bool
+	}{
+		{
+			name:      "reference keeps the type of the variable",
+			condition: map[interface{}]interface{}{"filepaths": "{{poms.filepaths}}", "xpath": "//dependency"},
+			want:      map[interface{}]interface{}{"filepaths": []string{"/app/pom.xml", "/app/module/pom.xml"}, "xpath": "//dependency"},
+		},
+		{
+			name:      "field names of the chain template",
+			condition: map[interface{}]interface{}{"filepaths": "{{ poms.Filepaths }}"},
+			want:      map[interface{}]interface{}{"filepaths": []string{"/app/pom.xml", "/app/module/pom.xml"}},
+		},
+		{
+			name:      "reference to a rule in dependsOn",
+			condition: []interface{}{"{{{rules/config.filepaths}}}"},
+			want:      []interface{}{[]string{"/app/config.xml"}},
+		},
+		{
+			name:      "references in text",
+			condition: "module {{poms.extras.name}} in {{poms.filepaths}}",
+			want:      "module parent in /app/pom.xml /app/module/pom.xml",
+		},
+		{
+			name:      "variable that was not set is empty",
+			condition: map[interface{}]interface{}{"name": "{{poms.extras.version}}"},
+			want:      map[interface{}]interface{}{"name": nil},
+		},
+		{
+			name:      "escaped reference",
+			condition: map[interface{}]interface{}{"pattern": `\{{ .Values.image }}`},
+			want:      map[interface{}]interface{}{"pattern": "{{ .Values.image }}"},
+		},
+		{
+			name:      "escaped reference in text",
+			condition: `image: \{{.Values.image}} in {{poms.extras.name}}`,
+			want:      "image: {{.Values.image}} in parent",
+		},
+		{
+			name:      "unknown template variable",
+			condition: map[interface{}]interface{}{"filepaths": "{{jars.filepaths}}"},
+			shouldErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := templateCondition(tt.condition, ctx)
+			if tt.shouldErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("templateCondition() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateReferences(t *testing.T) {
+	condition := map[interface{}]interface{}{
+		"filepaths": "{{poms.filepaths}}",
+		"pattern":   `image: \{{ .Values.image }} {{{ rules/config.extras.name }}}`,
+		"names":     []interface{}{"{{jars.filepaths}}", `\{{ .Chart.Name }}`},
+	}
+	got := TemplateReferences(condition)
+	sort.Strings(got)
+	want := []string{"jars.filepaths", "poms.filepaths", "rules/config.extras.name"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TemplateReferences() = %v, want %v", got, want)
+	}
+}
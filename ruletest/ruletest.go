@@ -0,0 +1,404 @@
+// Package ruletest runs the rules of a ruleset against small source fixtures
+// and compares the incidents they find with the incidents that are expected.
+package ruletest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/konveyor/analyzer-lsp/engine"
+	"github.com/konveyor/analyzer-lsp/output/v1/konveyor"
+	"github.com/konveyor/analyzer-lsp/parser"
+	"github.com/konveyor/analyzer-lsp/provider"
+	"github.com/konveyor/analyzer-lsp/provider/lib"
+	"go.lsp.dev/uri"
+	"gopkg.in/yaml.v2"
+)
+
+// TestsFileSuffix is the suffix of tests files, the tests of rules.yaml are in
+// rules.test.yaml. The parser does not load these files as rules.
+const TestsFileSuffix = ".test.yaml"
+
+// TestsFile lists the tests of the rules of a rules file or ruleset.
+type TestsFile struct {
+	// RulesPath is the rules file or ruleset directory that is tested,
+	// relative to the tests file. It defaults to the rules file with the name
+	// of the tests file, or to the directory of the tests file.
+	RulesPath string     `yaml:"rulesPath,omitempty"`
+	Tests     []RuleTest `yaml:"tests"`
+}
+
+// RuleTest is a test of a rule, the incidents that it must find in a fixture.
+type RuleTest struct {
+	RuleID string `yaml:"ruleID"`
+	// Fixture is the directory of source code the rules run on, relative to
+	// the tests file.
+	Fixture string `yaml:"fixture"`
+	// Incidents are all of the incidents the rule must find, an empty list
+	// means that the rule must not match.
+	Incidents []ExpectedIncident `yaml:"incidents,omitempty"`
+}
+
+// ExpectedIncident is an incident a rule must find, fields that are not set
+// match any incident.
+type ExpectedIncident struct {
+	// File is the path of the file of the incident relative to the fixture.
+	File    string `yaml:"file,omitempty"`
+	Line    *int   `yaml:"line,omitempty"`
+	Message string `yaml:"message,omitempty"`
+	// Variables are compared with the variables of the incident of the same
+	// name, other variables of the incident are not compared.
+	Variables map[string]interface{} `yaml:"variables,omitempty"`
+}
+
+func (e ExpectedIncident) String() string {
+	return formatIncident(e.File, e.Line, e.Message, e.Variables)
+}
+
+// Result is the result of a test of a rule.
+type Result struct {
+	RuleID  string
+	Fixture string
+	// Diff lists the expected incidents that were not found, starting with -,
+	// and the incidents that were found and not expected, starting with +.
+	Diff []string
+	// Error is set when the rule, or the fixture, could not be run.
+	Error error
+}
+
+func (r Result) Passed() bool {
+	return r.Error == nil && len(r.Diff) == 0
+}
+
+func (r Result) String() string {
+	switch {
+	case r.Error != nil:
+		return fmt.Sprintf("FAIL %s (%s): %v", r.RuleID, r.Fixture, r.Error)
+	case len(r.Diff) > 0:
+		return fmt.Sprintf("FAIL %s (%s)\n    %s", r.RuleID, r.Fixture, strings.Join(r.Diff, "\n    "))
+	default:
+		return fmt.Sprintf("PASS %s (%s)", r.RuleID, r.Fixture)
+	}
+}
+
+// FindTestsFiles returns the tests file at the path, or the tests files in the
+// directory and its subdirectories.
+func FindTestsFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	files := []string{}
+	err = filepath.WalkDir(path, func(file string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(d.Name(), TestsFileSuffix) {
+			files = append(files, file)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// LoadTestsFile reads a tests file, the paths in it are made relative to the
+// working directory.
+func LoadTestsFile(path string) (TestsFile, error) {
+	testsFile := TestsFile{}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return testsFile, err
+	}
+	if err := yaml.UnmarshalStrict(content, &testsFile); err != nil {
+		return testsFile, fmt.Errorf("invalid tests file %s: %w", path, err)
+	}
+	if len(testsFile.Tests) == 0 {
+		return testsFile, fmt.Errorf("tests file %s has no tests", path)
+	}
+	dir := filepath.Dir(path)
+	if testsFile.RulesPath != "" {
+		testsFile.RulesPath = filepath.Join(dir, testsFile.RulesPath)
+	} else if rulesFile := strings.TrimSuffix(path, TestsFileSuffix) + ".yaml"; fileExists(rulesFile) {
+		testsFile.RulesPath = rulesFile
+	} else {
+		testsFile.RulesPath = dir
+	}
+	for i, test := range testsFile.Tests {
+		if test.RuleID == "" || test.Fixture == "" {
+			return testsFile, fmt.Errorf("test %d of %s must have a ruleID and a fixture", i+1, path)
+		}
+		testsFile.Tests[i].Fixture = filepath.Join(dir, test.Fixture)
+	}
+	return testsFile, nil
+}
+
+// Runner runs the tests of rules with the real providers and rule engine.
+type Runner struct {
+	// ProviderConfigs are the providers the rules use, their locations are
+	// replaced by the fixture of each test. The builtin provider is always
+	// used.
+	ProviderConfigs []provider.Config
+	Log             logr.Logger
+}
+
+// Run runs the tests of a tests file, the rules are run once for each fixture.
+func (r Runner) Run(ctx context.Context, path string) ([]Result, error) {
+	testsFile, err := LoadTestsFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fixtures := []string{}
+	testsByFixture := map[string][]RuleTest{}
+	for _, test := range testsFile.Tests {
+		if _, ok := testsByFixture[test.Fixture]; !ok {
+			fixtures = append(fixtures, test.Fixture)
+		}
+		testsByFixture[test.Fixture] = append(testsByFixture[test.Fixture], test)
+	}
+
+	results := []Result{}
+	for _, fixture := range fixtures {
+		tests := testsByFixture[fixture]
+		location, ruleSets, err := r.runFixture(ctx, testsFile.RulesPath, fixture)
+		for _, test := range tests {
+			result := Result{RuleID: test.RuleID, Fixture: fixture}
+			if err != nil {
+				result.Error = err
+			} else {
+				result.Diff, result.Error = compareIncidents(ruleSets, test, location)
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// runFixture runs the rules on a fixture with providers created for it, it
+// returns the absolute path of the fixture along with the output of the rules.
+func (r Runner) runFixture(ctx context.Context, rulesPath string, fixture string) (string, []konveyor.RuleSet, error) {
+	location, err := filepath.Abs(fixture)
+	if err != nil {
+		return "", nil, err
+	}
+	if !fileExists(location) {
+		return "", nil, fmt.Errorf("fixture %s does not exist", fixture)
+	}
+
+	providers := map[string]provider.InternalProviderClient{}
+	defer func() {
+		for _, p := range providers {
+			p.Stop()
+		}
+	}()
+	for _, config := range fixtureConfigs(r.ProviderConfigs, location) {
+		client, err := lib.GetProviderClient(config, r.Log)
+		if err != nil {
+			return "", nil, fmt.Errorf("unable to create provider %s: %w", config.Name, err)
+		}
+		providers[config.Name] = client
+		if s, ok := client.(provider.Startable); ok {
+			if err := s.Start(ctx); err != nil {
+				return "", nil, fmt.Errorf("unable to start provider %s: %w", config.Name, err)
+			}
+		}
+	}
+
+	ruleParser := parser.RuleParser{
+		ProviderNameToClient: providers,
+		Log:                  r.Log.WithName("parser"),
+	}
+	ruleSets, needProviders, err := ruleParser.LoadRules(rulesPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to load rules %s: %w", rulesPath, err)
+	}
+
+	// other providers can return additional configs for the builtin provider,
+	// it is initialized last
+	additionalBuiltinConfigs := []provider.InitConfig{}
+	for name, client := range needProviders {
+		if name == "builtin" {
+			continue
+		}
+		builtinConfigs, err := client.ProviderInit(ctx, nil)
+		if err != nil {
+			return "", nil, fmt.Errorf("unable to init provider %s: %w", name, err)
+		}
+		additionalBuiltinConfigs = append(additionalBuiltinConfigs, builtinConfigs...)
+	}
+	if builtinClient, ok := needProviders["builtin"]; ok {
+		if _, err := builtinClient.ProviderInit(ctx, additionalBuiltinConfigs); err != nil {
+			return "", nil, fmt.Errorf("unable to init provider builtin: %w", err)
+		}
+	}
+
+	eng := engine.CreateRuleEngine(ctx, 10, r.Log, engine.WithLocationPrefixes([]string{location}))
+	defer eng.Stop()
+	return location, eng.RunRules(ctx, ruleSets), nil
+}
+
+// fixtureConfigs returns the provider configs with their locations replaced by
+// the fixture, along with the builtin provider.
+func fixtureConfigs(configs []provider.Config, location string) []provider.Config {
+	builtin := provider.Config{Name: "builtin"}
+	fixtureConfigs := []provider.Config{}
+	for _, config := range configs {
+		initConfigs := []provider.InitConfig{}
+		for _, initConfig := range config.InitConfig {
+			initConfig.Location = location
+			initConfigs = append(initConfigs, initConfig)
+		}
+		config.InitConfig = initConfigs
+		if config.Name == "builtin" {
+			builtin = config
+			continue
+		}
+		fixtureConfigs = append(fixtureConfigs, config)
+	}
+	if len(builtin.InitConfig) == 0 {
+		builtin.InitConfig = []provider.InitConfig{{Location: location}}
+	}
+	return append(fixtureConfigs, builtin)
+}
+
+// compareIncidents compares the incidents of the rule with the expected
+// incidents, each expected incident matches one incident of the rule.
+func compareIncidents(ruleSets []konveyor.RuleSet, test RuleTest, location string) ([]string, error) {
+	incidents := []konveyor.Incident{}
+	found := false
+	for _, ruleSet := range ruleSets {
+		if ruleErr, ok := ruleSet.Errors[test.RuleID]; ok {
+			return nil, fmt.Errorf("rule failed: %s", ruleErr)
+		}
+		for _, violations := range []map[string]konveyor.Violation{ruleSet.Violations, ruleSet.Insights} {
+			if violation, ok := violations[test.RuleID]; ok {
+				incidents = append(incidents, violation.Incidents...)
+				found = true
+			}
+		}
+		found = found || contains(ruleSet.Unmatched, test.RuleID) || contains(ruleSet.Skipped, test.RuleID)
+	}
+	if !found {
+		return nil, fmt.Errorf("rule was not loaded")
+	}
+	sort.SliceStable(incidents, func(i, j int) bool {
+		if incidents[i].URI != incidents[j].URI {
+			return incidents[i].URI < incidents[j].URI
+		}
+		return lineNumber(incidents[i]) < lineNumber(incidents[j])
+	})
+	files := make([]string, len(incidents))
+	for i, incident := range incidents {
+		files[i] = incidentFile(incident, location)
+	}
+
+	diff := []string{}
+	matched := make([]bool, len(incidents))
+	for _, expected := range test.Incidents {
+		found := false
+		for i, incident := range incidents {
+			if !matched[i] && matchIncident(expected, files[i], incident) {
+				matched[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			diff = append(diff, "- "+expected.String())
+		}
+	}
+	for i, incident := range incidents {
+		if !matched[i] {
+			diff = append(diff, "+ "+formatIncident(files[i], incident.LineNumber, incident.Message, incident.Variables))
+		}
+	}
+	return diff, nil
+}
+
+func matchIncident(expected ExpectedIncident, file string, incident konveyor.Incident) bool {
+	if expected.File != "" && filepath.ToSlash(filepath.Clean(expected.File)) != file {
+		return false
+	}
+	if expected.Line != nil && (incident.LineNumber == nil || *expected.Line != *incident.LineNumber) {
+		return false
+	}
+	if expected.Message != "" && strings.TrimSpace(expected.Message) != strings.TrimSpace(incident.Message) {
+		return false
+	}
+	for name, value := range expected.Variables {
+		actual, ok := incident.Variables[name]
+		if !ok || !(reflect.DeepEqual(value, actual) || fmt.Sprint(value) == fmt.Sprint(actual)) {
+			return false
+		}
+	}
+	return true
+}
+
+// incidentFile returns the path of the file of an incident relative to the
+// fixture, for the incident to be compared with the expected incidents.
+func incidentFile(incident konveyor.Incident, location string) string {
+	if !strings.HasPrefix(string(incident.URI), uri.FileScheme+"://") {
+		return string(incident.URI)
+	}
+	rel, err := filepath.Rel(location, incident.URI.Filename())
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return string(incident.URI)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func formatIncident(file string, line *int, message string, variables map[string]interface{}) string {
+	location := file
+	if location == "" {
+		location = "*"
+	}
+	if line != nil {
+		location = fmt.Sprintf("%s:%d", location, *line)
+	}
+	parts := []string{location}
+	if message != "" {
+		parts = append(parts, fmt.Sprintf("%q", strings.TrimSpace(message)))
+	}
+	if len(variables) > 0 {
+		names := make([]string, 0, len(variables))
+		for name := range variables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		values := []string{}
+		for _, name := range names {
+			values = append(values, fmt.Sprintf("%s=%v", name, variables[name]))
+		}
+		parts = append(parts, "{"+strings.Join(values, ", ")+"}")
+	}
+	return strings.Join(parts, " ")
+}
+
+func lineNumber(incident konveyor.Incident) int {
+	if incident.LineNumber == nil {
+		return 0
+	}
+	return *incident.LineNumber
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
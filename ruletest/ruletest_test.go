@@ -0,0 +1,93 @@
+package ruletest
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestRunner(t *testing.T) {
+	fixture := filepath.Join("testdata", "fixtures", "app")
+	tests := []struct {
+		name      string
+		testsFile string
+		want      []Result
+	}{
+		{
+			name:      "incidents found by the rules",
+			testsFile: "rules.test.yaml",
+			want: []Result{
+				{RuleID: "todo-001", Fixture: fixture},
+				{RuleID: "properties-001", Fixture: fixture},
+			},
+		},
+		{
+			name:      "incidents that differ",
+			testsFile: "failing.test.yaml",
+			want: []Result{
+				{RuleID: "todo-001", Fixture: fixture, Diff: []string{
+					"- notes.txt:3",
+					`+ notes.txt:2 "TODO comment: remove this" {matchingText=TODO:, todo=remove this}`,
+				}},
+				{RuleID: "unknown-001", Fixture: fixture},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := Runner{Log: logr.Discard()}
+			results, err := runner.Run(context.Background(), filepath.Join("testdata", tt.testsFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(tt.want) {
+				t.Fatalf("expected %d results, got %v", len(tt.want), results)
+			}
+			for i, want := range tt.want {
+				got := results[i]
+				if got.RuleID != want.RuleID || got.Fixture != want.Fixture {
+					t.Errorf("expected result of %s (%s), got %s (%s)", want.RuleID, want.Fixture, got.RuleID, got.Fixture)
+				}
+				if want.RuleID == "unknown-001" {
+					if got.Error == nil || got.Passed() {
+						t.Errorf("expected an error for a rule that does not exist, got %s", got)
+					}
+					continue
+				}
+				if got.Error != nil {
+					t.Fatalf("unexpected error: %v", got.Error)
+				}
+				if len(got.Diff) != len(want.Diff) || (len(got.Diff) > 0 && !reflect.DeepEqual(got.Diff, want.Diff)) {
+					t.Errorf("expected diff %q, got %q", want.Diff, got.Diff)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadTestsFile(t *testing.T) {
+	testsFile, err := LoadTestsFile(filepath.Join("testdata", "rules.test.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if testsFile.RulesPath != filepath.Join("testdata", "rules.yaml") {
+		t.Errorf("expected the rules file with the name of the tests file, got %s", testsFile.RulesPath)
+	}
+	line := 2
+	want := RuleTest{
+		RuleID:  "todo-001",
+		Fixture: filepath.Join("testdata", "fixtures", "app"),
+		Incidents: []ExpectedIncident{{
+			File:      "notes.txt",
+			Line:      &line,
+			Message:   "TODO comment: remove this",
+			Variables: map[string]interface{}{"todo": "remove this"},
+		}},
+	}
+	if !reflect.DeepEqual(testsFile.Tests[0], want) {
+		t.Errorf("expected %#v, got %#v", want, testsFile.Tests[0])
+	}
+}